@@ -1,15 +1,28 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log" // Standard library logger
-	"os"  // For os.Exit
+	"net/http"
+	"os" // For os.Exit
+	"os/signal"
+	"syscall"
+	"time"
 
 	"app-store-api/pkg/api"
 	"app-store-api/pkg/appcatalog"
+	"app-store-api/pkg/approvals"
+	"app-store-api/pkg/audit"
+	"app-store-api/pkg/auth"
 	"app-store-api/pkg/config"
+	"app-store-api/pkg/expiry"
 	"app-store-api/pkg/helm"
 	"app-store-api/pkg/metrics"
+	"app-store-api/pkg/schedules"
+	"app-store-api/pkg/server"
+	"app-store-api/pkg/telemetry"
 
 	"github.com/gin-gonic/gin"
 	"k8s.io/client-go/kubernetes"
@@ -28,6 +41,10 @@ func main() {
 	// Set Gin mode
 	gin.SetMode(cfg.GinMode)
 
+	// Cancelled on SIGINT/SIGTERM, so the background loops can finish their work
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
 	// ---- Initialize Kubernetes and Metrics Clients ----
 	var k8sRestConfig *rest.Config
 	var k8sInitErr error
@@ -72,18 +89,108 @@ func main() {
 	}
 
 	// Initialize Metrics Service
-	metricsService := metrics.NewService(kubeClientset, metricsClientset)
+	metricsService := metrics.NewService(kubeClientset, metricsClientset, metrics.Options{
+		Namespace:       cfg.AppInstallNamespace,
+		CollectReleases: cfg.MetricsCollectReleases,
+		HistoryFile:     cfg.MetricsHistoryFile,
+	})
+	metricsDone := make(chan struct{})
+	go func() {
+		defer close(metricsDone)
+		metricsService.Run(ctx, cfg.MetricsPollInterval)
+	}()
+	telemetry.Registry.MustRegister(metricsService.PrometheusCollector())
+
+	// Initialize Audit Service
+	auditSink, err := audit.NewSink(cfg, kubeClientset)
+	if err != nil {
+		log.Fatalf("Failed to initialize audit sink: %v", err)
+	}
+	auditService := audit.NewService(auditSink)
+
+	// Initialize the sleep schedules and their scheduler
+	scheduleStore := schedules.NewStore(kubeClientset, cfg.AppInstallNamespace)
+	go schedules.NewScheduler(scheduleStore, helmClient, auditService, cfg.ScheduleLocation).Run(ctx, cfg.ScheduleInterval)
+
+	// Initialize the release expiries
+	expiryStore := expiry.NewStore(kubeClientset, cfg.AppInstallNamespace)
 
 	// Initialize API Handler with dependencies
-	apiHandler := api.NewAPIHandler(catalogService, helmClient, metricsService)
+	apiHandler := api.NewAPIHandler(api.Dependencies{
+		Config:        cfg,
+		Catalog:       catalogService,
+		Helm:          helmClient,
+		Metrics:       metricsService,
+		Audit:         auditService,
+		Authorizer:    auth.NewAuthorizer(cfg),
+		ApprovalStore: approvals.NewStore(kubeClientset, cfg.AppInstallNamespace),
+		ScheduleStore: scheduleStore,
+		ExpiryStore:   expiryStore,
+	})
+
+	apiHandler.AuditCatalog()
+
+	// Uninstall expired releases
+	reaper := expiry.NewReaper(expiryStore, helmClient, auditService, expiry.Options{
+		WebhookURL:    cfg.ExpiryWebhookURL,
+		WarningBefore: cfg.ExpiryWarningBefore,
+		Cleanup: func(releaseName string) {
+			if err := scheduleStore.Delete(releaseName); err != nil {
+				log.Printf("Warning: %v", err)
+			}
+			apiHandler.ForgetRelease(releaseName)
+		},
+	})
+	go reaper.Run(ctx, cfg.ExpiryCheckInterval)
+
+	// Reconcile the releases with the desired-state file, when one is configured
+	if cfg.SyncFile != "" {
+		go apiHandler.RunSync(ctx, cfg.SyncInterval)
+	}
 
 	// Setup router
-	router := api.SetupRouter(apiHandler)
+	router := api.SetupRouter(apiHandler, cfg)
 
 	// Start server
-	listenAddr := fmt.Sprintf(":%s", cfg.ListenPort)
-	log.Printf("API server starting on %s in %s mode", listenAddr, cfg.GinMode)
-       if err := router.Run(listenAddr); err != nil {
-               log.Fatalf("Failed to run server: %v", err)
-       }
+	tlsConfig, err := server.NewTLSConfig(cfg)
+	if err != nil {
+		log.Fatalf("Failed to configure TLS: %v", err)
+	}
+	httpServer := &http.Server{
+		Addr:      fmt.Sprintf(":%s", cfg.ListenPort),
+		Handler:   router,
+		TLSConfig: tlsConfig,
+	}
+
+	serverErr := make(chan error, 1)
+	go func() {
+		if tlsConfig != nil {
+			log.Printf("API server starting on %s (HTTPS) in %s mode", httpServer.Addr, cfg.GinMode)
+			// Certificates come from TLSConfig.GetCertificate, so no files are passed here.
+			serverErr <- httpServer.ListenAndServeTLS("", "")
+		} else {
+			log.Printf("API server starting on %s in %s mode", httpServer.Addr, cfg.GinMode)
+			serverErr <- httpServer.ListenAndServe()
+		}
+	}()
+
+	select {
+	case err := <-serverErr:
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Failed to run server: %v", err)
+		}
+	case <-ctx.Done():
+		log.Println("Shutting down...")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := httpServer.Shutdown(shutdownCtx); err != nil {
+			log.Printf("Warning: Server shutdown: %v", err)
+		}
+		// The collector persists the metrics history on its way out
+		select {
+		case <-metricsDone:
+		case <-shutdownCtx.Done():
+			log.Println("Warning: Metrics collector did not stop in time, history not saved")
+		}
+	}
 }
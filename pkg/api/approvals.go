@@ -0,0 +1,193 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"app-store-api/pkg/approvals"
+)
+
+// decisionRequest is the optional body of approve and reject calls.
+type decisionRequest struct {
+	Reason string `json:"reason,omitempty"`
+}
+
+// ListApprovalsHandler lists approval requests. Only pending requests are returned
+// unless the status query parameter asks for another status, or "all".
+func (h *APIHandler) ListApprovalsHandler(c *gin.Context) {
+	status := approvals.Status(c.DefaultQuery("status", string(approvals.StatusPending)))
+	if status == "all" {
+		status = ""
+	}
+	requests, err := h.approvalStore.List(status)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	for i := range requests {
+		h.failInterruptedInstall(&requests[i])
+	}
+	c.JSON(http.StatusOK, h.redactApprovals(requests))
+}
+
+// GetApprovalHandler returns a single approval request to its requester or to an admin.
+func (h *APIHandler) GetApprovalHandler(c *gin.Context) {
+	req, ok := h.loadApproval(c)
+	if !ok {
+		return
+	}
+	if id := identityFrom(c); req.RequestedBy != id.User && !h.authorizer.IsAdmin(id) {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Approval request '%s' not found.", req.ID)})
+		return
+	}
+	c.JSON(http.StatusOK, h.redactApproval(*req))
+}
+
+// ApproveHandler approves a pending request and runs the install as the original requester.
+func (h *APIHandler) ApproveHandler(c *gin.Context) {
+	req, ok := h.loadApproval(c)
+	if !ok {
+		return
+	}
+	if req.Status != approvals.StatusPending {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Approval request '%s' is already %s.", req.ID, req.Status)})
+		return
+	}
+	chartMeta, err := h.catalogService.GetChartByName(req.Chart)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+
+	if wantsExpiry(req.TTL, req.ExpiresAt) {
+		if _, err := h.releaseDeadline(req.TTL, req.ExpiresAt, time.Now()); err != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Approval request '%s' cannot be installed: %v", req.ID, err)})
+			return
+		}
+	}
+
+	// Claim the request before installing so a concurrent approval cannot install twice
+	admin := identityFrom(c).User
+	now := time.Now().UTC()
+	req.Status = approvals.StatusInstalling
+	req.DecidedBy = admin
+	req.DecidedAt = &now
+	if !h.saveApproval(c, req) {
+		return
+	}
+
+	log.Printf("Approval request %s approved by '%s', installing chart '%s' as release '%s' for '%s'", req.ID, admin, req.Chart, req.ReleaseName, req.RequestedBy)
+	rel, installErr := h.installRelease(c, chartMeta, req.ReleaseName, req.Values, installActor{User: req.RequestedBy, ApprovedBy: admin})
+	if installErr != nil {
+		req.Status = approvals.StatusFailed
+		req.Error = installErr.Error()
+	} else {
+		req.Status = approvals.StatusInstalled
+	}
+	if err := h.approvalStore.Update(req); err != nil {
+		log.Printf("Error saving outcome of approval request %s: %v", req.ID, err)
+	}
+
+	if installErr != nil {
+		body := installErrorBody(installErr)
+		body["approval"] = h.redactApproval(*req)
+		c.JSON(installErrorStatus(installErr), body)
+		return
+	}
+	summary := h.releaseSummary(chartMeta, rel)
+	response := gin.H{
+		"message":  fmt.Sprintf("Approval request '%s' approved, chart '%s' installed as release '%s'", req.ID, chartMeta.Chart, rel.Name),
+		"approval": h.redactApproval(*req),
+		"release":  summary,
+	}
+	if wantsExpiry(req.TTL, req.ExpiresAt) {
+		if rec, err := h.setReleaseExpiry(rel, req.TTL, req.ExpiresAt, req.RequestedBy); err != nil {
+			response["expiry_error"] = err.Error()
+		} else {
+			summary["expires_at"] = rec.ExpiresAt
+		}
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// RejectHandler rejects a pending request.
+func (h *APIHandler) RejectHandler(c *gin.Context) {
+	var body decisionRequest
+	if err := c.ShouldBindJSON(&body); err != nil && err.Error() != "EOF" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid request body: %v", err)})
+		return
+	}
+
+	req, ok := h.loadApproval(c)
+	if !ok {
+		return
+	}
+	if req.Status != approvals.StatusPending {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Approval request '%s' is already %s.", req.ID, req.Status)})
+		return
+	}
+
+	now := time.Now().UTC()
+	req.Status = approvals.StatusRejected
+	req.DecidedBy = identityFrom(c).User
+	req.DecidedAt = &now
+	req.Reason = body.Reason
+	if !h.saveApproval(c, req) {
+		return
+	}
+	log.Printf("Approval request %s rejected by '%s'", req.ID, req.DecidedBy)
+	c.JSON(http.StatusOK, gin.H{
+		"message":  fmt.Sprintf("Approval request '%s' rejected.", req.ID),
+		"approval": h.redactApproval(*req),
+	})
+}
+
+// loadApproval fetches the request named by the :id parameter, writing the error response on failure.
+func (h *APIHandler) loadApproval(c *gin.Context) (*approvals.Request, bool) {
+	id := c.Param("id")
+	req, err := h.approvalStore.Get(id)
+	if err != nil {
+		if errors.Is(err, approvals.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Approval request '%s' not found.", id)})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return nil, false
+	}
+	h.failInterruptedInstall(req)
+	return req, true
+}
+
+// failInterruptedInstall marks a request left installing as failed once its install cannot
+// still be running, as when the API stopped before recording the outcome. Installs are bounded
+// by the Helm timeout; twice that leaves room for fetching the chart.
+func (h *APIHandler) failInterruptedInstall(req *approvals.Request) {
+	if req.Status != approvals.StatusInstalling || req.DecidedAt == nil || time.Since(*req.DecidedAt) < 2*h.config.HelmTimeout {
+		return
+	}
+	req.Status = approvals.StatusFailed
+	req.Error = "install interrupted before its outcome was recorded"
+	if err := h.approvalStore.Update(req); err != nil {
+		log.Printf("Warning: Could not mark approval request %s as failed: %v", req.ID, err)
+		return
+	}
+	log.Printf("Approval request %s was installing since %s, marked as failed", req.ID, req.DecidedAt.Format(time.RFC3339))
+}
+
+// saveApproval stores a decision, writing the error response on failure.
+func (h *APIHandler) saveApproval(c *gin.Context, req *approvals.Request) bool {
+	if err := h.approvalStore.Update(req); err != nil {
+		if errors.Is(err, approvals.ErrConflict) {
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Approval request '%s' was decided concurrently.", req.ID)})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return false
+	}
+	return true
+}
@@ -0,0 +1,110 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"app-store-api/pkg/audit"
+)
+
+// recordAudit completes an audit entry with the caller details, the outcome and
+// the duration since startTime, then hands it to the audit service.
+// An Actor already set on the entry is kept. c is nil for operations not made
+// on behalf of a request, which have no caller details.
+func (h *APIHandler) recordAudit(c *gin.Context, entry audit.Entry, startTime time.Time, opErr error) {
+	if h.auditService == nil {
+		return
+	}
+	if c != nil {
+		if entry.Actor == "" {
+			entry.Actor = identityFrom(c).User
+		}
+		entry.SourceIP = c.ClientIP()
+	}
+	entry.DurationMs = time.Since(startTime).Milliseconds()
+	if opErr != nil {
+		entry.Outcome = audit.OutcomeFailure
+		entry.Error = opErr.Error()
+	} else {
+		entry.Outcome = audit.OutcomeSuccess
+	}
+	h.auditService.Record(entry)
+}
+
+// CatalogActor is the audit actor of the catalog changes, found when the API starts.
+const CatalogActor = "catalog"
+
+// AuditCatalog records a catalog change when the loaded catalog differs from the one last
+// recorded. The catalog is only read at startup, so that is when a change shows. Without a
+// queryable sink, every startup is recorded.
+func (h *APIHandler) AuditCatalog() {
+	if h.auditService == nil {
+		return
+	}
+	hash := h.catalogService.Hash()
+	last, err := h.auditService.Query(audit.Filter{Actor: CatalogActor, Limit: 1})
+	if err != nil && !errors.Is(err, audit.ErrQueryNotSupported) {
+		log.Printf("Warning: Could not read the last catalog change: %v", err)
+	}
+	if len(last) > 0 && last[0].ValuesHash == hash {
+		return
+	}
+	log.Printf("Chart catalog %s changed (%d charts)", h.config.ChartConfigPath, len(h.catalogService.GetAvailableCharts()))
+	h.recordAudit(nil, audit.Entry{
+		Action:     audit.ActionCatalogChange,
+		Actor:      CatalogActor,
+		Target:     h.config.ChartConfigPath,
+		ValuesHash: hash,
+	}, time.Now(), nil)
+}
+
+// GetAuditHandler handles requests to query the audit log.
+// Supported query parameters: since, until (RFC 3339), actor, release and limit.
+func (h *APIHandler) GetAuditHandler(c *gin.Context) {
+	if h.auditService == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Audit log is not configured."})
+		return
+	}
+
+	filter := audit.Filter{
+		Actor:   c.Query("actor"),
+		Release: c.Query("release"),
+		Limit:   100,
+	}
+	var err error
+	if since := c.Query("since"); since != "" {
+		if filter.Since, err = time.Parse(time.RFC3339, since); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid 'since' timestamp: %v", err)})
+			return
+		}
+	}
+	if until := c.Query("until"); until != "" {
+		if filter.Until, err = time.Parse(time.RFC3339, until); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid 'until' timestamp: %v", err)})
+			return
+		}
+	}
+	if limit := c.Query("limit"); limit != "" {
+		if filter.Limit, err = strconv.Atoi(limit); err != nil || filter.Limit < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'limit', expected a non-negative integer."})
+			return
+		}
+	}
+
+	entries, err := h.auditService.Query(filter)
+	if err != nil {
+		if errors.Is(err, audit.ErrQueryNotSupported) {
+			c.JSON(http.StatusNotImplemented, gin.H{"error": "The configured audit sink cannot be queried."})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, entries)
+}
@@ -0,0 +1,31 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetClusterStorageHandler lists the StorageClasses and the volume claims of each release.
+func (h *APIHandler) GetClusterStorageHandler(c *gin.Context) {
+	overview, err := h.metricsService.GetStorage()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, overview)
+}
+
+// GetClusterNodePortsHandler lists the NodePorts of the cluster, who uses them and the
+// reservations of the app store.
+func (h *APIHandler) GetClusterNodePortsHandler(c *gin.Context) {
+	start, end, allocations, err := h.helmClient.ListNodePorts()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"range":       gin.H{"start": start, "end": end},
+		"allocations": allocations,
+	})
+}
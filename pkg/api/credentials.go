@@ -0,0 +1,36 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"app-store-api/pkg/helm"
+)
+
+// GetReleaseCredentialsHandler reveals the generated credentials of a release
+// to the user who installed it, or to an admin.
+func (h *APIHandler) GetReleaseCredentialsHandler(c *gin.Context) {
+	releaseName := c.Param("releaseName")
+	creds, err := h.helmClient.GetReleaseCredentials(releaseName)
+	if err != nil {
+		if errors.Is(err, helm.ErrNoCredentials) {
+			c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Release '%s' has no generated credentials.", releaseName)})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	id := identityFrom(c)
+	if creds.RequestedBy != id.User && !h.authorizer.IsAdmin(id) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the user who installed the release or an admin can reveal its credentials."})
+		return
+	}
+	log.Printf("Credentials of release '%s' revealed to '%s'", releaseName, id.User)
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, creds)
+}
@@ -0,0 +1,236 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"k8s.io/client-go/tools/remotecommand"
+
+	"app-store-api/pkg/audit"
+	"app-store-api/pkg/helm"
+)
+
+// defaultExecCommand is the command run when the client does not give one.
+const defaultExecCommand = "sh"
+
+// execMessage is a JSON text message of the exec protocol. Clients send "stdin" (data)
+// and "resize" (cols, rows); the server sends "exit" (error) before closing.
+type execMessage struct {
+	Type  string `json:"type"`
+	Data  string `json:"data,omitempty"`
+	Cols  uint16 `json:"cols,omitempty"`
+	Rows  uint16 `json:"rows,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// ExecHandler opens an interactive shell in a container of a release pod over a WebSocket.
+// Query parameters: container (defaults to the pod default container) and command, repeated
+// for each argument (defaults to "sh"). Terminal output is sent as binary messages; the
+// session ends after EXEC_IDLE_TIMEOUT without input or output, or after EXEC_MAX_DURATION.
+func (h *APIHandler) ExecHandler(c *gin.Context) {
+	releaseName := c.Param("releaseName")
+	podName := c.Param("pod")
+	command := c.QueryArray("command")
+	if len(command) == 0 {
+		command = []string{defaultExecCommand}
+	}
+
+	container, err := h.helmClient.ResolveExecContainer(c.Request.Context(), releaseName, podName, c.Query("container"))
+	if err != nil {
+		if errors.Is(err, helm.ErrPodNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Pod '%s' not found in release '%s'.", podName, releaseName)})
+		} else {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	upgrader := websocket.Upgrader{CheckOrigin: h.execOriginAllowed}
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// The upgrader has already replied to the client
+		log.Printf("Exec into pod '%s' of release '%s': WebSocket upgrade failed: %v", podName, releaseName, err)
+		return
+	}
+	defer conn.Close()
+
+	startTime := time.Now()
+	log.Printf("User '%s' opened a shell in %s/%s of release '%s' (command %q)", identityFrom(c).User, podName, container, releaseName, command)
+	sessionErr := h.runExecSession(c.Request.Context(), conn, podName, container, command)
+
+	exit := execMessage{Type: "exit"}
+	if sessionErr != nil {
+		exit.Error = sessionErr.Error()
+	}
+	_ = conn.WriteJSON(exit)
+	_ = conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+
+	log.Printf("Shell in %s/%s of release '%s' closed after %v: %v", podName, container, releaseName, time.Since(startTime).Round(time.Second), sessionErr)
+	h.recordAudit(c, audit.Entry{
+		Time:    startTime,
+		Action:  audit.ActionExec,
+		Release: releaseName,
+		Target:  podName + "/" + container,
+	}, startTime, sessionErr)
+}
+
+// runExecSession wires the WebSocket to the exec stream until the command exits, the client
+// disconnects, or a timeout closes the session.
+func (h *APIHandler) runExecSession(parent context.Context, conn *websocket.Conn, podName, container string, command []string) error {
+	ctx, cancel := context.WithTimeout(parent, h.config.ExecMaxDuration)
+	defer cancel()
+
+	s := &execSession{conn: conn, resize: make(chan remotecommand.TerminalSize, 4), done: ctx.Done()}
+	s.touch()
+	stdinReader, stdinWriter := io.Pipe()
+	defer stdinReader.Close()
+
+	var idle atomic.Bool
+	go func() {
+		defer cancel()
+		defer stdinWriter.Close()
+		s.readClient(stdinWriter)
+	}()
+	go func() {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if time.Since(s.lastActivity()) >= h.config.ExecIdleTimeout {
+					idle.Store(true)
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+
+	err := h.helmClient.ExecInPod(ctx, podName, helm.ExecOptions{
+		Container: container,
+		Command:   command,
+		Stdin:     stdinReader,
+		Stdout:    s,
+		Resize:    s,
+	})
+	switch {
+	case idle.Load():
+		return fmt.Errorf("session closed after %v without activity", h.config.ExecIdleTimeout)
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return fmt.Errorf("session closed after the maximum duration of %v", h.config.ExecMaxDuration)
+	case ctx.Err() != nil:
+		// The client disconnected
+		return nil
+	}
+	return err
+}
+
+// execOriginAllowed accepts requests without an Origin (non-browser clients), from the API's
+// own host, or from an origin listed in CORS_ALLOWED_ORIGINS. A "*" entry is not honoured:
+// browsers send cookies with WebSocket handshakes whatever the CORS policy.
+func (h *APIHandler) execOriginAllowed(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	for _, allowed := range h.config.CORSAllowedOrigins {
+		if allowed != "*" && strings.TrimSuffix(allowed, "/") == origin {
+			return true
+		}
+	}
+	return false
+}
+
+// execSession adapts a WebSocket to the exec streams: it writes the terminal output,
+// queues the resize requests and tracks the last activity in either direction.
+type execSession struct {
+	conn     *websocket.Conn
+	writeMu  sync.Mutex
+	resize   chan remotecommand.TerminalSize
+	done     <-chan struct{}
+	activity atomic.Int64 // Unix nanoseconds
+}
+
+func (s *execSession) touch() {
+	s.activity.Store(time.Now().UnixNano())
+}
+
+func (s *execSession) lastActivity() time.Time {
+	return time.Unix(0, s.activity.Load())
+}
+
+// Write sends terminal output to the client as a binary message.
+func (s *execSession) Write(p []byte) (int, error) {
+	s.touch()
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	if err := s.conn.WriteMessage(websocket.BinaryMessage, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Next returns the next terminal size requested by the client, or nil once the session is over.
+func (s *execSession) Next() *remotecommand.TerminalSize {
+	select {
+	case size := <-s.resize:
+		return &size
+	case <-s.done:
+		return nil
+	}
+}
+
+// readClient copies the client input to stdin until the WebSocket is closed. Binary messages
+// are raw input; text messages are execMessage values.
+func (s *execSession) readClient(stdin io.Writer) {
+	for {
+		msgType, data, err := s.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		s.touch()
+		if msgType == websocket.BinaryMessage {
+			if _, err := stdin.Write(data); err != nil {
+				return
+			}
+			continue
+		}
+
+		var msg execMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			continue
+		}
+		switch msg.Type {
+		case "stdin":
+			if _, err := io.WriteString(stdin, msg.Data); err != nil {
+				return
+			}
+		case "resize":
+			if msg.Cols == 0 || msg.Rows == 0 {
+				continue
+			}
+			select {
+			case s.resize <- remotecommand.TerminalSize{Width: msg.Cols, Height: msg.Rows}:
+			case <-s.done:
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"helm.sh/helm/v3/pkg/release"
+
+	"app-store-api/pkg/audit"
+	"app-store-api/pkg/expiry"
+)
+
+// extendRequest is the body of an extend request: a duration added to the current expiry,
+// or a new expiry.
+type extendRequest struct {
+	TTL       string     `json:"ttl,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// releaseDeadline returns the expiry given by a TTL or an absolute time, counted from now,
+// and checks it against EXPIRY_MAX_TTL.
+func (h *APIHandler) releaseDeadline(ttl string, expiresAt *time.Time, now time.Time) (time.Time, error) {
+	deadline, err := expiry.Deadline(ttl, expiresAt, now)
+	if err != nil {
+		return deadline, err
+	}
+	if h.config.ExpiryMaxTTL > 0 && deadline.Sub(now) > h.config.ExpiryMaxTTL {
+		return deadline, fmt.Errorf("the expiry may be at most %v away", h.config.ExpiryMaxTTL)
+	}
+	return deadline, nil
+}
+
+// wantsExpiry reports whether an install asked for an expiry.
+func wantsExpiry(ttl string, expiresAt *time.Time) bool {
+	return ttl != "" || expiresAt != nil
+}
+
+// setReleaseExpiry stores the expiry of a freshly installed release. The release stays installed
+// when the expiry cannot be stored, so the caller reports the error alongside the release.
+func (h *APIHandler) setReleaseExpiry(rel *release.Release, ttl string, expiresAt *time.Time, setBy string) (*expiry.Record, error) {
+	now := time.Now()
+	deadline, err := h.releaseDeadline(ttl, expiresAt, now)
+	if err != nil {
+		log.Printf("Warning: Release '%s' has no expiry: %v", rel.Name, err)
+		return nil, fmt.Errorf("release '%s' was installed without its expiry: %w", rel.Name, err)
+	}
+	rec := &expiry.Record{Release: rel.Name, ExpiresAt: deadline, SetBy: setBy, SetAt: now.UTC(), Revision: rel.Version}
+	if rel.Info != nil {
+		rec.FirstDeployed = rel.Info.FirstDeployed.Time.UTC()
+	}
+	if err := h.expiryStore.Save(rec); err != nil {
+		log.Printf("Warning: Release '%s' has no expiry: %v", rel.Name, err)
+		return nil, fmt.Errorf("release '%s' was installed without its expiry: %w", rel.Name, err)
+	}
+	log.Printf("Release '%s' expires at %s", rel.Name, deadline.Format(time.RFC3339))
+	return rec, nil
+}
+
+// ExtendReleaseHandler postpones the expiry of an ephemeral release. Body: {"ttl": "2h"} adds
+// to the current expiry (or to now, if it is closer), {"expires_at": "..."} replaces it.
+func (h *APIHandler) ExtendReleaseHandler(c *gin.Context) {
+	releaseName := c.Param("releaseName")
+	var req extendRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid request body: %v", err)})
+		return
+	}
+
+	rec, err := h.expiryStore.Get(releaseName)
+	if errors.Is(err, expiry.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Release '%s' has no expiry.", releaseName)})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	now := time.Now()
+	from := now
+	if req.TTL != "" && rec.ExpiresAt.After(now) {
+		from = rec.ExpiresAt
+	}
+	deadline, err := expiry.Deadline(req.TTL, req.ExpiresAt, from)
+	if err == nil && h.config.ExpiryMaxTTL > 0 && deadline.Sub(now) > h.config.ExpiryMaxTTL {
+		err = fmt.Errorf("the expiry may be at most %v away", h.config.ExpiryMaxTTL)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	previous := rec.ExpiresAt
+	rec.ExpiresAt = deadline
+	rec.SetBy = identityFrom(c).User
+	rec.SetAt = now.UTC()
+	rec.WarnedAt = nil
+	rec.Extended++
+	err = h.expiryStore.Save(rec)
+	h.recordAudit(c, audit.Entry{Action: audit.ActionExtend, Release: releaseName}, now, err)
+	if errors.Is(err, expiry.ErrConflict) {
+		c.JSON(http.StatusConflict, gin.H{"error": "The expiry was modified concurrently, retry."})
+		return
+	} else if errors.Is(err, expiry.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Release '%s' has expired.", releaseName)})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	log.Printf("Release '%s' extended by '%s' until %s", releaseName, rec.SetBy, deadline.Format(time.RFC3339))
+	c.JSON(http.StatusOK, gin.H{
+		"message":             fmt.Sprintf("Release '%s' now expires at %s.", releaseName, deadline.Format(time.RFC3339)),
+		"previous_expires_at": previous,
+		"expiry":              rec,
+	})
+}
@@ -0,0 +1,135 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"app-store-api/pkg/appcatalog"
+	"app-store-api/pkg/helm"
+	"app-store-api/pkg/values"
+)
+
+// errExposureValue rejects values setting the host of an exposed release, which would let
+// a release publish itself under another host.
+var errExposureValue = errors.New("value is set by the app store")
+
+// exposure is where a release is published on the ingress base domain.
+type exposure struct {
+	host        string
+	url         string
+	tlsSecret   string // Set when cert-manager issues the certificate
+	annotations map[string]string
+}
+
+// exposureFor returns the exposure of a release, or nil when exposure is disabled or
+// the chart does not declare how to expose it.
+func (h *APIHandler) exposureFor(chartMeta *appcatalog.ChartMeta, releaseName string) *exposure {
+	if h.config.IngressBaseDomain == "" || chartMeta.Expose == nil {
+		return nil
+	}
+	labels := []string{releaseName}
+	if h.config.IngressTenant != "" {
+		labels = append(labels, h.config.IngressTenant)
+	}
+	host := strings.Join(append(labels, h.config.IngressBaseDomain), ".")
+
+	exp := &exposure{host: host, url: "http://" + host, annotations: map[string]string{}}
+	if h.config.IngressTLS {
+		exp.url = "https://" + host
+		if h.config.IngressClusterIssuer != "" {
+			exp.annotations[helm.ClusterIssuerAnnotation] = h.config.IngressClusterIssuer
+			exp.tlsSecret = releaseName + "-tls"
+		}
+	}
+	return exp
+}
+
+// valueSetting is a value to store at a chart value path. Locked values may not be set
+// by the request.
+type valueSetting struct {
+	path   string
+	value  interface{}
+	locked bool
+}
+
+// applyIngressValues returns a copy of vals with the chart ingress settings pointing at the
+// exposure host. Setting the host paths in vals is an error; other paths already set are left
+// alone, except annotations which are merged.
+func (h *APIHandler) applyIngressValues(vals map[string]interface{}, iv *appcatalog.IngressValues, exp *exposure) (map[string]interface{}, error) {
+	out := values.Copy(vals)
+	if out == nil {
+		out = make(map[string]interface{})
+	}
+
+	settings := []valueSetting{
+		{iv.Enabled, true, false},
+		{iv.Host, exp.host, true},
+		{iv.Hosts, []interface{}{map[string]interface{}{
+			"host":  exp.host,
+			"paths": []interface{}{map[string]interface{}{"path": "/", "pathType": "Prefix"}},
+		}}, true},
+	}
+	if h.config.IngressClass != "" {
+		settings = append(settings, valueSetting{iv.ClassName, h.config.IngressClass, false})
+	}
+	if h.config.IngressTLS {
+		tls := map[string]interface{}{"hosts": []interface{}{exp.host}}
+		if exp.tlsSecret != "" {
+			tls["secretName"] = exp.tlsSecret
+		}
+		settings = append(settings, valueSetting{iv.TLS, true, false}, valueSetting{iv.TLSHosts, []interface{}{tls}, true})
+	} else {
+		// Without TLS nothing is set there, but the TLS hosts name hosts all the same
+		settings = append(settings, valueSetting{iv.TLSHosts, nil, true})
+	}
+
+	for _, s := range settings {
+		if s.path == "" {
+			continue
+		}
+		if _, set := values.Get(out, s.path); set {
+			if s.locked {
+				return nil, fmt.Errorf("%w: '%s'", errExposureValue, s.path)
+			}
+			continue
+		}
+		if s.value == nil {
+			continue
+		}
+		if !values.Set(out, s.path, s.value) {
+			return nil, fmt.Errorf("cannot set ingress value at path '%s'", s.path)
+		}
+	}
+
+	if iv.Annotations != "" && len(exp.annotations) > 0 {
+		annotations := make(map[string]interface{})
+		if existing, ok := values.Get(out, iv.Annotations); ok {
+			if m, isMap := existing.(map[string]interface{}); isMap {
+				annotations = m
+			}
+		}
+		for k, v := range exp.annotations {
+			if _, set := annotations[k]; !set {
+				annotations[k] = v
+			}
+		}
+		if !values.Set(out, iv.Annotations, annotations) {
+			return nil, fmt.Errorf("cannot set ingress annotations at path '%s'", iv.Annotations)
+		}
+	}
+	return out, nil
+}
+
+// createFallbackIngress creates the Ingress of a release whose chart does not manage one.
+func (h *APIHandler) createFallbackIngress(chartMeta *appcatalog.ChartMeta, releaseName string, exp *exposure) error {
+	return h.helmClient.CreateReleaseIngress(releaseName, helm.IngressSpec{
+		Host:          exp.host,
+		ClassName:     h.config.IngressClass,
+		TLS:           h.config.IngressTLS,
+		TLSSecretName: exp.tlsSecret,
+		Annotations:   exp.annotations,
+		Service:       strings.ReplaceAll(chartMeta.Expose.Service, "{release}", releaseName),
+		Port:          chartMeta.Expose.Port,
+	})
+}
@@ -2,33 +2,71 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 
 	"app-store-api/pkg/appcatalog"
+	"app-store-api/pkg/approvals"
+	"app-store-api/pkg/audit"
+	"app-store-api/pkg/auth"
+	"app-store-api/pkg/config"
+	"app-store-api/pkg/expiry"
 	"app-store-api/pkg/helm"
 	"app-store-api/pkg/metrics"
+	"app-store-api/pkg/schedules"
+	"app-store-api/pkg/values"
 )
 
 // APIHandler holds dependencies for API handlers.
 type APIHandler struct {
+	config         *config.AppConfig
 	catalogService *appcatalog.Service
 	helmClient     *helm.HelmClient
 	metricsService *metrics.Service
+	auditService   *audit.Service
+	authorizer     *auth.Authorizer
+	approvalStore  *approvals.Store
+	scheduleStore  *schedules.Store
+	expiryStore    *expiry.Store
+	proxyTargets   *proxyTargetCache
+	syncHistory    *syncHistory
+}
+
+// Dependencies groups the services the API handlers are built from.
+type Dependencies struct {
+	Config        *config.AppConfig
+	Catalog       *appcatalog.Service
+	Helm          *helm.HelmClient
+	Metrics       *metrics.Service
+	Audit         *audit.Service
+	Authorizer    *auth.Authorizer
+	ApprovalStore *approvals.Store
+	ScheduleStore *schedules.Store
+	ExpiryStore   *expiry.Store
 }
 
 // NewAPIHandler creates a new APIHandler.
-func NewAPIHandler(cs *appcatalog.Service, hc *helm.HelmClient, ms *metrics.Service) *APIHandler {
+func NewAPIHandler(deps Dependencies) *APIHandler {
 	return &APIHandler{
-		catalogService: cs,
-		helmClient:     hc,
-		metricsService: ms,
+		config:         deps.Config,
+		catalogService: deps.Catalog,
+		helmClient:     deps.Helm,
+		metricsService: deps.Metrics,
+		auditService:   deps.Audit,
+		authorizer:     deps.Authorizer,
+		approvalStore:  deps.ApprovalStore,
+		scheduleStore:  deps.ScheduleStore,
+		expiryStore:    deps.ExpiryStore,
+		proxyTargets:   newProxyTargetCache(),
+		syncHistory:    &syncHistory{},
 	}
 }
 
@@ -42,14 +80,9 @@ func (h *APIHandler) GetChartsHandler(c *gin.Context) {
 func (h *APIHandler) InstallChartHandler(c *gin.Context) {
 	chartSimpleName := c.Param("chartName")
 
-	var req helm.InstallRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		if err.Error() != "EOF" {
-			log.Printf("Warning: Could not bind JSON for install request for chart '%s': %v", chartSimpleName, err)
-		}
-		if req.Values == nil {
-			req.Values = make(map[string]interface{})
-		}
+	req, ok := h.bindInstallRequest(c)
+	if !ok {
+		return
 	}
 
 	chartMeta, err := h.catalogService.GetChartByName(chartSimpleName)
@@ -58,28 +91,96 @@ func (h *APIHandler) InstallChartHandler(c *gin.Context) {
 		return
 	}
 
-	// Convert appcatalog.ChartMeta to helm.ChartDefinition for InstallChart
-	helmChartDef := helm.ChartDefinition{
-		Name:    chartMeta.Name,
-		Chart:   chartMeta.Chart,
-		Version: chartMeta.Version,
-		RepoURL: chartMeta.RepoURL,
+	if !h.applyStorageClass(c, chartMeta, &req) {
+		return
+	}
+	releaseName := req.ReleaseName
+	if releaseName == "" {
+		releaseName = chartMeta.Name
+	}
+	if wantsExpiry(req.TTL, req.ExpiresAt) {
+		if _, err := h.releaseDeadline(req.TTL, req.ExpiresAt, time.Now()); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	// Checked now so requests waiting for approval are not rejected only once approved
+	if exp := h.exposureFor(chartMeta, releaseName); exp != nil && chartMeta.Expose.IngressValues != nil {
+		if _, err := h.applyIngressValues(req.Values, chartMeta.Expose.IngressValues, exp); err != nil {
+			c.JSON(installErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	// Restricted charts installed by non-admins wait for an admin decision
+	if id := identityFrom(c); chartMeta.RequiresApproval && !h.authorizer.IsAdmin(id) {
+		approvalReq := &approvals.Request{
+			Chart:         chartMeta.Name,
+			ReleaseName:   releaseName,
+			Values:        req.Values,
+			Justification: req.Justification,
+			TTL:           req.TTL,
+			ExpiresAt:     req.ExpiresAt,
+			RequestedBy:   id.User,
+		}
+		if err := h.approvalStore.Create(approvalReq); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("Install of chart '%s' as release '%s' by '%s' is pending approval (request %s)", chartMeta.Name, releaseName, id.User, approvalReq.ID)
+		c.JSON(http.StatusAccepted, gin.H{
+			"message":  fmt.Sprintf("Chart '%s' requires approval, install request '%s' is pending.", chartMeta.Name, approvalReq.ID),
+			"approval": h.redactApproval(*approvalReq),
+		})
+		return
 	}
 
-	release, err := h.helmClient.InstallChart(helmChartDef, req.ReleaseName, req.Values) // PASSING CONVERTED TYPE
+	release, err := h.installRelease(c, chartMeta, releaseName, req.Values, installActor{})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(installErrorStatus(err), installErrorBody(err))
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{
+	summary := h.releaseSummary(chartMeta, release)
+	response := gin.H{
 		"message": fmt.Sprintf("Chart '%s' installed successfully as release '%s'", chartMeta.Chart, release.Name),
-		"release": gin.H{
-			"name":      release.Name,
-			"namespace": release.Namespace,
-			"version":   release.Version,
-			"status":    release.Info.Status.String(),
-		},
-	})
+		"release": summary,
+	}
+	if wantsExpiry(req.TTL, req.ExpiresAt) {
+		if rec, err := h.setReleaseExpiry(release, req.TTL, req.ExpiresAt, identityFrom(c).User); err != nil {
+			// The release is installed but permanent until an expiry is set
+			response["expiry_error"] = err.Error()
+		} else {
+			summary["expires_at"] = rec.ExpiresAt
+		}
+	}
+	if snapshot := h.metricsService.LatestSnapshot(); snapshot != nil && len(snapshot.Warnings) > 0 {
+		response["cluster_warnings"] = snapshot.Warnings
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// bindInstallRequest decodes the body of an install or preflight request and validates its values.
+// It writes the error response and returns false when the request is rejected.
+func (h *APIHandler) bindInstallRequest(c *gin.Context) (helm.InstallRequest, bool) {
+	var req helm.InstallRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("Request body exceeds %d bytes.", maxBytesErr.Limit)})
+			return req, false
+		}
+		if err.Error() != "EOF" {
+			log.Printf("Warning: Could not bind JSON for install request for chart '%s': %v", c.Param("chartName"), err)
+		}
+		if req.Values == nil {
+			req.Values = make(map[string]interface{})
+		}
+	}
+	if depth := values.Depth(req.Values); h.config.MaxValuesDepth > 0 && depth > h.config.MaxValuesDepth {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Values are nested %d levels deep, the maximum is %d.", depth, h.config.MaxValuesDepth)})
+		return req, false
+	}
+	return req, true
 }
 
 // ListReleasesHandler handles requests to list installed releases.
@@ -89,6 +190,19 @@ func (h *APIHandler) ListReleasesHandler(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	if expiries, err := h.expiryStore.List(); err != nil {
+		log.Printf("Warning: Could not list release expiries: %v", err)
+	} else {
+		expiresAt := make(map[string]time.Time, len(expiries))
+		for _, rec := range expiries {
+			expiresAt[rec.Release] = rec.ExpiresAt
+		}
+		for i := range releases {
+			if t, ok := expiresAt[releases[i].Name]; ok {
+				releases[i].ExpiresAt = &t
+			}
+		}
+	}
 	c.JSON(http.StatusOK, releases)
 }
 
@@ -104,13 +218,22 @@ func (h *APIHandler) GetReleaseStatusHandler(c *gin.Context) {
 		}
 		return
 	}
-	c.JSON(http.StatusOK, status)
+	c.JSON(http.StatusOK, h.redactReleaseStatus(status))
 }
 
 // UninstallReleaseHandler handles requests to uninstall a release.
+// Query parameters: keep_history, delete_pvcs and wait (booleans) and timeout (a duration such as "10m").
 func (h *APIHandler) UninstallReleaseHandler(c *gin.Context) {
 	releaseName := c.Param("releaseName")
-	_, err := h.helmClient.UninstallRelease(releaseName)
+	opts, err := uninstallOptionsFrom(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	startTime := time.Now()
+	report, err := h.helmClient.UninstallRelease(releaseName, opts)
+	h.recordAudit(c, audit.Entry{Action: audit.ActionUninstall, Release: releaseName}, startTime, err)
 	if err != nil {
 		if strings.Contains(err.Error(), "not found") {
 			c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Release '%s' not found.", releaseName)})
@@ -119,69 +242,131 @@ func (h *APIHandler) UninstallReleaseHandler(c *gin.Context) {
 		}
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"message": fmt.Sprintf("Release '%s' uninstalled successfully.", releaseName)})
+	h.ForgetRelease(releaseName)
+	if !opts.KeepHistory {
+		if err := h.scheduleStore.Delete(releaseName); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	}
+	// Even with its history kept, an uninstalled release has nothing left to expire
+	if err := h.expiryStore.Delete(releaseName); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message": fmt.Sprintf("Release '%s' uninstalled successfully.", releaseName),
+		"report":  report,
+	})
+}
+
+// uninstallOptionsFrom reads the uninstall options from the query string.
+func uninstallOptionsFrom(c *gin.Context) (helm.UninstallOptions, error) {
+	var opts helm.UninstallOptions
+	for name, target := range map[string]*bool{
+		"keep_history": &opts.KeepHistory,
+		"delete_pvcs":  &opts.DeletePVCs,
+		"wait":         &opts.Wait,
+	} {
+		if v := c.Query(name); v != "" {
+			parsed, err := strconv.ParseBool(v)
+			if err != nil {
+				return opts, fmt.Errorf("invalid '%s', expected true or false", name)
+			}
+			*target = parsed
+		}
+	}
+	if v := c.Query("timeout"); v != "" {
+		timeout, err := time.ParseDuration(v)
+		if err != nil || timeout <= 0 {
+			return opts, fmt.Errorf("invalid 'timeout', expected a duration such as '10m'")
+		}
+		opts.Timeout = timeout
+	}
+	return opts, nil
+}
+
+// ListOrphansHandler lists the objects left behind by releases that no longer exist.
+func (h *APIHandler) ListOrphansHandler(c *gin.Context) {
+	orphans, err := h.helmClient.FindOrphans()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, orphans)
+}
+
+// GetReleaseMetricsHandler returns the CPU/memory usage of a release's pods
+// compared with their requests and limits.
+func (h *APIHandler) GetReleaseMetricsHandler(c *gin.Context) {
+	releaseName := c.Param("releaseName")
+	releaseMetrics, found, err := h.metricsService.GetReleaseMetricsByName(releaseName)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("No running pods found for release '%s'.", releaseName)})
+		return
+	}
+	c.JSON(http.StatusOK, releaseMetrics)
 }
 
 // MetricsStreamHandler establishes an SSE connection to stream cluster metrics.
+// Snapshots come from the shared collector of the metrics service.
+// Per-release metrics are only included with ?releases=true.
 func (h *APIHandler) MetricsStreamHandler(c *gin.Context) {
+	if h.metricsService == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Metrics service not available."})
+		return
+	}
+	includeReleases := c.Query("releases") == "true"
+
 	log.Println("Client connected for metrics stream")
 	c.Writer.Header().Set("Content-Type", "text/event-stream")
 	c.Writer.Header().Set("Cache-Control", "no-cache")
 	c.Writer.Header().Set("Connection", "keep-alive")
-	c.Writer.Header().Set("Access-Control-Allow-Origin", "*") // Or your specific frontend origin
 
-	messageChan := make(chan string)
+	sub := h.metricsService.Subscribe()
 	defer func() {
-		close(messageChan)
+		h.metricsService.Unsubscribe(sub)
 		log.Println("Client disconnected from metrics stream")
 	}()
 
-	// Goroutine to periodically fetch and send metrics
-	go func() {
-		ticker := time.NewTicker(200 * time.Millisecond)
-		defer ticker.Stop()
-
-		for {
-			select {
-			case <-c.Request.Context().Done(): // Client disconnected
-				return
-			case <-ticker.C:
-				if h.metricsService == nil {
-					log.Println("Metrics service not available, cannot send metrics.")
-					// Optionally send an error event to client
-					// fmt.Fprintf(c.Writer, "event: error\ndata: Metrics service unavailable\n\n")
-					// c.Writer.Flush()
-					continue // Or break/return if we don't want to keep trying
-				}
-				clusterMetrics, err := h.metricsService.GetClusterMetricsSnapshot()
-				if err != nil {
-					log.Printf("Error getting cluster metrics snapshot: %v", err)
-					// Optionally send an error event to client
-					// fmt.Fprintf(c.Writer, "event: error\ndata: %s\n\n", err.Error())
-					// c.Writer.Flush()
-					continue
-				}
-
-				jsonData, err := json.Marshal(clusterMetrics)
-				if err != nil {
-					log.Printf("Error marshalling metrics to JSON: %v", err)
-					continue
-				}
-				messageChan <- string(jsonData)
-			}
-		}
-	}()
-
-	// Keep the connection open and send messages
-	// Use io.WriteString for simpler SSE formatting
 	c.Stream(func(w io.Writer) bool {
-		if msg, ok := <-messageChan; ok {
-			_, err := fmt.Fprintf(w, "data: %s\n\n", msg)
-			if err != nil {
+		select {
+		case <-c.Request.Context().Done(): // Client disconnected
+			return false
+		case snapshot, ok := <-sub.C:
+			if !ok {
 				return false
-			} // SSE format: "data: <json_string>\n\n"
-			return true // Keep connection open
+			}
+			if !includeReleases && snapshot.Releases != nil {
+				// Snapshots are shared between subscribers, so strip the section on a copy
+				withoutReleases := *snapshot
+				withoutReleases.Releases = nil
+				snapshot = &withoutReleases
+			}
+			jsonData, err := json.Marshal(snapshot)
+			if err != nil {
+				log.Printf("Error marshalling metrics to JSON: %v", err)
+				return true
+			}
+			_, err = fmt.Fprintf(w, "data: %s\n\n", jsonData) // SSE format: "data: <json_string>\n\n"
+			return err == nil
 		}
-		return false // Close connection if channel is closed
 	})
 }
+
+// GetReleaseResourcesHandler returns the objects of a release with their live status,
+// its pods and the latest events about them.
+func (h *APIHandler) GetReleaseResourcesHandler(c *gin.Context) {
+	releaseName := c.Param("releaseName")
+	resources, err := h.helmClient.GetReleaseResources(releaseName)
+	if errors.Is(err, helm.ErrReleaseNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Release '%s' not found.", releaseName)})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, resources)
+}
@@ -0,0 +1,269 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"helm.sh/helm/v3/pkg/release"
+
+	"app-store-api/pkg/appcatalog"
+	"app-store-api/pkg/audit"
+	"app-store-api/pkg/helm"
+	"app-store-api/pkg/preflight"
+	"app-store-api/pkg/values"
+)
+
+// installActor overrides who an install is attributed to.
+// The zero value attributes it to the caller of the current request.
+type installActor struct {
+	User       string // Original requester, for approved installs
+	ApprovedBy string
+}
+
+// deployOptions changes how deployRelease deploys a release.
+type deployOptions struct {
+	Upgrade bool              // Upgrade the existing release instead of installing it
+	Labels  map[string]string // Stored on the Helm release record
+}
+
+// installRelease installs a catalog chart and records the operation in the audit log.
+func (h *APIHandler) installRelease(c *gin.Context, chartMeta *appcatalog.ChartMeta, releaseName string, vals map[string]interface{}, actor installActor) (*release.Release, error) {
+	return h.deployRelease(c, chartMeta, releaseName, vals, actor, deployOptions{})
+}
+
+// deployRelease installs or upgrades a release of a catalog chart and records the operation
+// in the audit log. c is nil for deployments not made on behalf of a request; actor.User
+// must then be set. Upgrades skip the preflight, which would count the release twice.
+func (h *APIHandler) deployRelease(c *gin.Context, chartMeta *appcatalog.ChartMeta, releaseName string, vals map[string]interface{}, actor installActor, opts deployOptions) (rel *release.Release, err error) {
+	// Convert appcatalog.ChartMeta to helm.ChartDefinition for InstallChart
+	helmChartDef := helm.ChartDefinition{
+		Name:    chartMeta.Name,
+		Chart:   chartMeta.Chart,
+		Version: chartMeta.Version,
+		RepoURL: chartMeta.RepoURL,
+	}
+
+	requestedBy := actor.User
+	if requestedBy == "" {
+		requestedBy = identityFrom(c).User
+	}
+	labels := make(map[string]string, len(opts.Labels)+1)
+	for k, v := range opts.Labels {
+		labels[k] = v
+	}
+	if !opts.Upgrade {
+		// Upgrades keep the labels of the release, so its owner stays the installing user
+		labels[helm.OwnerLabel] = helm.OwnerLabelValue(requestedBy)
+	}
+
+	startTime := time.Now()
+	entry := audit.Entry{
+		Action:     audit.ActionInstall,
+		Actor:      actor.User,
+		ApprovedBy: actor.ApprovedBy,
+		Chart:      chartMeta.Chart,
+		Release:    releaseName,
+		ValuesHash: audit.HashValues(vals, chartMeta.SecretPaths()),
+	}
+	if opts.Upgrade {
+		entry.Action = audit.ActionUpgrade
+	}
+
+	if !opts.Upgrade {
+		// Checked before anything is stored for the release name, so an install under the name
+		// of an installed release cannot touch its credentials or NodePorts
+		if _, err := h.helmClient.CheckInstallable(releaseName); err != nil {
+			h.recordAudit(c, entry, startTime, err)
+			return nil, err
+		}
+	}
+
+	exp := h.exposureFor(chartMeta, releaseName)
+	if exp != nil && chartMeta.Expose.IngressValues != nil {
+		exposedVals, err := h.applyIngressValues(vals, chartMeta.Expose.IngressValues, exp)
+		if err != nil {
+			h.recordAudit(c, entry, startTime, err)
+			return nil, err
+		}
+		vals = exposedVals
+	}
+
+	vals, freshPorts, err := h.applyNodePorts(chartMeta, releaseName, vals)
+	if err != nil {
+		h.recordAudit(c, entry, startTime, err)
+		return nil, err
+	}
+	defer func() {
+		// Ports reserved by a deployment that did not happen would stay taken until an uninstall
+		if err != nil {
+			h.helmClient.FreeNodePorts(releaseName, freshPorts)
+		}
+	}()
+
+	if h.config.EnforcePreflight && !opts.Upgrade {
+		if err := h.enforcePreflight(chartMeta, releaseName, vals); err != nil {
+			h.recordAudit(c, entry, startTime, err)
+			return nil, err
+		}
+	}
+
+	credentialSpecs := make([]helm.CredentialSpec, len(chartMeta.GeneratedSecrets))
+	for i, gs := range chartMeta.GeneratedSecrets {
+		credentialSpecs[i] = helm.CredentialSpec{Path: gs.Path, Length: gs.Length, Charset: gs.Charset}
+	}
+	installVals, err := h.helmClient.GenerateCredentials(releaseName, credentialSpecs, vals, requestedBy, opts.Upgrade)
+	if err != nil {
+		h.recordAudit(c, entry, startTime, err)
+		return nil, err
+	}
+
+	// Secret references are resolved last so the plaintext only ever reaches Helm
+	resolvedVals, err := h.helmClient.ResolveSecretRefs(releaseName, installVals)
+	if err != nil {
+		h.recordAudit(c, entry, startTime, err)
+		return nil, err
+	}
+
+	if opts.Upgrade {
+		rel, err = h.helmClient.UpgradeRelease(helmChartDef, releaseName, resolvedVals, labels)
+	} else {
+		rel, err = h.helmClient.InstallChart(helmChartDef, releaseName, resolvedVals, labels)
+	}
+	h.recordAudit(c, entry, startTime, err)
+	if err == nil && !opts.Upgrade {
+		h.ForgetRelease(releaseName)
+		// A record left by an earlier install under this name must not reap this one; the
+		// caller sets a new expiry when one is asked for
+		if errExpiry := h.expiryStore.Delete(releaseName); errExpiry != nil {
+			log.Printf("Warning: %v", errExpiry)
+		}
+	}
+	if err == nil && exp != nil && chartMeta.Expose.IngressValues == nil {
+		// The release is installed either way; without the Ingress it stays reachable through its services
+		if errIngress := h.createFallbackIngress(chartMeta, releaseName, exp); errIngress != nil {
+			log.Printf("Warning: Could not expose release '%s': %v", releaseName, errIngress)
+		}
+	}
+	return rel, err
+}
+
+// applyStorageClass injects the storage class chosen in req into the value paths declared
+// by the chart. It writes the error response and returns false when the choice is rejected.
+func (h *APIHandler) applyStorageClass(c *gin.Context, chartMeta *appcatalog.ChartMeta, req *helm.InstallRequest) bool {
+	vals, err := h.withStorageClass(chartMeta, req.StorageClass, req.Values)
+	var invalid storageClassError
+	if errors.As(err, &invalid) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return false
+	}
+	req.Values = vals
+	return true
+}
+
+// storageClassError rejects the storage class chosen for an install.
+type storageClassError string
+
+func (e storageClassError) Error() string { return string(e) }
+
+// withStorageClass returns a copy of vals with storageClass set at the storage class paths
+// of the chart, or vals itself when no storage class is chosen.
+func (h *APIHandler) withStorageClass(chartMeta *appcatalog.ChartMeta, storageClass string, vals map[string]interface{}) (map[string]interface{}, error) {
+	if storageClass == "" {
+		return vals, nil
+	}
+	if len(chartMeta.StorageClassPaths) == 0 {
+		return nil, storageClassError(fmt.Sprintf("Chart '%s' does not support choosing a storage class.", chartMeta.Name))
+	}
+	exists, err := h.metricsService.StorageClassExists(storageClass)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, storageClassError(fmt.Sprintf("Storage class '%s' does not exist.", storageClass))
+	}
+
+	out := values.Copy(vals)
+	if out == nil {
+		out = make(map[string]interface{})
+	}
+	for _, p := range chartMeta.StorageClassPaths {
+		if !values.Set(out, p, storageClass) {
+			return nil, storageClassError(fmt.Sprintf("Cannot set storage class at '%s': the values hold a non-map there.", p))
+		}
+	}
+	return out, nil
+}
+
+// applyNodePorts returns a copy of vals with a reserved NodePort injected at each node port
+// path of the chart that the request does not set, and the paths whose port was newly reserved.
+func (h *APIHandler) applyNodePorts(chartMeta *appcatalog.ChartMeta, releaseName string, vals map[string]interface{}) (map[string]interface{}, []string, error) {
+	var paths []string
+	for _, p := range chartMeta.NodePortPaths {
+		if _, set := values.Get(vals, p); !set {
+			paths = append(paths, p)
+		}
+	}
+	if len(paths) == 0 {
+		return vals, nil, nil
+	}
+	ports, fresh, err := h.helmClient.AllocateNodePorts(releaseName, paths)
+	if err != nil {
+		return nil, nil, err
+	}
+	out := values.Copy(vals)
+	if out == nil {
+		out = make(map[string]interface{})
+	}
+	for p, port := range ports {
+		if !values.Set(out, p, port) {
+			h.helmClient.FreeNodePorts(releaseName, fresh)
+			return nil, nil, fmt.Errorf("cannot inject NodePort at value path '%s'", p)
+		}
+	}
+	return out, fresh, nil
+}
+
+// installErrorStatus maps an installRelease error to an HTTP status code.
+func installErrorStatus(err error) int {
+	if errors.Is(err, helm.ErrSecretRef) || errors.Is(err, errExposureValue) {
+		return http.StatusBadRequest
+	}
+	if errors.Is(err, preflight.ErrWontSchedule) || errors.Is(err, helm.ErrNoFreeNodePort) ||
+		errors.Is(err, helm.ErrReleaseExists) || errors.Is(err, helm.ErrCredentialsOwned) {
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+// installErrorBody is the response body of a failed install, with the preflight report
+// when the install was blocked by it.
+func installErrorBody(err error) gin.H {
+	body := gin.H{"error": err.Error()}
+	var blocked *preflightBlockedError
+	if errors.As(err, &blocked) {
+		body["preflight"] = blocked.report
+	}
+	return body
+}
+
+// releaseSummary is the short description of a release returned after an install,
+// with the URL it is published at when exposure is enabled for its chart.
+func (h *APIHandler) releaseSummary(chartMeta *appcatalog.ChartMeta, rel *release.Release) gin.H {
+	summary := gin.H{
+		"name":      rel.Name,
+		"namespace": rel.Namespace,
+		"version":   rel.Version,
+		"status":    rel.Info.Status.String(),
+	}
+	if exp := h.exposureFor(chartMeta, rel.Name); exp != nil {
+		summary["url"] = exp.url
+	}
+	return summary
+}
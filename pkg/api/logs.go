@@ -0,0 +1,123 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"app-store-api/pkg/helm"
+)
+
+// defaultLogTailLines is the number of lines returned when neither tailLines nor sinceSeconds is set.
+const defaultLogTailLines = 100
+
+// GetReleaseLogsHandler streams the container logs of a release. Clients accepting
+// text/event-stream receive one SSE message per line (JSON with pod, container and line);
+// others receive chunked plain text prefixed with "[pod/container]".
+// Query parameters: pod, container, follow, tailLines, sinceSeconds and previous.
+// Only the user who installed the release and admins can read them.
+func (h *APIHandler) GetReleaseLogsHandler(c *gin.Context) {
+	releaseName := c.Param("releaseName")
+	opts, err := logOptionsFrom(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rel, err := h.helmClient.GetRelease(releaseName)
+	if errors.Is(err, helm.ErrReleaseNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Release '%s' not found.", releaseName)})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !h.ownsRelease(c, rel.Labels[helm.OwnerLabel]) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the user who installed the release or an admin can read its logs."})
+		return
+	}
+
+	lines := make(chan helm.LogLine, 64)
+	if err := h.helmClient.StreamReleaseLogs(c.Request.Context(), releaseName, opts, lines); err != nil {
+		if errors.Is(err, helm.ErrNoLogTargets) {
+			c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("No pod container of release '%s' matches.", releaseName)})
+		} else {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	sse := strings.Contains(c.GetHeader("Accept"), "text/event-stream")
+	if sse {
+		c.Writer.Header().Set("Content-Type", "text/event-stream")
+		c.Writer.Header().Set("Connection", "keep-alive")
+	} else {
+		c.Writer.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	}
+	c.Writer.Header().Set("Cache-Control", "no-cache")
+	log.Printf("Streaming logs of release '%s' (pod %q, container %q, follow %t)", releaseName, opts.Pod, opts.Container, opts.Follow)
+
+	c.Stream(func(w io.Writer) bool {
+		line, ok := <-lines
+		if !ok {
+			return false
+		}
+		if sse {
+			jsonData, err := json.Marshal(line)
+			if err != nil {
+				return true
+			}
+			_, err = fmt.Fprintf(w, "data: %s\n\n", jsonData)
+			return err == nil
+		}
+		text := line.Line
+		if line.Error != "" {
+			text = "error: " + line.Error
+		}
+		_, err := fmt.Fprintf(w, "[%s/%s] %s\n", line.Pod, line.Container, text)
+		return err == nil
+	})
+	// Drain so the container streams can exit if the client went away
+	for range lines {
+	}
+}
+
+// logOptionsFrom reads the log options from the query string.
+func logOptionsFrom(c *gin.Context) (helm.LogOptions, error) {
+	opts := helm.LogOptions{Pod: c.Query("pod"), Container: c.Query("container")}
+	for name, target := range map[string]*bool{"follow": &opts.Follow, "previous": &opts.Previous} {
+		if v := c.Query(name); v != "" {
+			parsed, err := strconv.ParseBool(v)
+			if err != nil {
+				return opts, fmt.Errorf("invalid '%s', expected true or false", name)
+			}
+			*target = parsed
+		}
+	}
+	// The API server rejects a sinceSeconds of 0, so it must be at least 1
+	for _, param := range []struct {
+		name   string
+		min    int64
+		target **int64
+	}{{"tailLines", 0, &opts.TailLines}, {"sinceSeconds", 1, &opts.SinceSeconds}} {
+		if v := c.Query(param.name); v != "" {
+			parsed, err := strconv.ParseInt(v, 10, 64)
+			if err != nil || parsed < param.min {
+				return opts, fmt.Errorf("invalid '%s', expected an integer of at least %d", param.name, param.min)
+			}
+			*param.target = &parsed
+		}
+	}
+	if opts.TailLines == nil && opts.SinceSeconds == nil {
+		tail := int64(defaultLogTailLines)
+		opts.TailLines = &tail
+	}
+	return opts, nil
+}
@@ -0,0 +1,49 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetMetricsHistoryHandler returns the collected metrics history.
+// Query parameters: from and to (RFC 3339, default the last hour) and step
+// (a duration such as "1m", default no downsampling).
+func (h *APIHandler) GetMetricsHistoryHandler(c *gin.Context) {
+	to := time.Now()
+	from := to.Add(-time.Hour)
+	var step time.Duration
+	var err error
+
+	if v := c.Query("from"); v != "" {
+		if from, err = time.Parse(time.RFC3339, v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid 'from' timestamp: %v", err)})
+			return
+		}
+	}
+	if v := c.Query("to"); v != "" {
+		if to, err = time.Parse(time.RFC3339, v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid 'to' timestamp: %v", err)})
+			return
+		}
+	}
+	if v := c.Query("step"); v != "" {
+		if step, err = time.ParseDuration(v); err != nil || step < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'step', expected a duration such as '1m'."})
+			return
+		}
+	}
+	if to.Before(from) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "'to' must not be before 'from'."})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"from":    from,
+		"to":      to,
+		"step":    step.String(),
+		"samples": h.metricsService.GetHistory(from, to, step),
+	})
+}
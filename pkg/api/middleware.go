@@ -1,15 +1,53 @@
 package api
 
-import "github.com/gin-gonic/gin"
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"app-store-api/pkg/auth"
+	"app-store-api/pkg/config"
+	"app-store-api/pkg/helm"
+	"app-store-api/pkg/telemetry"
+)
+
+const identityContextKey = "identity"
+
+// CORSMiddleware sets up CORS headers from the configured origins, methods and headers.
+// A "*" origin allows any site but never allows credentials, as browsers reject that combination.
+func CORSMiddleware(cfg *config.AppConfig) gin.HandlerFunc {
+	allowAny := false
+	allowed := make(map[string]bool, len(cfg.CORSAllowedOrigins))
+	for _, origin := range cfg.CORSAllowedOrigins {
+		if origin == "*" {
+			allowAny = true
+		}
+		allowed[strings.TrimSuffix(origin, "/")] = true
+	}
+	methods := strings.Join(cfg.CORSAllowedMethods, ", ")
+	headers := strings.Join(cfg.CORSAllowedHeaders, ", ")
 
-// CORSMiddleware sets up CORS headers.
-// For production, origins should be restricted.
-func CORSMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*") // TODO: Restrict in production
-		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
+		origin := c.GetHeader("Origin")
+		if origin != "" {
+			h := c.Writer.Header()
+			h.Add("Vary", "Origin")
+			switch {
+			case allowAny:
+				h.Set("Access-Control-Allow-Origin", "*")
+			case allowed[origin]:
+				h.Set("Access-Control-Allow-Origin", origin)
+				if cfg.CORSAllowCredentials {
+					h.Set("Access-Control-Allow-Credentials", "true")
+				}
+			}
+			h.Set("Access-Control-Allow-Headers", headers)
+			h.Set("Access-Control-Allow-Methods", methods)
+		}
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
@@ -18,3 +56,133 @@ func CORSMiddleware() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// SecurityHeadersMiddleware adds the standard hardening headers for a JSON API.
+// Strict-Transport-Security is only sent when the server itself terminates TLS.
+func SecurityHeadersMiddleware(tlsEnabled bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		h := c.Writer.Header()
+		h.Set("X-Content-Type-Options", "nosniff")
+		h.Set("X-Frame-Options", "DENY")
+		h.Set("Referrer-Policy", "no-referrer")
+		h.Set("Content-Security-Policy", "default-src 'none'; frame-ancestors 'none'")
+		h.Set("Cross-Origin-Resource-Policy", "same-site")
+		if tlsEnabled {
+			h.Set("Strict-Transport-Security", "max-age=31536000; includeSubDomains")
+		}
+		c.Next()
+	}
+}
+
+// IdentityMiddleware reads the caller identity from the headers set by the
+// authenticating proxy and stores it in the request context. The headers are only
+// trusted on connections from trustedProxies or authenticated by a verified TLS client
+// certificate; other callers are anonymous whatever they send.
+func IdentityMiddleware(userHeader, groupsHeader string, trustedProxies []*net.IPNet) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := auth.Identity{User: auth.AnonymousUser}
+		if !fromTrustedProxy(c.Request, trustedProxies) {
+			c.Set(identityContextKey, id)
+			c.Next()
+			return
+		}
+		if user := strings.TrimSpace(c.GetHeader(userHeader)); user != "" {
+			id.User = user
+		}
+		if groups := c.GetHeader(groupsHeader); groups != "" {
+			for _, g := range strings.Split(groups, ",") {
+				if g = strings.TrimSpace(g); g != "" {
+					id.Groups = append(id.Groups, g)
+				}
+			}
+		}
+		c.Set(identityContextKey, id)
+		c.Next()
+	}
+}
+
+// fromTrustedProxy reports whether a request comes straight from a trusted proxy: its
+// connection address (never a forwarding header) is in trustedProxies, or it presented a
+// client certificate verified against TLS_CLIENT_CA_FILE.
+func fromTrustedProxy(r *http.Request, trustedProxies []*net.IPNet) bool {
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+		return true
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, n := range trustedProxies {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// identityFrom returns the identity stored by IdentityMiddleware.
+func identityFrom(c *gin.Context) auth.Identity {
+	if v, ok := c.Get(identityContextKey); ok {
+		if id, ok := v.(auth.Identity); ok {
+			return id
+		}
+	}
+	return auth.Identity{User: auth.AnonymousUser}
+}
+
+// ownsRelease reports whether the caller installed the release with the given owner label,
+// or is an admin.
+func (h *APIHandler) ownsRelease(c *gin.Context, owner string) bool {
+	id := identityFrom(c)
+	return owner == helm.OwnerLabelValue(id.User) || h.authorizer.IsAdmin(id)
+}
+
+// RequireAuthenticated rejects requests without an identity.
+func RequireAuthenticated() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if identityFrom(c).User == auth.AnonymousUser {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authentication required."})
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireAdmin rejects requests from identities that are not admins.
+func RequireAdmin(authorizer *auth.Authorizer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !authorizer.IsAdmin(identityFrom(c)) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "This action requires the admin role."})
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireOperator rejects callers that hold neither the operator nor the admin role.
+func RequireOperator(authorizer *auth.Authorizer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !authorizer.IsOperator(identityFrom(c)) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "This action requires the operator role."})
+			return
+		}
+		c.Next()
+	}
+}
+
+// PrometheusMiddleware records request counts and latencies per route template.
+func PrometheusMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		telemetry.ObserveHTTPRequest(c.Request.Method, route, strconv.Itoa(c.Writer.Status()), time.Since(start))
+	}
+}
@@ -0,0 +1,103 @@
+package api
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"app-store-api/pkg/appcatalog"
+	"app-store-api/pkg/helm"
+	"app-store-api/pkg/preflight"
+)
+
+// preflightBlockedError is returned by installRelease when an enforced preflight
+// predicts that the release pods cannot be scheduled.
+type preflightBlockedError struct {
+	report *preflight.Report
+}
+
+func (e *preflightBlockedError) Error() string {
+	msg := preflight.ErrWontSchedule.Error()
+	for _, reason := range e.report.Reasons {
+		msg += "; " + reason
+	}
+	return msg
+}
+
+func (e *preflightBlockedError) Unwrap() error {
+	return preflight.ErrWontSchedule
+}
+
+// PreflightHandler checks whether the cluster has room for a chart without installing it.
+func (h *APIHandler) PreflightHandler(c *gin.Context) {
+	req, ok := h.bindInstallRequest(c)
+	if !ok {
+		return
+	}
+	chartMeta, err := h.catalogService.GetChartByName(c.Param("chartName"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if !h.applyStorageClass(c, chartMeta, &req) {
+		return
+	}
+	releaseName := req.ReleaseName
+	if releaseName == "" {
+		releaseName = chartMeta.Name
+	}
+
+	report, err := h.runPreflight(chartMeta, releaseName, req.Values)
+	if err != nil {
+		c.JSON(installErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// runPreflight renders a chart and simulates the scheduling of its workloads.
+func (h *APIHandler) runPreflight(chartMeta *appcatalog.ChartMeta, releaseName string, vals map[string]interface{}) (*preflight.Report, error) {
+	resolvedVals, err := h.helmClient.ResolveSecretRefs(releaseName, vals)
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := h.helmClient.RenderChart(helm.ChartDefinition{
+		Name:    chartMeta.Name,
+		Chart:   chartMeta.Chart,
+		Version: chartMeta.Version,
+		RepoURL: chartMeta.RepoURL,
+	}, releaseName, resolvedVals)
+	if err != nil {
+		return nil, err
+	}
+	workloads, err := preflight.WorkloadsFromManifest(manifest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the workloads of chart '%s': %w", chartMeta.Chart, err)
+	}
+	nodes, err := h.metricsService.GetNodeCapacity()
+	if err != nil {
+		return nil, err
+	}
+	report := preflight.Check(workloads, nodes)
+	if snapshot := h.metricsService.LatestSnapshot(); snapshot != nil {
+		report.ClusterWarnings = snapshot.Warnings
+	}
+	return report, nil
+}
+
+// enforcePreflight rejects installs whose pods cannot be scheduled. A check that fails
+// does not block the install: the preflight is a guard, not a dependency.
+func (h *APIHandler) enforcePreflight(chartMeta *appcatalog.ChartMeta, releaseName string, vals map[string]interface{}) error {
+	report, err := h.runPreflight(chartMeta, releaseName, vals)
+	if err != nil {
+		log.Printf("Warning: Preflight check of release '%s' failed, installing anyway: %v", releaseName, err)
+		return nil
+	}
+	if report.Verdict == preflight.VerdictWontSchedule {
+		log.Printf("Install of release '%s' blocked by the preflight check: %v", releaseName, report.Reasons)
+		return &preflightBlockedError{report: report}
+	}
+	return nil
+}
@@ -0,0 +1,396 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"app-store-api/pkg/auth"
+	"app-store-api/pkg/helm"
+)
+
+// proxyTargetTTL is how long the Service port a release is proxied to is cached,
+// so loading a page and its assets does not list the Services on every request.
+const proxyTargetTTL = 30 * time.Second
+
+// proxyTargetCache caches the proxy targets of the releases.
+type proxyTargetCache struct {
+	mu      sync.Mutex
+	entries map[string]cachedProxyTarget
+}
+
+type cachedProxyTarget struct {
+	target  *helm.ProxyTarget
+	owner   string // helm.OwnerLabel of the release
+	expires time.Time
+}
+
+func newProxyTargetCache() *proxyTargetCache {
+	return &proxyTargetCache{entries: make(map[string]cachedProxyTarget)}
+}
+
+func (pc *proxyTargetCache) get(releaseName string) (*helm.ProxyTarget, string) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	entry, ok := pc.entries[releaseName]
+	if !ok || time.Now().After(entry.expires) {
+		delete(pc.entries, releaseName)
+		return nil, ""
+	}
+	return entry.target, entry.owner
+}
+
+func (pc *proxyTargetCache) put(releaseName string, target *helm.ProxyTarget, owner string) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	pc.entries[releaseName] = cachedProxyTarget{target: target, owner: owner, expires: time.Now().Add(proxyTargetTTL)}
+}
+
+func (pc *proxyTargetCache) forget(releaseName string) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	delete(pc.entries, releaseName)
+}
+
+// ForgetRelease drops what is cached about a release, once it is uninstalled or installed
+// again: a release reinstalled under the same name may have another owner.
+func (h *APIHandler) ForgetRelease(releaseName string) {
+	h.proxyTargets.forget(releaseName)
+}
+
+// proxySandboxPolicy is added to the responses of apps proxied under the API origin. The
+// sandbox gives their pages an opaque origin, so their scripts cannot call the API or open a
+// shell with the caller's credentials.
+const proxySandboxPolicy = "sandbox allow-scripts allow-forms allow-popups allow-modals allow-downloads"
+
+// ProxyHandler forwards any request under /api/releases/:releaseName/proxy/ to a Service of
+// the release through the API server service proxy, so ClusterIP-only apps can be reached with
+// the store's authentication. With PROXY_DOMAIN set, it redirects to the app origin instead.
+func (h *APIHandler) ProxyHandler(c *gin.Context) {
+	releaseName := c.Param("releaseName")
+	prefix := "/api/releases/" + releaseName + "/proxy"
+	if h.config.ProxyDomain != "" {
+		location := h.proxyOrigin(c.Request, releaseName) + strings.TrimPrefix(c.Request.URL.EscapedPath(), prefix)
+		if c.Request.URL.RawQuery != "" {
+			location += "?" + c.Request.URL.RawQuery
+		}
+		c.Redirect(http.StatusTemporaryRedirect, location)
+		return
+	}
+	h.serveProxy(c, releaseName, prefix)
+}
+
+// ProxyHostMiddleware serves the requests made to "<release>.<PROXY_DOMAIN>" from the app of
+// the release, each app on its own origin. Other hosts go on to the API routes.
+func (h *APIHandler) ProxyHostMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		releaseName, ok := proxyHostRelease(c.Request.Host, h.config.ProxyDomain)
+		if !ok {
+			c.Next()
+			return
+		}
+		if identityFrom(c).User == auth.AnonymousUser {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authentication required."})
+			return
+		}
+		h.serveProxy(c, releaseName, "")
+		c.Abort()
+	}
+}
+
+// proxyHostRelease returns the release an app host such as "wiki.apps.example.com" is for.
+func proxyHostRelease(host, domain string) (string, bool) {
+	if domain == "" {
+		return "", false
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	releaseName, found := strings.CutSuffix(strings.ToLower(host), "."+strings.ToLower(domain))
+	if !found || releaseName == "" || strings.Contains(releaseName, ".") {
+		return "", false
+	}
+	return releaseName, true
+}
+
+// proxyOrigin is the origin a release app is served on with PROXY_DOMAIN.
+func (h *APIHandler) proxyOrigin(r *http.Request, releaseName string) string {
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + releaseName + "." + h.config.ProxyDomain
+}
+
+// serveProxy forwards a request to the release, to the user who installed it or an admin.
+// HTTP bodies are streamed both ways and WebSocket upgrades are passed through. Redirects,
+// cookie paths and the links of HTML pages are rewritten to stay under prefix, which is empty
+// when the app has its own origin; under the API origin, the pages are sandboxed.
+func (h *APIHandler) serveProxy(c *gin.Context, releaseName, prefix string) {
+	target, owner, err := h.releaseProxyTarget(c, releaseName)
+	if err != nil {
+		switch {
+		case errors.Is(err, helm.ErrReleaseNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Release '%s' not found.", releaseName)})
+		case errors.Is(err, helm.ErrNoProxyService):
+			c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Release '%s' has no service to proxy to.", releaseName)})
+		default:
+			c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	if !h.ownsRelease(c, owner) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the user who installed the release or an admin can open it through the proxy."})
+		return
+	}
+
+	upstream, err := proxyUpstreamURL(target.BaseURL, strings.TrimPrefix(c.Request.URL.EscapedPath(), prefix), c.Request.URL.RawQuery)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	upstreamPrefix := target.BaseURL.Path
+
+	// The app sets its own security policy; the API's would block its scripts and styles
+	for _, header := range []string{"Content-Security-Policy", "X-Frame-Options", "Cross-Origin-Resource-Policy"} {
+		c.Writer.Header().Del(header)
+	}
+
+	proxy := &httputil.ReverseProxy{
+		Transport:     target.Transport,
+		FlushInterval: -1, // Flush at once, for server-sent events and long polling
+		Rewrite: func(r *httputil.ProxyRequest) {
+			r.Out.URL = upstream
+			r.Out.Host = ""
+			// The API server must only see the service account credentials, and the app
+			// must not be able to tell the store's identity headers from its own
+			for _, header := range []string{"Authorization", "Proxy-Authorization", h.config.AuthUserHeader, h.config.AuthGroupsHeader} {
+				r.Out.Header.Del(header)
+			}
+			for header := range r.Out.Header {
+				if strings.HasPrefix(header, "Impersonate-") {
+					r.Out.Header.Del(header)
+				}
+			}
+			// Let the transport negotiate compression, so HTML bodies arrive decoded and can be rewritten
+			r.Out.Header.Del("Accept-Encoding")
+			if prefix != "" {
+				r.Out.Header.Set("X-Forwarded-Prefix", prefix)
+			}
+		},
+		ModifyResponse: func(resp *http.Response) error {
+			rewriteProxyResponse(resp, upstreamPrefix, prefix)
+			if prefix != "" {
+				// Added to the app's own policy: every policy sent is enforced
+				resp.Header.Add("Content-Security-Policy", proxySandboxPolicy)
+			}
+			return nil
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			log.Printf("Proxy to release '%s' (service %s, port %s) failed: %v", releaseName, target.Service, target.Port, err)
+			h.proxyTargets.forget(releaseName)
+			c.JSON(http.StatusBadGateway, gin.H{"error": fmt.Sprintf("Release '%s' is not reachable.", releaseName)})
+		},
+	}
+	proxy.ServeHTTP(c.Writer, c.Request)
+}
+
+// errInvalidProxyPath rejects proxied paths that could leave the service proxy path of the release.
+var errInvalidProxyPath = errors.New("invalid proxy path: dot-dot segments and encoded slashes are not allowed")
+
+// proxyUpstreamURL appends the escaped request path suffix to the service proxy URL base.
+// The suffix is decoded and cleaned first; ".." segments and encoded slashes or backslashes
+// are rejected, so the request stays on the Service of the release and cannot reach other
+// API server paths with the service account credentials.
+func proxyUpstreamURL(base *url.URL, suffix, rawQuery string) (*url.URL, error) {
+	lower := strings.ToLower(suffix)
+	if strings.Contains(lower, "%2f") || strings.Contains(lower, "%5c") {
+		return nil, errInvalidProxyPath
+	}
+	decoded, err := url.PathUnescape(suffix)
+	if err != nil || strings.ContainsRune(decoded, '\\') {
+		return nil, errInvalidProxyPath
+	}
+	for _, segment := range strings.Split(decoded, "/") {
+		if segment == ".." {
+			return nil, errInvalidProxyPath
+		}
+	}
+
+	cleaned := path.Clean("/" + decoded)
+	if strings.HasSuffix(decoded, "/") && cleaned != "/" {
+		cleaned += "/"
+	}
+	basePath := strings.TrimSuffix(base.Path, "/")
+	upstream := *base
+	upstream.Path = basePath + cleaned
+	upstream.RawPath = ""
+	upstream.RawQuery = rawQuery
+	if !strings.HasPrefix(path.Clean(upstream.Path)+"/", basePath+"/") {
+		return nil, errInvalidProxyPath
+	}
+	return &upstream, nil
+}
+
+// releaseProxyTarget returns the cached proxy target of a release and its owner label, or
+// resolves them, preferring the Service and port the catalog entry exposes.
+func (h *APIHandler) releaseProxyTarget(c *gin.Context, releaseName string) (*helm.ProxyTarget, string, error) {
+	if target, owner := h.proxyTargets.get(releaseName); target != nil {
+		return target, owner, nil
+	}
+
+	rel, err := h.helmClient.GetRelease(releaseName)
+	if err != nil {
+		return nil, "", err
+	}
+	var service string
+	var port int32
+	if rel.Chart != nil && rel.Chart.Metadata != nil {
+		if chartMeta, ok := h.catalogService.FindByChartName(rel.Chart.Metadata.Name); ok && chartMeta.Expose != nil {
+			service = strings.ReplaceAll(chartMeta.Expose.Service, "{release}", releaseName)
+			port = chartMeta.Expose.Port
+		}
+	}
+
+	target, err := h.helmClient.ReleaseProxyTarget(c.Request.Context(), releaseName, service, port)
+	if errors.Is(err, helm.ErrNoProxyService) && (service != "" || port != 0) {
+		// The exposed Service may be absent when the chart values disabled it
+		target, err = h.helmClient.ReleaseProxyTarget(c.Request.Context(), releaseName, "", 0)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	owner := rel.Labels[helm.OwnerLabel]
+	h.proxyTargets.put(releaseName, target, owner)
+	return target, owner, nil
+}
+
+// rewriteProxyResponse moves the paths of an upstream response under the proxy prefix: the
+// API server service proxy path in redirects and HTML bodies (where the API server has already
+// rooted the links), root-relative redirects, and cookie paths.
+func rewriteProxyResponse(resp *http.Response, upstreamPrefix, prefix string) {
+	if location := resp.Header.Get("Location"); location != "" {
+		resp.Header.Set("Location", rewriteProxyLocation(location, upstreamPrefix, prefix))
+	}
+
+	if cookies := resp.Header.Values("Set-Cookie"); len(cookies) > 0 {
+		resp.Header.Del("Set-Cookie")
+		for _, cookie := range cookies {
+			resp.Header.Add("Set-Cookie", rewriteCookiePath(cookie, upstreamPrefix, prefix))
+		}
+	}
+
+	if resp.StatusCode != http.StatusSwitchingProtocols && resp.Header.Get("Content-Encoding") == "" &&
+		strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") {
+		resp.Body = newReplacingReader(resp.Body, []byte(upstreamPrefix), []byte(prefix))
+		resp.Header.Del("Content-Length")
+		resp.ContentLength = -1
+	}
+}
+
+// rewriteProxyLocation rewrites a redirect target to stay under the proxy prefix.
+// Redirects to other hosts are left alone.
+func rewriteProxyLocation(location, upstreamPrefix, prefix string) string {
+	u, err := url.Parse(location)
+	if err != nil || (u.Host != "" && !strings.HasPrefix(u.Path, upstreamPrefix)) {
+		return location
+	}
+	switch {
+	case strings.HasPrefix(u.Path, upstreamPrefix):
+		u.Path = prefix + strings.TrimPrefix(u.Path, upstreamPrefix)
+		if u.Path == "" {
+			u.Path = "/"
+		}
+	case strings.HasPrefix(u.Path, "/"):
+		u.Path = prefix + u.Path
+	default:
+		// Relative to the current path, already under the prefix
+		return location
+	}
+	u.Scheme, u.Host, u.RawPath = "", "", ""
+	return u.String()
+}
+
+// rewriteCookiePath moves the Path attribute of a Set-Cookie header under the proxy prefix.
+func rewriteCookiePath(cookie, upstreamPrefix, prefix string) string {
+	parts := strings.Split(cookie, ";")
+	for i, part := range parts {
+		name, value, found := strings.Cut(strings.TrimSpace(part), "=")
+		if !found || !strings.EqualFold(name, "path") {
+			continue
+		}
+		value = prefix + strings.TrimPrefix(value, upstreamPrefix)
+		if value == "" {
+			value = "/"
+		}
+		parts[i] = " Path=" + value
+	}
+	return strings.Join(parts, ";")
+}
+
+// replacingReader replaces every occurrence of from by to in a stream, holding back only
+// the bytes that may start an occurrence split across reads.
+type replacingReader struct {
+	src      io.ReadCloser
+	from, to []byte
+	pending  []byte // Read but not yet searched to the end
+	out      []byte // Ready to be returned
+	chunk    []byte
+	eof      bool
+}
+
+func newReplacingReader(src io.ReadCloser, from, to []byte) *replacingReader {
+	return &replacingReader{src: src, from: from, to: to, chunk: make([]byte, 32*1024)}
+}
+
+func (r *replacingReader) Read(p []byte) (int, error) {
+	for len(r.out) == 0 {
+		if r.eof {
+			if len(r.pending) == 0 {
+				return 0, io.EOF
+			}
+			r.out, r.pending = r.pending, nil
+			break
+		}
+		n, err := r.src.Read(r.chunk)
+		r.pending = append(r.pending, r.chunk[:n]...)
+		if err == io.EOF {
+			r.eof = true
+		} else if err != nil {
+			return 0, err
+		}
+
+		for {
+			i := bytes.Index(r.pending, r.from)
+			if i < 0 {
+				break
+			}
+			r.out = append(r.out, r.pending[:i]...)
+			r.out = append(r.out, r.to...)
+			r.pending = r.pending[i+len(r.from):]
+		}
+		if keep := len(r.from) - 1; !r.eof && len(r.pending) > keep {
+			r.out = append(r.out, r.pending[:len(r.pending)-keep]...)
+			r.pending = append([]byte(nil), r.pending[len(r.pending)-keep:]...)
+		}
+	}
+	n := copy(p, r.out)
+	r.out = r.out[n:]
+	return n, nil
+}
+
+func (r *replacingReader) Close() error {
+	return r.src.Close()
+}
@@ -0,0 +1,179 @@
+package api
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"app-store-api/pkg/helm"
+)
+
+// servicePath is the API server service proxy path of the wiki Service.
+const servicePath = "/api/v1/namespaces/apps/services/http:wiki:80/proxy"
+
+// fakeServiceProxy stands for the API server proxying to the wiki app. Like the API server, it
+// roots the links of the pages it serves at servicePath.
+type fakeServiceProxy struct {
+	*httptest.Server
+	requests []*http.Request
+}
+
+func newFakeServiceProxy(t *testing.T) *fakeServiceProxy {
+	fsp := &fakeServiceProxy{}
+	fsp.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fsp.requests = append(fsp.requests, r)
+		page, ok := strings.CutPrefix(r.URL.Path, servicePath)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		switch page {
+		case "/login":
+			http.SetCookie(w, &http.Cookie{Name: "sid", Value: "1", Path: "/", HttpOnly: true})
+			http.Redirect(w, r, servicePath+"/doku.php?id=start", http.StatusFound)
+		default:
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			io.WriteString(w, `<link href="`+servicePath+`/lib/style.css"><a href="`+servicePath+`/login">Log in</a>`)
+		}
+	}))
+	t.Cleanup(fsp.Close)
+	return fsp
+}
+
+// proxyTo caches the fake service proxy as the proxy target of the wiki release, owned by owner,
+// as if the release had been looked up.
+func (api *testAPI) proxyTo(t *testing.T, fsp *fakeServiceProxy, owner string) {
+	base, err := url.Parse(fsp.URL + servicePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	api.handler.proxyTargets.put("wiki", &helm.ProxyTarget{Service: "wiki", Port: "http", BaseURL: base, Transport: http.DefaultTransport},
+		helm.OwnerLabelValue(owner))
+}
+
+func TestProxyServesTheOwnerAndAdmins(t *testing.T) {
+	api := newTestAPI(t)
+	fsp := newFakeServiceProxy(t)
+	api.proxyTo(t, fsp, "alice")
+
+	for user, want := range map[string]int{"alice": http.StatusOK, testAdmin: http.StatusOK, "bob": http.StatusForbidden, "": http.StatusUnauthorized} {
+		if w := api.get("/api/releases/wiki/proxy/", user); w.Code != want {
+			t.Errorf("user %q: status %d, want %d (body %s)", user, w.Code, want, w.Body)
+		}
+	}
+	if len(fsp.requests) != 2 {
+		t.Fatalf("the app received %d requests, want 2", len(fsp.requests))
+	}
+	for _, r := range fsp.requests {
+		if user := r.Header.Get("X-Remote-User"); user != "" {
+			t.Errorf("the app was told the caller is %q", user)
+		}
+	}
+}
+
+func TestProxyKeepsTheAppUnderThePrefix(t *testing.T) {
+	api := newTestAPI(t)
+	api.proxyTo(t, newFakeServiceProxy(t), "alice")
+	const prefix = "/api/releases/wiki/proxy"
+
+	page := api.get(prefix+"/", "alice")
+	wantBody := `<link href="` + prefix + `/lib/style.css"><a href="` + prefix + `/login">Log in</a>`
+	if page.Body.String() != wantBody {
+		t.Errorf("page = %s, want %s", page.Body, wantBody)
+	}
+	// Served under the API origin, the page must not run with the caller's credentials
+	if !strings.Contains(strings.Join(page.Header().Values("Content-Security-Policy"), ", "), "sandbox") {
+		t.Errorf("the page is not sandboxed: %q", page.Header().Values("Content-Security-Policy"))
+	}
+
+	login := api.get(prefix+"/login", "alice")
+	if location := login.Header().Get("Location"); location != prefix+"/doku.php?id=start" {
+		t.Errorf("redirected to %q, want the page under the prefix", location)
+	}
+	if cookie := login.Header().Get("Set-Cookie"); !strings.Contains(cookie, "Path="+prefix+"/;") {
+		t.Errorf("cookie %q is not scoped to the prefix", cookie)
+	}
+}
+
+func TestProxyRejectsPathsLeavingTheService(t *testing.T) {
+	api := newTestAPI(t)
+	fsp := newFakeServiceProxy(t)
+	api.proxyTo(t, fsp, "alice")
+
+	// Each would reach /api/v1/namespaces/apps/secrets with the service account credentials
+	for _, path := range []string{
+		"/%2e%2e/%2e%2e/%2e%2e/secrets",
+		"/lib/..%2f..%2f..%2f..%2fsecrets",
+		"/lib%5c..%5c..%5c..%5csecrets",
+	} {
+		if w := api.get("/api/releases/wiki/proxy"+path, "alice"); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want %d", path, w.Code, http.StatusBadRequest)
+		}
+	}
+	if len(fsp.requests) != 0 {
+		t.Errorf("the API server received %s", fsp.requests[0].URL)
+	}
+
+	// Dot segments that stay on the service are cleaned
+	upstream, err := proxyUpstreamURL(&url.URL{Scheme: "https", Host: "10.0.0.1", Path: servicePath}, "/a//b/./c/", "x=1")
+	if err != nil || upstream.String() != "https://10.0.0.1"+servicePath+"/a/b/c/?x=1" {
+		t.Errorf("proxyUpstreamURL() = %v, %v", upstream, err)
+	}
+}
+
+func TestProxyOnTheAppOrigin(t *testing.T) {
+	api := newTestAPI(t)
+	api.proxyTo(t, newFakeServiceProxy(t), "alice")
+	api.handler.config.ProxyDomain = "apps.example.com"
+
+	req := httptest.NewRequest(http.MethodGet, "/login", nil)
+	req.Host = "wiki.apps.example.com:8443"
+	login := api.serve(req, "alice")
+	if location := login.Header().Get("Location"); location != "/doku.php?id=start" {
+		t.Errorf("redirected to %q, want the page at the root of the app origin", location)
+	}
+
+	// Hosts that are not of an app reach the API routes
+	req = httptest.NewRequest(http.MethodGet, "/health", nil)
+	req.Host = "a.wiki.apps.example.com"
+	if w := api.serve(req, "alice"); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "UP") {
+		t.Errorf("another host: status %d, body %s", w.Code, w.Body)
+	}
+	// The API path redirects to the app origin
+	if w := api.get("/api/releases/wiki/proxy/login?next=%2F", "alice"); w.Header().Get("Location") != "http://wiki.apps.example.com/login?next=%2F" {
+		t.Errorf("the API path redirects to %q", w.Header().Get("Location"))
+	}
+}
+
+func TestForgetRelease(t *testing.T) {
+	api := newTestAPI(t)
+	api.proxyTo(t, newFakeServiceProxy(t), "alice")
+
+	// Uninstalled and installed again by another user: alice's cached ownership must go
+	api.handler.ForgetRelease("wiki")
+	if target, owner := api.handler.proxyTargets.get("wiki"); target != nil {
+		t.Errorf("the proxy target owned by %s is still cached", owner)
+	}
+}
+
+func TestReplacingReaderAcrossReads(t *testing.T) {
+	page := strings.Repeat(`<img src="`+servicePath+`/a.png">`, 3000) + servicePath[:10]
+	want := strings.ReplaceAll(page, servicePath, "/p")
+
+	// Occurrences split between reads are still replaced, and a trailing partial match is kept
+	for name, src := range map[string]io.Reader{
+		"whole":    strings.NewReader(page),
+		"one byte": iotest.OneByteReader(strings.NewReader(page)),
+		"halves":   iotest.HalfReader(strings.NewReader(page)),
+	} {
+		got, err := io.ReadAll(newReplacingReader(io.NopCloser(src), []byte(servicePath), []byte("/p")))
+		if err != nil || !bytes.Equal(got, []byte(want)) {
+			t.Errorf("%s reads: got %d bytes (error %v), want %d", name, len(got), err, len(want))
+		}
+	}
+}
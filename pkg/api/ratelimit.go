@@ -0,0 +1,137 @@
+package api
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/time/rate"
+
+	"app-store-api/pkg/auth"
+)
+
+// bucketIdleTTL is how long an unused bucket is kept before being forgotten.
+const bucketIdleTTL = 10 * time.Minute
+
+type bucket struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// RateLimiter holds one token bucket per key (identity or client IP).
+type RateLimiter struct {
+	perMinute int
+	limit     rate.Limit
+	burst     int
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastPrune time.Time
+}
+
+// NewRateLimiter creates a limiter allowing perMinute sustained requests per key with the given burst.
+func NewRateLimiter(perMinute, burst int) *RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &RateLimiter{
+		perMinute: perMinute,
+		limit:     rate.Limit(float64(perMinute) / 60),
+		burst:     burst,
+		buckets:   make(map[string]*bucket),
+		lastPrune: time.Now(),
+	}
+}
+
+// reserve reserves a token of the bucket of key and returns the tokens left. The
+// reservation has a delay when the bucket is empty; it must then be cancelled.
+func (rl *RateLimiter) reserve(key string, now time.Time) (*rate.Reservation, int) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	if now.Sub(rl.lastPrune) > bucketIdleTTL {
+		for k, b := range rl.buckets {
+			if now.Sub(b.lastSeen) > bucketIdleTTL {
+				delete(rl.buckets, k)
+			}
+		}
+		rl.lastPrune = now
+	}
+
+	b, ok := rl.buckets[key]
+	if !ok {
+		b = &bucket{limiter: rate.NewLimiter(rl.limit, rl.burst)}
+		rl.buckets[key] = b
+	}
+	b.lastSeen = now
+
+	reservation := b.limiter.ReserveN(now, 1)
+	return reservation, int(math.Max(0, b.limiter.TokensAt(now)))
+}
+
+// RateLimitMiddleware throttles requests per identity and per client IP.
+// Both buckets must have a token for the request to go through; when either is empty,
+// the token taken from the other is given back. Anonymous callers are only limited by IP.
+func RateLimitMiddleware(rl *RateLimiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if rl.perMinute <= 0 {
+			c.Next()
+			return
+		}
+		now := time.Now()
+		keys := []string{"ip:" + c.ClientIP()}
+		if id := identityFrom(c); id.User != auth.AnonymousUser {
+			keys = append(keys, "user:"+id.User)
+		}
+
+		reservations := make([]*rate.Reservation, 0, len(keys))
+		remaining := rl.burst
+		var retryAfter time.Duration
+		for _, key := range keys {
+			reservation, left := rl.reserve(key, now)
+			reservations = append(reservations, reservation)
+			if delay := reservation.DelayFrom(now); delay > retryAfter {
+				retryAfter = delay
+			}
+			if left < remaining {
+				remaining = left
+			}
+		}
+		c.Header("X-RateLimit-Limit", strconv.Itoa(rl.perMinute))
+		if retryAfter > 0 {
+			for _, reservation := range reservations {
+				reservation.CancelAt(now)
+			}
+			seconds := int(math.Ceil(retryAfter.Seconds()))
+			c.Header("Retry-After", strconv.Itoa(seconds))
+			c.Header("X-RateLimit-Remaining", "0")
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+				"error": fmt.Sprintf("Too many requests, retry in %d seconds.", seconds),
+			})
+			return
+		}
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(remaining))
+		c.Next()
+	}
+}
+
+// BodySizeLimitMiddleware caps the size of request bodies.
+// Reading past the limit fails with *http.MaxBytesError.
+func BodySizeLimitMiddleware(maxBytes int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if maxBytes > 0 && c.Request.Body != nil {
+			if c.Request.ContentLength > maxBytes {
+				c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{
+					"error": fmt.Sprintf("Request body exceeds %d bytes.", maxBytes),
+				})
+				return
+			}
+			c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes)
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,179 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"app-store-api/pkg/auth"
+)
+
+// newRateLimitedRouter serves POST /install behind the rate limiter. The caller is named by
+// the X-Test-User header, and is anonymous without it.
+func newRateLimitedRouter(t *testing.T, rl *RateLimiter) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	if err := router.SetTrustedProxies(nil); err != nil {
+		t.Fatal(err)
+	}
+	router.Use(func(c *gin.Context) {
+		id := auth.Identity{User: auth.AnonymousUser}
+		if user := c.GetHeader("X-Test-User"); user != "" {
+			id.User = user
+		}
+		c.Set(identityContextKey, id)
+	})
+	router.POST("/install", RateLimitMiddleware(rl), func(c *gin.Context) {
+		c.Status(http.StatusAccepted)
+	})
+	return router
+}
+
+func install(router *gin.Engine, user, ip string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/install", nil)
+	req.RemoteAddr = ip + ":40000"
+	if user != "" {
+		req.Header.Set("X-Test-User", user)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestRateLimitMiddlewareThrottlesAfterBurst(t *testing.T) {
+	router := newRateLimitedRouter(t, NewRateLimiter(6, 2))
+
+	for i, wantRemaining := range []string{"1", "0"} {
+		w := install(router, "alice", "10.0.0.1")
+		if w.Code != http.StatusAccepted {
+			t.Fatalf("request %d: status %d, want %d", i+1, w.Code, http.StatusAccepted)
+		}
+		if got := w.Header().Get("X-RateLimit-Remaining"); got != wantRemaining {
+			t.Errorf("request %d: X-RateLimit-Remaining = %q, want %q", i+1, got, wantRemaining)
+		}
+	}
+
+	w := install(router, "alice", "10.0.0.1")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("third request: status %d, want %d", w.Code, http.StatusTooManyRequests)
+	}
+	// 6 per minute: the next token comes 10 seconds later
+	if got := w.Header().Get("Retry-After"); got != "10" {
+		t.Errorf("Retry-After = %q, want \"10\"", got)
+	}
+	if got := w.Header().Get("X-RateLimit-Limit"); got != "6" {
+		t.Errorf("X-RateLimit-Limit = %q, want \"6\"", got)
+	}
+	if got := w.Header().Get("X-RateLimit-Remaining"); got != "0" {
+		t.Errorf("X-RateLimit-Remaining = %q, want \"0\"", got)
+	}
+}
+
+func TestRateLimitMiddlewareRefundsRejectedRequests(t *testing.T) {
+	router := newRateLimitedRouter(t, NewRateLimiter(1, 2))
+
+	// alice uses up her bucket from her own address
+	install(router, "alice", "10.0.0.1")
+	install(router, "alice", "10.0.0.1")
+
+	// Then keeps trying from the office NAT, and is refused by her own bucket
+	for i := 0; i < 3; i++ {
+		if w := install(router, "alice", "192.0.2.1"); w.Code != http.StatusTooManyRequests {
+			t.Fatalf("alice through the NAT: status %d, want %d", w.Code, http.StatusTooManyRequests)
+		}
+	}
+
+	// Her refused requests took nothing from the bucket of the NAT address
+	for i := 0; i < 2; i++ {
+		if w := install(router, "bob", "192.0.2.1"); w.Code != http.StatusAccepted {
+			t.Errorf("bob through the NAT, request %d: status %d, want %d", i+1, w.Code, http.StatusAccepted)
+		}
+	}
+	if w := install(router, "bob", "192.0.2.1"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("bob past the burst of the NAT address: status %d, want %d", w.Code, http.StatusTooManyRequests)
+	}
+}
+
+func TestRateLimitMiddlewareAnonymousCallers(t *testing.T) {
+	router := newRateLimitedRouter(t, NewRateLimiter(1, 1))
+
+	if w := install(router, "", "10.0.0.1"); w.Code != http.StatusAccepted {
+		t.Fatalf("first anonymous request: status %d", w.Code)
+	}
+	if w := install(router, "", "10.0.0.1"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("second anonymous request from the same address: status %d, want %d", w.Code, http.StatusTooManyRequests)
+	}
+	// Anonymous callers share no user bucket
+	if w := install(router, "", "10.0.0.2"); w.Code != http.StatusAccepted {
+		t.Errorf("anonymous request from another address: status %d, want %d", w.Code, http.StatusAccepted)
+	}
+}
+
+func TestRateLimitMiddlewareDisabled(t *testing.T) {
+	router := newRateLimitedRouter(t, NewRateLimiter(0, 1))
+	for i := 0; i < 20; i++ {
+		w := install(router, "alice", "10.0.0.1")
+		if w.Code != http.StatusAccepted {
+			t.Fatalf("request %d with rate limiting disabled: status %d", i+1, w.Code)
+		}
+		if w.Header().Get("X-RateLimit-Limit") != "" {
+			t.Fatal("rate limit headers sent with rate limiting disabled")
+		}
+	}
+}
+
+func TestRateLimiterPrunesIdleBuckets(t *testing.T) {
+	start := time.Date(2026, time.January, 5, 9, 0, 0, 0, time.UTC)
+	rl := NewRateLimiter(60, 1)
+	rl.lastPrune = start
+
+	rl.reserve("user:idle", start)
+	rl.reserve("user:active", start.Add(bucketIdleTTL))
+	rl.reserve("user:active", start.Add(bucketIdleTTL+time.Minute))
+
+	if _, ok := rl.buckets["user:idle"]; ok {
+		t.Error("a bucket unused for longer than bucketIdleTTL was kept")
+	}
+	if _, ok := rl.buckets["user:active"]; !ok {
+		t.Error("a bucket in use was pruned")
+	}
+}
+
+func TestBodySizeLimitMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/install", BodySizeLimitMiddleware(16), func(c *gin.Context) {
+		var body map[string]interface{}
+		if err := c.ShouldBindJSON(&body); err != nil {
+			c.Status(http.StatusBadRequest)
+			return
+		}
+		c.Status(http.StatusAccepted)
+	})
+
+	post := func(body []byte, chunked bool) int {
+		req := httptest.NewRequest(http.MethodPost, "/install", bytes.NewReader(body))
+		if chunked {
+			// Without a Content-Length, the limit is only hit while reading
+			req.ContentLength = -1
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if got := post([]byte(`{"a":"b"}`), false); got != http.StatusAccepted {
+		t.Errorf("small body: status %d, want %d", got, http.StatusAccepted)
+	}
+	if got := post([]byte(`{"values":{"a":"bbbbbbbb"}}`), false); got != http.StatusRequestEntityTooLarge {
+		t.Errorf("body over the limit: status %d, want %d", got, http.StatusRequestEntityTooLarge)
+	}
+	if got := post([]byte(`{"values":{"a":"bbbbbbbb"}}`), true); got == http.StatusAccepted {
+		t.Error("body over the limit without a Content-Length was read whole")
+	}
+}
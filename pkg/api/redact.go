@@ -0,0 +1,69 @@
+package api
+
+import (
+	"app-store-api/pkg/approvals"
+	"app-store-api/pkg/helm"
+	"app-store-api/pkg/values"
+)
+
+// secretPathsForChart returns the secret value paths declared by the catalog entry of a release chart.
+func (h *APIHandler) secretPathsForChart(chartName string) []string {
+	if chartMeta, ok := h.catalogService.FindByChartName(chartName); ok {
+		return chartMeta.SecretPaths()
+	}
+	return nil
+}
+
+// secretPathsForCatalogEntry returns the secret value paths declared by a catalog entry.
+func (h *APIHandler) secretPathsForCatalogEntry(name string) []string {
+	if chartMeta, err := h.catalogService.GetChartByName(name); err == nil {
+		return chartMeta.SecretPaths()
+	}
+	return nil
+}
+
+// redactReleaseStatus removes secrets from a `helm status -o json` document:
+// user-supplied values under "config" and Secret data in the rendered manifests.
+func (h *APIHandler) redactReleaseStatus(status map[string]interface{}) map[string]interface{} {
+	var chartName string
+	if chart, ok := status["chart"].(map[string]interface{}); ok {
+		if metadata, ok := chart["metadata"].(map[string]interface{}); ok {
+			chartName, _ = metadata["name"].(string)
+		}
+		// The chart's default values are not user input but can still carry default credentials
+		if defaults, ok := chart["values"].(map[string]interface{}); ok {
+			chart["values"] = values.Redact(defaults, h.secretPathsForChart(chartName))
+		}
+	}
+
+	if config, ok := status["config"].(map[string]interface{}); ok {
+		status["config"] = values.Redact(config, h.secretPathsForChart(chartName))
+	}
+	if manifest, ok := status["manifest"].(string); ok {
+		status["manifest"] = helm.RedactManifestSecrets(manifest)
+	}
+	if hooks, ok := status["hooks"].([]interface{}); ok {
+		for _, hook := range hooks {
+			if hookMap, ok := hook.(map[string]interface{}); ok {
+				if manifest, ok := hookMap["manifest"].(string); ok {
+					hookMap["manifest"] = helm.RedactManifestSecrets(manifest)
+				}
+			}
+		}
+	}
+	return status
+}
+
+// redactApproval returns a copy of an approval request with its secret values redacted.
+func (h *APIHandler) redactApproval(req approvals.Request) approvals.Request {
+	req.Values = values.Redact(req.Values, h.secretPathsForCatalogEntry(req.Chart))
+	return req
+}
+
+func (h *APIHandler) redactApprovals(reqs []approvals.Request) []approvals.Request {
+	out := make([]approvals.Request, len(reqs))
+	for i, req := range reqs {
+		out[i] = h.redactApproval(req)
+	}
+	return out
+}
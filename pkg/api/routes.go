@@ -2,33 +2,88 @@ package api
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"app-store-api/pkg/config"
+	"app-store-api/pkg/telemetry"
 )
 
 // SetupRouter configures the Gin router with all API routes.
-func SetupRouter(handler *APIHandler) *gin.Engine {
+func SetupRouter(handler *APIHandler, cfg *config.AppConfig) *gin.Engine {
 	router := gin.Default()
+	router.Use(PrometheusMiddleware())
 
-	// Basic CORS middleware (allow all for now, refine for production)
-	router.Use(CORSMiddleware())
+	router.Use(SecurityHeadersMiddleware(cfg.TLSCertFile != "" && cfg.TLSKeyFile != ""))
+	router.Use(CORSMiddleware(cfg))
+	router.Use(IdentityMiddleware(cfg.AuthUserHeader, cfg.AuthGroupsHeader, cfg.TrustedProxyCIDRs))
+	// Apps served on their own origin, "<release>.<PROXY_DOMAIN>", never reach the API routes
+	router.Use(handler.ProxyHostMiddleware())
 
 	// Health check endpoint
 	router.GET("/health", func(c *gin.Context) {
 		c.JSON(200, gin.H{"status": "UP"})
 	})
 
+	// Installs and uninstalls are throttled; preflights and the other release actions have
+	// their own buckets, so they never use up the installs of a user
+	installLimiter := RateLimitMiddleware(NewRateLimiter(cfg.RateLimitPerMinute, cfg.RateLimitBurst))
+	actionLimiter := RateLimitMiddleware(NewRateLimiter(cfg.RateLimitPerMinute, cfg.RateLimitBurst))
+
+	// Prometheus exposition endpoint
+	router.GET("/metrics", gin.WrapH(promhttp.HandlerFor(telemetry.Registry, promhttp.HandlerOpts{})))
+
+	// App proxy, outside the API group: proxied uploads are not bound by the request body limit
+	router.Any("/api/releases/:releaseName/proxy/*path", RequireAuthenticated(), handler.ProxyHandler)
+
 	apiGroup := router.Group("/api")
+	apiGroup.Use(BodySizeLimitMiddleware(cfg.MaxRequestBodyBytes))
 	{
+		requireAdmin := RequireAdmin(handler.authorizer)
+
 		// Chart catalog endpoints
 		apiGroup.GET("/charts", handler.GetChartsHandler)
 
 		// Release management endpoints
-		apiGroup.POST("/charts/:chartName/install", handler.InstallChartHandler)
+		apiGroup.POST("/charts/:chartName/install", installLimiter, handler.InstallChartHandler)
+		apiGroup.POST("/charts/:chartName/preflight", actionLimiter, handler.PreflightHandler)
 		apiGroup.GET("/releases", handler.ListReleasesHandler)
 		apiGroup.GET("/releases/:releaseName/status", handler.GetReleaseStatusHandler)
-		apiGroup.DELETE("/releases/:releaseName", handler.UninstallReleaseHandler)
+		apiGroup.GET("/releases/:releaseName/resources", handler.GetReleaseResourcesHandler)
+		apiGroup.GET("/releases/:releaseName/logs", RequireAuthenticated(), handler.GetReleaseLogsHandler)
+		apiGroup.GET("/releases/:releaseName/pods/:pod/exec", RequireOperator(handler.authorizer), handler.ExecHandler)
+		apiGroup.GET("/releases/:releaseName/credentials", RequireAuthenticated(), handler.GetReleaseCredentialsHandler)
+		apiGroup.GET("/releases/:releaseName/metrics", handler.GetReleaseMetricsHandler)
+		apiGroup.DELETE("/releases/:releaseName", installLimiter, handler.UninstallReleaseHandler)
+		apiGroup.POST("/releases/:releaseName/restart", actionLimiter, handler.RestartReleaseHandler)
+		apiGroup.POST("/releases/:releaseName/scale", actionLimiter, handler.ScaleReleaseHandler)
+		apiGroup.POST("/releases/:releaseName/suspend", actionLimiter, handler.SuspendReleaseHandler)
+		apiGroup.POST("/releases/:releaseName/resume", actionLimiter, handler.ResumeReleaseHandler)
+		apiGroup.POST("/releases/:releaseName/extend", actionLimiter, handler.ExtendReleaseHandler)
+		apiGroup.GET("/releases/:releaseName/schedule", handler.GetScheduleHandler)
+		apiGroup.PUT("/releases/:releaseName/schedule", actionLimiter, handler.PutScheduleHandler)
+		apiGroup.DELETE("/releases/:releaseName/schedule", actionLimiter, handler.DeleteScheduleHandler)
+		apiGroup.GET("/schedules", handler.ListSchedulesHandler)
+		apiGroup.GET("/orphans", handler.ListOrphansHandler)
 
 		// Metrics streaming endpoint
 		apiGroup.GET("/metrics/stream", handler.MetricsStreamHandler)
+		apiGroup.GET("/metrics/history", handler.GetMetricsHistoryHandler)
+
+		// Cluster resources endpoints
+		apiGroup.GET("/cluster/storage", handler.GetClusterStorageHandler)
+		apiGroup.GET("/cluster/nodeports", handler.GetClusterNodePortsHandler)
+
+		// Audit log endpoint
+		apiGroup.GET("/audit", requireAdmin, handler.GetAuditHandler)
+
+		// Desired-state sync endpoint
+		apiGroup.GET("/sync/status", handler.GetSyncStatusHandler)
+
+		// Approval workflow endpoints
+		apiGroup.GET("/approvals", requireAdmin, handler.ListApprovalsHandler)
+		apiGroup.GET("/approvals/:id", RequireAuthenticated(), handler.GetApprovalHandler)
+		apiGroup.POST("/approvals/:id/approve", requireAdmin, handler.ApproveHandler)
+		apiGroup.POST("/approvals/:id/reject", requireAdmin, handler.RejectHandler)
 	}
 	return router
 }
@@ -0,0 +1,111 @@
+package api
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"app-store-api/pkg/approvals"
+	"app-store-api/pkg/auth"
+	"app-store-api/pkg/config"
+)
+
+const (
+	testProxyAddr     = "10.0.0.2"   // The authenticating proxy, trusted with the identity headers
+	testUntrustedAddr = "192.0.2.10" // Any other client
+	testAdmin         = "root"
+)
+
+// testAPI is the router built from the default configuration, with the authenticating proxy
+// at testProxyAddr, testAdmin as the only admin and the stores in a fake cluster.
+type testAPI struct {
+	ctx       context.Context
+	router    *gin.Engine
+	handler   *APIHandler
+	approvals *approvals.Store
+}
+
+func newTestAPI(t *testing.T) *testAPI {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.TrustedProxyCIDRs = []*net.IPNet{{IP: net.ParseIP(testProxyAddr), Mask: net.CIDRMask(32, 32)}}
+	cfg.AdminUsers = []string{testAdmin}
+
+	kubeClient := fake.NewSimpleClientset()
+	approvalStore := approvals.NewStore(kubeClient, cfg.AppInstallNamespace)
+	handler := NewAPIHandler(Dependencies{
+		Config:        cfg,
+		Authorizer:    auth.NewAuthorizer(cfg),
+		ApprovalStore: approvalStore,
+	})
+	return &testAPI{ctx: t.Context(), router: SetupRouter(handler, cfg), handler: handler, approvals: approvalStore}
+}
+
+// get sends a GET request as user, through the authenticating proxy. Without a user, the
+// request comes straight from a client claiming to be the admin, which must not be believed.
+func (api *testAPI) get(path, user string) *httptest.ResponseRecorder {
+	return api.serve(httptest.NewRequest(http.MethodGet, path, nil), user)
+}
+
+// serve sends req as user, as get does, canceling it when the test ends.
+func (api *testAPI) serve(req *http.Request, user string) *httptest.ResponseRecorder {
+	req = req.WithContext(api.ctx)
+	if user != "" {
+		req.RemoteAddr = testProxyAddr + ":40000"
+		req.Header.Set("X-Remote-User", user)
+	} else {
+		req.RemoteAddr = testUntrustedAddr + ":40000"
+		req.Header.Set("X-Remote-User", testAdmin)
+	}
+	w := httptest.NewRecorder()
+	api.router.ServeHTTP(w, req)
+	return w
+}
+
+func TestGetApprovalRequiresAuthentication(t *testing.T) {
+	api := newTestAPI(t)
+	// Without trusted proxies, every request was made by the anonymous user
+	req := &approvals.Request{Chart: "gitea", ReleaseName: "git", RequestedBy: auth.AnonymousUser,
+		Values: map[string]interface{}{"gitea": map[string]interface{}{"admin": map[string]interface{}{"password": "hunter2"}}}}
+	if err := api.approvals.Create(req); err != nil {
+		t.Fatal(err)
+	}
+	path := "/api/approvals/" + req.ID
+
+	if w := api.get(path, ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("anonymous caller: status %d, want %d (body %s)", w.Code, http.StatusUnauthorized, w.Body)
+	}
+	if w := api.get(path, "mallory"); w.Code != http.StatusNotFound {
+		t.Errorf("another user: status %d, want %d (body %s)", w.Code, http.StatusNotFound, w.Body)
+	}
+	if w := api.get("/api/approvals", ""); w.Code != http.StatusForbidden {
+		t.Errorf("anonymous listing: status %d, want %d", w.Code, http.StatusForbidden)
+	}
+}
+
+func TestGetReleaseCredentialsRequiresAuthentication(t *testing.T) {
+	api := newTestAPI(t)
+	// Refused before the credentials are looked up: releases installed without trusted proxies
+	// are owned by the anonymous user, which every unauthenticated caller is
+	if w := api.get("/api/releases/wordpress/credentials", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("anonymous caller: status %d, want %d (body %s)", w.Code, http.StatusUnauthorized, w.Body)
+	}
+}
+
+func TestGetReleaseLogsRequiresAuthentication(t *testing.T) {
+	api := newTestAPI(t)
+	for _, path := range []string{"/api/releases/wordpress/logs", "/api/releases/wordpress/logs?follow=true&container=wordpress"} {
+		if w := api.get(path, ""); w.Code != http.StatusUnauthorized {
+			t.Errorf("anonymous caller of %s: status %d, want %d (body %s)", path, w.Code, http.StatusUnauthorized, w.Body)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"app-store-api/pkg/audit"
+	"app-store-api/pkg/helm"
+	"app-store-api/pkg/schedules"
+)
+
+// ListSchedulesHandler lists the sleep schedules of every release.
+func (h *APIHandler) ListSchedulesHandler(c *gin.Context) {
+	records, err := h.scheduleStore.List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"time_zone": h.config.ScheduleLocation.String(), "schedules": records})
+}
+
+// GetScheduleHandler returns the sleep schedule of a release and whether it is in a sleep window now.
+func (h *APIHandler) GetScheduleHandler(c *gin.Context) {
+	releaseName := c.Param("releaseName")
+	rec, err := h.scheduleStore.Get(releaseName)
+	if errors.Is(err, schedules.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Release '%s' has no schedule.", releaseName)})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, h.scheduleResponse(rec))
+}
+
+// PutScheduleHandler sets the sleep schedule of a release. The scheduler applies it on its next run.
+func (h *APIHandler) PutScheduleHandler(c *gin.Context) {
+	releaseName := c.Param("releaseName")
+	var schedule schedules.Schedule
+	if err := c.ShouldBindJSON(&schedule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid request body: %v", err)})
+		return
+	}
+	if err := schedule.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if _, err := h.helmClient.ReleaseChartName(releaseName); errors.Is(err, helm.ErrReleaseNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Release '%s' not found.", releaseName)})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	startTime := time.Now()
+	// Keep the state of an existing schedule, so a release put to sleep is still woken up
+	rec, err := h.scheduleStore.Get(releaseName)
+	if errors.Is(err, schedules.ErrNotFound) {
+		rec, err = &schedules.Record{Release: releaseName}, nil
+	}
+	if err == nil {
+		rec.Schedule = schedule
+		rec.UpdatedBy = identityFrom(c).User
+		rec.UpdatedAt = startTime.UTC()
+		err = h.scheduleStore.Save(rec)
+	}
+	h.recordAudit(c, audit.Entry{Action: audit.ActionSchedule, Release: releaseName}, startTime, err)
+	if errors.Is(err, schedules.ErrConflict) {
+		c.JSON(http.StatusConflict, gin.H{"error": "The schedule was modified concurrently, retry."})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, h.scheduleResponse(rec))
+}
+
+// DeleteScheduleHandler removes the sleep schedule of a release, waking it up if the scheduler put it to sleep.
+func (h *APIHandler) DeleteScheduleHandler(c *gin.Context) {
+	releaseName := c.Param("releaseName")
+	rec, err := h.scheduleStore.Get(releaseName)
+	if errors.Is(err, schedules.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Release '%s' has no schedule.", releaseName)})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	startTime := time.Now()
+	response := gin.H{"message": fmt.Sprintf("Schedule of release '%s' deleted.", releaseName)}
+	if rec.Asleep {
+		report, err := h.helmClient.ResumeRelease(releaseName)
+		if err == nil {
+			err = report.Err()
+		}
+		h.recordAudit(c, audit.Entry{Action: audit.ActionResume, Release: releaseName}, startTime, err)
+		if err != nil && !errors.Is(err, helm.ErrReleaseNotFound) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Could not wake up release '%s': %v", releaseName, err)})
+			return
+		}
+		response["resume"] = report
+	}
+
+	err = h.scheduleStore.Delete(releaseName)
+	h.recordAudit(c, audit.Entry{Action: audit.ActionSchedule, Release: releaseName}, startTime, err)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// scheduleResponse adds to a schedule the time zone it is read in and whether it is in a sleep window now.
+func (h *APIHandler) scheduleResponse(rec *schedules.Record) gin.H {
+	return gin.H{
+		"schedule":     rec,
+		"time_zone":    h.config.ScheduleLocation.String(),
+		"sleeping_now": rec.Schedule.Asleep(time.Now().In(h.config.ScheduleLocation)),
+	}
+}
@@ -0,0 +1,355 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"path"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/gin-gonic/gin"
+	"helm.sh/helm/v3/pkg/release"
+
+	"app-store-api/pkg/appcatalog"
+	"app-store-api/pkg/audit"
+	"app-store-api/pkg/desiredstate"
+	"app-store-api/pkg/helm"
+	"app-store-api/pkg/values"
+)
+
+// SyncActor is the audit actor of the changes made by the desired-state sync.
+const SyncActor = "sync"
+
+// syncHashLabel holds, on a Helm release, the hash of the declaration it was last synced from.
+const syncHashLabel = "app-store-api/sync-hash"
+
+// syncAction is what a sync run does to a release.
+type syncAction string
+
+const (
+	syncNone    syncAction = "none"
+	syncInstall syncAction = "install"
+	syncUpgrade syncAction = "upgrade"
+	syncPrune   syncAction = "prune"
+)
+
+// syncStep is the planned action for one release.
+type syncStep struct {
+	Release string     `json:"release"`
+	Chart   string     `json:"chart,omitempty"`   // Catalog name, for declared releases
+	Version string     `json:"version,omitempty"` // Desired chart version, if any
+	Action  syncAction `json:"action"`
+	Reason  string     `json:"reason,omitempty"`
+	Error   string     `json:"error,omitempty"` // Why the release cannot be synced; it is left as is
+
+	app       desiredstate.App
+	chartMeta *appcatalog.ChartMeta // With the desired version
+}
+
+// syncResult is the outcome of an applied step.
+type syncResult struct {
+	Release    string        `json:"release"`
+	Action     syncAction    `json:"action"`
+	Reason     string        `json:"reason,omitempty"`
+	Outcome    audit.Outcome `json:"outcome"`
+	Error      string        `json:"error,omitempty"`
+	DurationMs int64         `json:"duration_ms"`
+}
+
+// syncRun is the record of a sync run.
+type syncRun struct {
+	StartedAt  time.Time    `json:"started_at"`
+	FinishedAt time.Time    `json:"finished_at"`
+	DryRun     bool         `json:"dry_run,omitempty"`
+	Error      string       `json:"error,omitempty"` // Why no plan could be made
+	Results    []syncResult `json:"results"`         // The steps applied or failing; on a dry run, the steps planned
+}
+
+// syncHistory keeps the latest sync run.
+type syncHistory struct {
+	mu   sync.Mutex
+	last *syncRun
+}
+
+func (s *syncHistory) set(run *syncRun) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.last = run
+}
+
+func (s *syncHistory) get() *syncRun {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.last
+}
+
+// RunSync reconciles the releases with the desired-state file every interval until ctx is cancelled.
+// The file is read again on every run.
+func (h *APIHandler) RunSync(ctx context.Context, interval time.Duration) {
+	log.Printf("Starting desired-state sync of %s (interval %v, prune %t, dry run %t)", h.config.SyncFile, interval, h.config.SyncPrune, h.config.SyncDryRun)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		h.syncOnce()
+		select {
+		case <-ctx.Done():
+			log.Println("Desired-state sync stopped.")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// syncOnce plans a sync run and, unless in dry-run mode, applies the plan.
+func (h *APIHandler) syncOnce() {
+	run := &syncRun{StartedAt: time.Now().UTC(), DryRun: h.config.SyncDryRun, Results: []syncResult{}}
+	steps, err := h.planSync()
+	if err != nil {
+		// Nothing is changed until the file is readable again
+		log.Printf("Sync: %v", err)
+		run.Error = err.Error()
+	}
+	for _, step := range steps {
+		if step.Error != "" {
+			log.Printf("Sync: release '%s' cannot be synced: %s", step.Release, step.Error)
+			run.Results = append(run.Results, syncResult{Release: step.Release, Action: step.Action, Outcome: audit.OutcomeFailure, Error: step.Error})
+			continue
+		}
+		if step.Action == syncNone {
+			continue
+		}
+		if run.DryRun {
+			run.Results = append(run.Results, syncResult{Release: step.Release, Action: step.Action, Reason: step.Reason})
+			continue
+		}
+		run.Results = append(run.Results, h.applySyncStep(step))
+	}
+	run.FinishedAt = time.Now().UTC()
+	h.syncHistory.set(run)
+}
+
+// planSync compares the desired-state file with the installed releases. Declared releases come
+// first, in file order, then the undeclared ones by name.
+func (h *APIHandler) planSync() ([]syncStep, error) {
+	apps, err := desiredstate.Load(h.config.SyncFile)
+	if err != nil {
+		return nil, err
+	}
+	installed, err := h.helmClient.ListInstalledReleases()
+	if err != nil {
+		return nil, err
+	}
+	byName := make(map[string]*helm.ReleaseInfo, len(installed))
+	for i := range installed {
+		byName[installed[i].Name] = &installed[i]
+	}
+
+	steps := make([]syncStep, 0, len(apps))
+	declared := make(map[string]bool, len(apps))
+	for _, app := range apps {
+		declared[app.Release] = true
+		steps = append(steps, h.planApp(app, byName[app.Release]))
+	}
+
+	var undeclared []string
+	for name := range byName {
+		if !declared[name] {
+			undeclared = append(undeclared, name)
+		}
+	}
+	sort.Strings(undeclared)
+	for _, name := range undeclared {
+		step := syncStep{Release: name, Action: syncNone, Reason: "not declared, pruning is disabled"}
+		if h.config.SyncPrune {
+			if len(apps) == 0 {
+				// An empty file is more likely a mistake than a request to uninstall everything
+				step.Reason = "not declared, but the file declares no apps so nothing is pruned"
+			} else {
+				step.Action, step.Reason = syncPrune, "not declared"
+			}
+		}
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+// planApp compares a declared release with its installed release, nil if not installed.
+func (h *APIHandler) planApp(app desiredstate.App, info *helm.ReleaseInfo) syncStep {
+	step := syncStep{Release: app.Release, Chart: app.Chart, Version: app.Version, Action: syncNone, app: app}
+
+	chartMeta, err := h.catalogService.GetChartByName(app.Chart)
+	if err != nil {
+		step.Error = err.Error()
+		return step
+	}
+	if app.Version != "" {
+		chartMeta.Version = app.Version
+	}
+	step.chartMeta = chartMeta
+	if depth := values.Depth(app.Values); h.config.MaxValuesDepth > 0 && depth > h.config.MaxValuesDepth {
+		step.Error = fmt.Sprintf("values are nested %d levels deep, the maximum is %d", depth, h.config.MaxValuesDepth)
+		return step
+	}
+
+	if info == nil {
+		step.Action, step.Reason = syncInstall, "not installed"
+		return step
+	}
+	reason, err := h.releaseDrift(app, chartMeta, info)
+	if err != nil {
+		step.Error = err.Error()
+		return step
+	}
+	if reason == "" {
+		step.Reason = "in sync"
+		return step
+	}
+	step.Action, step.Reason = syncUpgrade, reason
+
+	// An upgrade would scale the workloads back up
+	if rec, err := h.scheduleStore.Get(app.Release); err == nil && rec.Asleep {
+		step.Action, step.Reason = syncNone, reason+"; deferred while the release is asleep"
+	}
+	return step
+}
+
+// releaseDrift describes how an installed release differs from its declaration, or returns
+// an empty string when it does not.
+func (h *APIHandler) releaseDrift(app desiredstate.App, chartMeta *appcatalog.ChartMeta, info *helm.ReleaseInfo) (string, error) {
+	if want := path.Base(chartMeta.Chart); info.Chart != want {
+		return fmt.Sprintf("chart is '%s', want '%s'", info.Chart, want), nil
+	}
+	if chartMeta.Version != "" && !versionMatches(chartMeta.Version, info.ChartVersion) {
+		return fmt.Sprintf("chart version is %s, want %s", info.ChartVersion, chartMeta.Version), nil
+	}
+	if info.Status == release.StatusFailed.String() {
+		return "last deployment failed", nil
+	}
+
+	rel, err := h.helmClient.GetRelease(app.Release)
+	if err != nil {
+		return "", err
+	}
+	switch hash := rel.Labels[syncHashLabel]; {
+	case hash == "":
+		return "not deployed by sync yet", nil
+	case hash != app.Hash():
+		return "declaration changed", nil
+	}
+	// Catches values changed out of band; secret references are resolved at install so they
+	// cannot be compared
+	if p, found := values.Mismatch(values.Normalize(app.Values), rel.Config, helm.IsSecretRef); found {
+		return fmt.Sprintf("value '%s' differs", p), nil
+	}
+	return "", nil
+}
+
+// versionMatches reports whether the installed chart version satisfies the desired one,
+// which may be a constraint such as "~1.2".
+func versionMatches(want, installed string) bool {
+	constraint, err := semver.NewConstraint(want)
+	if err != nil {
+		return want == installed
+	}
+	version, err := semver.NewVersion(installed)
+	if err != nil {
+		return want == installed
+	}
+	return constraint.Check(version)
+}
+
+// applySyncStep installs, upgrades or prunes a release.
+func (h *APIHandler) applySyncStep(step syncStep) syncResult {
+	startTime := time.Now()
+	result := syncResult{Release: step.Release, Action: step.Action, Reason: step.Reason}
+
+	var err error
+	switch step.Action {
+	case syncInstall, syncUpgrade:
+		err = h.syncRelease(step)
+	case syncPrune:
+		err = h.pruneRelease(step.Release)
+	}
+
+	result.DurationMs = time.Since(startTime).Milliseconds()
+	if err != nil {
+		log.Printf("Sync: %s of release '%s' failed: %v", step.Action, step.Release, err)
+		result.Outcome = audit.OutcomeFailure
+		result.Error = err.Error()
+	} else {
+		log.Printf("Sync: release '%s' %s done (%s)", step.Release, step.Action, step.Reason)
+		result.Outcome = audit.OutcomeSuccess
+	}
+	return result
+}
+
+// syncRelease installs or upgrades a declared release, labelled with its declaration hash.
+func (h *APIHandler) syncRelease(step syncStep) error {
+	vals, err := h.withStorageClass(step.chartMeta, step.app.StorageClass, step.app.Values)
+	if err != nil {
+		return err
+	}
+	_, err = h.deployRelease(nil, step.chartMeta, step.Release, vals, installActor{User: SyncActor}, deployOptions{
+		Upgrade: step.Action == syncUpgrade,
+		Labels:  map[string]string{syncHashLabel: step.app.Hash()},
+	})
+	return err
+}
+
+// pruneRelease uninstalls an undeclared release, keeping its volumes.
+func (h *APIHandler) pruneRelease(releaseName string) error {
+	startTime := time.Now()
+	_, err := h.helmClient.UninstallRelease(releaseName, helm.UninstallOptions{})
+	if errors.Is(err, helm.ErrReleaseNotFound) {
+		// Uninstalled by other means
+		return nil
+	}
+	h.recordAudit(nil, audit.Entry{Action: audit.ActionUninstall, Actor: SyncActor, Release: releaseName}, startTime, err)
+	if err != nil {
+		return err
+	}
+	h.ForgetRelease(releaseName)
+	if err := h.scheduleStore.Delete(releaseName); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	if err := h.expiryStore.Delete(releaseName); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	return nil
+}
+
+// GetSyncStatusHandler reports what the next sync run would change, computed now, and the
+// result of the last run.
+func (h *APIHandler) GetSyncStatusHandler(c *gin.Context) {
+	if h.config.SyncFile == "" {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Desired-state sync is not configured."})
+		return
+	}
+
+	body := gin.H{
+		"file":     h.config.SyncFile,
+		"interval": h.config.SyncInterval.String(),
+		"prune":    h.config.SyncPrune,
+		"dry_run":  h.config.SyncDryRun,
+		"last_run": h.syncHistory.get(),
+	}
+	steps, err := h.planSync()
+	if err != nil {
+		body["plan_error"] = err.Error()
+	} else {
+		body["plan"] = steps
+		pending := 0
+		for _, step := range steps {
+			if step.Action != syncNone && step.Error == "" {
+				pending++
+			}
+		}
+		body["in_sync"] = pending == 0
+	}
+	c.JSON(http.StatusOK, body)
+}
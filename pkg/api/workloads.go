@@ -0,0 +1,91 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"app-store-api/pkg/audit"
+	"app-store-api/pkg/helm"
+)
+
+// maxReplicas bounds the replicas a release can be scaled to.
+const maxReplicas = 50
+
+// scaleRequest is the body of a scale request.
+type scaleRequest struct {
+	Replicas *int32 `json:"replicas"`
+	Workload string `json:"workload,omitempty"` // Only this Deployment or StatefulSet; every one when empty
+}
+
+// RestartReleaseHandler triggers a rolling restart of the workloads of a release.
+func (h *APIHandler) RestartReleaseHandler(c *gin.Context) {
+	releaseName := c.Param("releaseName")
+	h.runWorkloadAction(c, audit.ActionRestart, func() (*helm.WorkloadReport, error) {
+		return h.helmClient.RestartRelease(releaseName)
+	})
+}
+
+// ScaleReleaseHandler sets the replicas of the Deployments and StatefulSets of a release.
+func (h *APIHandler) ScaleReleaseHandler(c *gin.Context) {
+	releaseName := c.Param("releaseName")
+	var req scaleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid request body: %v", err)})
+		return
+	}
+	if req.Replicas == nil || *req.Replicas < 0 || *req.Replicas > maxReplicas {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("'replicas' must be between 0 and %d.", maxReplicas)})
+		return
+	}
+	h.runWorkloadAction(c, audit.ActionScale, func() (*helm.WorkloadReport, error) {
+		return h.helmClient.ScaleRelease(releaseName, *req.Replicas, req.Workload)
+	})
+}
+
+// SuspendReleaseHandler scales the workloads of a release to zero, remembering their replicas.
+func (h *APIHandler) SuspendReleaseHandler(c *gin.Context) {
+	releaseName := c.Param("releaseName")
+	h.runWorkloadAction(c, audit.ActionSuspend, func() (*helm.WorkloadReport, error) {
+		return h.helmClient.SuspendRelease(releaseName)
+	})
+}
+
+// ResumeReleaseHandler restores the workloads of a suspended release.
+func (h *APIHandler) ResumeReleaseHandler(c *gin.Context) {
+	releaseName := c.Param("releaseName")
+	h.runWorkloadAction(c, audit.ActionResume, func() (*helm.WorkloadReport, error) {
+		return h.helmClient.ResumeRelease(releaseName)
+	})
+}
+
+// runWorkloadAction runs and audits an action on the workloads of a release, and replies with
+// its report. The reply is 500 when the action failed on any workload.
+func (h *APIHandler) runWorkloadAction(c *gin.Context, action audit.Action, run func() (*helm.WorkloadReport, error)) {
+	releaseName := c.Param("releaseName")
+	startTime := time.Now()
+	report, err := run()
+	if err == nil {
+		err = report.Err()
+	}
+	h.recordAudit(c, audit.Entry{Action: action, Release: releaseName}, startTime, err)
+
+	switch {
+	case errors.Is(err, helm.ErrReleaseNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Release '%s' not found.", releaseName)})
+	case errors.Is(err, helm.ErrNoWorkloads):
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Release '%s' has no matching workload.", releaseName)})
+	case report == nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "report": report})
+	default:
+		c.JSON(http.StatusOK, gin.H{
+			"message": fmt.Sprintf("Action '%s' applied to release '%s'.", action, releaseName),
+			"report":  report,
+		})
+	}
+}
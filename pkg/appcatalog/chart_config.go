@@ -14,9 +14,57 @@ type ChartMeta struct {
 	Version     string `json:"version,omitempty" yaml:"version,omitempty"`   // Optional chart version
 	RepoURL     string `json:"repo_url,omitempty" yaml:"repo_url,omitempty"` // Helm repository URL (if applicable)
 	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+	// RequiresApproval makes installs by non-admins wait for an admin decision.
+	RequiresApproval bool `json:"requires_approval,omitempty" yaml:"requires_approval,omitempty"`
+	// SecretValues lists value paths (e.g. "auth.rootPassword") redacted from every response and audit record.
+	SecretValues []string `json:"secret_values,omitempty" yaml:"secret_values,omitempty"`
+	// GeneratedSecrets are credentials generated at install time and injected into the values.
+	GeneratedSecrets []GeneratedSecret `json:"generated_secrets,omitempty" yaml:"generated_secrets,omitempty"`
+	// StorageClassPaths are the value paths (e.g. "persistence.storageClass") receiving the storage class chosen at install.
+	StorageClassPaths []string `json:"storage_class_paths,omitempty" yaml:"storage_class_paths,omitempty"`
+	// NodePortPaths are the value paths (e.g. "service.nodePorts.http") receiving a NodePort reserved for the release.
+	NodePortPaths []string `json:"node_port_paths,omitempty" yaml:"node_port_paths,omitempty"`
+	// Expose describes how the app is published on the ingress base domain.
+	Expose *Expose `json:"expose,omitempty" yaml:"expose,omitempty"`
 	// DefaultValues map[string]interface{} `json:"default_values,omitempty" yaml:"default_values,omitempty"` // Future: default values
 }
 
+// GeneratedSecret describes a credential generated for each install of a chart.
+type GeneratedSecret struct {
+	Path    string `json:"path" yaml:"path"`                           // Value path receiving the secret (e.g., "auth.password")
+	Length  int    `json:"length,omitempty" yaml:"length,omitempty"`   // Defaults to 24
+	Charset string `json:"charset,omitempty" yaml:"charset,omitempty"` // "alphanumeric" (default), "alpha", "numeric", "hex" or "symbols"
+}
+
+// Expose describes how an app is reached through an Ingress. With IngressValues, the
+// chart creates the Ingress itself; without, the API creates one for Service and Port.
+type Expose struct {
+	Service       string         `json:"service,omitempty" yaml:"service,omitempty"` // Service name; "{release}" is replaced by the release name
+	Port          int32          `json:"port,omitempty" yaml:"port,omitempty"`
+	IngressValues *IngressValues `json:"ingress_values,omitempty" yaml:"ingress_values,omitempty"`
+}
+
+// IngressValues maps the ingress settings of a chart to its value paths. Every path is optional.
+type IngressValues struct {
+	Enabled     string `json:"enabled,omitempty" yaml:"enabled,omitempty"`         // Receives true
+	ClassName   string `json:"class_name,omitempty" yaml:"class_name,omitempty"`   // Receives the IngressClass
+	Host        string `json:"host,omitempty" yaml:"host,omitempty"`               // Receives the host name
+	Hosts       string `json:"hosts,omitempty" yaml:"hosts,omitempty"`             // Receives [{host, paths: [{path: /, pathType: Prefix}]}]
+	TLS         string `json:"tls,omitempty" yaml:"tls,omitempty"`                 // Receives true when TLS is enabled
+	TLSHosts    string `json:"tls_hosts,omitempty" yaml:"tls_hosts,omitempty"`     // Receives [{hosts: [host], secretName}] when TLS is enabled
+	Annotations string `json:"annotations,omitempty" yaml:"annotations,omitempty"` // Receives the ingress annotations, merged with existing ones
+}
+
+// SecretPaths returns every value path holding a secret: the declared secret
+// values and the generated credentials.
+func (cm ChartMeta) SecretPaths() []string {
+	paths := append([]string{}, cm.SecretValues...)
+	for _, gs := range cm.GeneratedSecrets {
+		paths = append(paths, gs.Path)
+	}
+	return paths
+}
+
 // ChartRegistry holds the list of configured charts.
 type ChartRegistry struct {
 	Charts []ChartMeta `yaml:"charts"`
@@ -1,8 +1,12 @@
 package appcatalog
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"log"
+	"strings"
 
 	"app-store-api/pkg/helm"
 )
@@ -46,6 +50,16 @@ func NewService(chartConfigPath string, hc *helm.HelmClient) (*Service, error) {
 	}, nil
 }
 
+// Hash identifies the loaded catalog; it changes whenever an entry is added, removed or edited.
+func (s *Service) Hash() string {
+	data, err := json.Marshal(s.charts)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
 func (s *Service) GetAvailableCharts() []ChartMeta {
 	return s.charts
 }
@@ -58,3 +72,14 @@ func (s *Service) GetChartByName(name string) (*ChartMeta, error) {
 	}
 	return nil, fmt.Errorf("chart '%s' not found in configured list", name)
 }
+
+// FindByChartName returns the catalog entry whose chart has the given name (the
+// chart metadata name stored on a release, e.g. "nginx" for "bitnami/nginx").
+func (s *Service) FindByChartName(chartName string) (*ChartMeta, bool) {
+	for _, chart := range s.charts {
+		if chart.Chart == chartName || strings.HasSuffix(chart.Chart, "/"+chartName) {
+			return &chart, true
+		}
+	}
+	return nil, false
+}
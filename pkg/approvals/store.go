@@ -0,0 +1,163 @@
+package approvals
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	requestDataKey = "request.json"
+	componentLabel = "app.kubernetes.io/component"
+	componentValue = "approval-request"
+	statusLabel    = "app-store-api/approval-status"
+	managedByLabel = "app.kubernetes.io/managed-by"
+	managedByValue = "app-store-api"
+	secretPrefix   = "approval-"
+)
+
+// Store persists approval requests as Secrets so they survive restarts. The requested
+// values may hold passwords, hence Secrets rather than ConfigMaps.
+type Store struct {
+	kubeClient kubernetes.Interface
+	namespace  string
+}
+
+// NewStore creates a store keeping its Secrets in namespace.
+func NewStore(kc kubernetes.Interface, namespace string) *Store {
+	return &Store{kubeClient: kc, namespace: namespace}
+}
+
+// Create assigns an ID to the request and stores it as pending.
+func (s *Store) Create(req *Request) error {
+	id, err := newID()
+	if err != nil {
+		return err
+	}
+	req.ID = id
+	req.Status = StatusPending
+	if req.RequestedAt.IsZero() {
+		req.RequestedAt = time.Now().UTC()
+	}
+
+	secret, err := s.toSecret(req)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	created, err := s.kubeClient.CoreV1().Secrets(s.namespace).Create(ctx, secret, metav1.CreateOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to store approval request: %w", err)
+	}
+	req.resourceVersion = created.ResourceVersion
+	return nil
+}
+
+// Get loads a single request.
+func (s *Store) Get(id string) (*Request, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	secret, err := s.kubeClient.CoreV1().Secrets(s.namespace).Get(ctx, secretPrefix+id, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read approval request %s: %w", id, err)
+	}
+	return fromSecret(secret)
+}
+
+// List returns the requests with the given status (all requests when status is empty), oldest first.
+func (s *Store) List(status Status) ([]Request, error) {
+	selector := fmt.Sprintf("%s=%s", componentLabel, componentValue)
+	if status != "" {
+		selector += fmt.Sprintf(",%s=%s", statusLabel, status)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	list, err := s.kubeClient.CoreV1().Secrets(s.namespace).List(ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list approval requests: %w", err)
+	}
+
+	requests := make([]Request, 0, len(list.Items))
+	for i := range list.Items {
+		req, err := fromSecret(&list.Items[i])
+		if err != nil {
+			continue
+		}
+		requests = append(requests, *req)
+	}
+	sort.Slice(requests, func(i, j int) bool {
+		return requests[i].RequestedAt.Before(requests[j].RequestedAt)
+	})
+	return requests, nil
+}
+
+// Update saves the request. It fails with ErrConflict if the request changed since it was read.
+func (s *Store) Update(req *Request) error {
+	secret, err := s.toSecret(req)
+	if err != nil {
+		return err
+	}
+	secret.ResourceVersion = req.resourceVersion
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	updated, err := s.kubeClient.CoreV1().Secrets(s.namespace).Update(ctx, secret, metav1.UpdateOptions{})
+	if apierrors.IsConflict(err) {
+		return ErrConflict
+	} else if apierrors.IsNotFound(err) {
+		return ErrNotFound
+	} else if err != nil {
+		return fmt.Errorf("failed to update approval request %s: %w", req.ID, err)
+	}
+	req.resourceVersion = updated.ResourceVersion
+	return nil
+}
+
+func (s *Store) toSecret(req *Request) (*corev1.Secret, error) {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal approval request: %w", err)
+	}
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      secretPrefix + req.ID,
+			Namespace: s.namespace,
+			Labels: map[string]string{
+				managedByLabel: managedByValue,
+				componentLabel: componentValue,
+				statusLabel:    string(req.Status),
+			},
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: map[string][]byte{requestDataKey: data},
+	}, nil
+}
+
+func fromSecret(secret *corev1.Secret) (*Request, error) {
+	var req Request
+	if err := json.Unmarshal(secret.Data[requestDataKey], &req); err != nil {
+		return nil, fmt.Errorf("failed to decode approval request %s: %w", secret.Name, err)
+	}
+	req.resourceVersion = secret.ResourceVersion
+	return &req, nil
+}
+
+func newID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate approval request ID: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,44 @@
+package approvals
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrNotFound is returned when no approval request has the given ID.
+var ErrNotFound = errors.New("approval request not found")
+
+// ErrConflict is returned when a request was modified concurrently, e.g. by two
+// admins deciding on it at the same time.
+var ErrConflict = errors.New("approval request was modified concurrently")
+
+// Status is the lifecycle state of an approval request.
+type Status string
+
+const (
+	StatusPending    Status = "pending"
+	StatusInstalling Status = "installing" // Approved, install in progress
+	StatusInstalled  Status = "installed"
+	StatusFailed     Status = "failed" // Approved, but the install failed
+	StatusRejected   Status = "rejected"
+)
+
+// Request is an install of a restricted chart waiting for an admin decision.
+type Request struct {
+	ID            string                 `json:"id"`
+	Chart         string                 `json:"chart"` // Catalog name (e.g., "gitea")
+	ReleaseName   string                 `json:"release_name"`
+	Values        map[string]interface{} `json:"values,omitempty"`
+	Justification string                 `json:"justification,omitempty"`
+	TTL           string                 `json:"ttl,omitempty"`        // Lifetime of the release, counted from the approval
+	ExpiresAt     *time.Time             `json:"expires_at,omitempty"` // Expiry of the release
+	RequestedBy   string                 `json:"requested_by"`
+	RequestedAt   time.Time              `json:"requested_at"`
+	Status        Status                 `json:"status"`
+	DecidedBy     string                 `json:"decided_by,omitempty"`
+	DecidedAt     *time.Time             `json:"decided_at,omitempty"`
+	Reason        string                 `json:"reason,omitempty"` // Rejection reason
+	Error         string                 `json:"error,omitempty"`  // Install error, when Status is failed
+
+	resourceVersion string
+}
@@ -0,0 +1,164 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+)
+
+const ringDataKey = "audit.jsonl"
+
+// KubeRingSink keeps the most recent entries in a single ConfigMap or Secret.
+// Once the ring is full, the oldest entries are dropped.
+type KubeRingSink struct {
+	kubeClient kubernetes.Interface
+	namespace  string
+	name       string
+	size       int
+	useSecret  bool
+	mu         sync.Mutex
+}
+
+// NewKubeRingSink creates a ring sink backed by a ConfigMap, or a Secret when useSecret is true.
+func NewKubeRingSink(kc kubernetes.Interface, namespace, name string, size int, useSecret bool) *KubeRingSink {
+	if size <= 0 {
+		size = 500
+	}
+	return &KubeRingSink{kubeClient: kc, namespace: namespace, name: name, size: size, useSecret: useSecret}
+}
+
+// Write appends the entry to the ring, trimming it to the configured size.
+func (s *KubeRingSink) Write(entry Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		data, resourceVersion, err := s.load(ctx)
+		if err != nil {
+			return err
+		}
+		entries := decodeRing(data)
+		entries = append(entries, entry)
+		if len(entries) > s.size {
+			entries = entries[len(entries)-s.size:]
+		}
+		encoded, err := encodeRing(entries)
+		if err != nil {
+			return err
+		}
+		return s.store(ctx, encoded, resourceVersion)
+	})
+}
+
+// Query returns the matching entries currently held in the ring.
+func (s *KubeRingSink) Query(filter Filter) ([]Entry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	data, _, err := s.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return selectEntries(decodeRing(data), filter), nil
+}
+
+// load returns the ring data and the resourceVersion of the backing object.
+// An empty resourceVersion means the object does not exist yet.
+func (s *KubeRingSink) load(ctx context.Context) ([]byte, string, error) {
+	if s.useSecret {
+		secret, err := s.kubeClient.CoreV1().Secrets(s.namespace).Get(ctx, s.name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return nil, "", nil
+		} else if err != nil {
+			return nil, "", fmt.Errorf("failed to read audit secret %s/%s: %w", s.namespace, s.name, err)
+		}
+		return secret.Data[ringDataKey], secret.ResourceVersion, nil
+	}
+
+	cm, err := s.kubeClient.CoreV1().ConfigMaps(s.namespace).Get(ctx, s.name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, "", nil
+	} else if err != nil {
+		return nil, "", fmt.Errorf("failed to read audit configmap %s/%s: %w", s.namespace, s.name, err)
+	}
+	return []byte(cm.Data[ringDataKey]), cm.ResourceVersion, nil
+}
+
+// store creates or updates the backing object. Updates carry the resourceVersion
+// read by load, so concurrent writers from other replicas surface as conflicts.
+func (s *KubeRingSink) store(ctx context.Context, data []byte, resourceVersion string) error {
+	meta := metav1.ObjectMeta{
+		Name:            s.name,
+		Namespace:       s.namespace,
+		ResourceVersion: resourceVersion,
+		Labels: map[string]string{
+			"app.kubernetes.io/managed-by": "app-store-api",
+			"app.kubernetes.io/component":  "audit",
+		},
+	}
+
+	var err error
+	if s.useSecret {
+		secret := &corev1.Secret{ObjectMeta: meta, Data: map[string][]byte{ringDataKey: data}}
+		if resourceVersion != "" {
+			_, err = s.kubeClient.CoreV1().Secrets(s.namespace).Update(ctx, secret, metav1.UpdateOptions{})
+		} else {
+			_, err = s.kubeClient.CoreV1().Secrets(s.namespace).Create(ctx, secret, metav1.CreateOptions{})
+		}
+	} else {
+		cm := &corev1.ConfigMap{ObjectMeta: meta, Data: map[string]string{ringDataKey: string(data)}}
+		if resourceVersion != "" {
+			_, err = s.kubeClient.CoreV1().ConfigMaps(s.namespace).Update(ctx, cm, metav1.UpdateOptions{})
+		} else {
+			_, err = s.kubeClient.CoreV1().ConfigMaps(s.namespace).Create(ctx, cm, metav1.CreateOptions{})
+		}
+	}
+	if apierrors.IsAlreadyExists(err) {
+		// Another writer created the object first; retry as an update.
+		resource := corev1.Resource("configmaps")
+		if s.useSecret {
+			resource = corev1.Resource("secrets")
+		}
+		return apierrors.NewConflict(resource, s.name, err)
+	}
+	return err
+}
+
+func decodeRing(data []byte) []Entry {
+	var entries []Entry
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal(line, &e); err == nil {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+func encodeRing(entries []Entry) ([]byte, error) {
+	var buf bytes.Buffer
+	for _, e := range entries {
+		line, err := json.Marshal(e)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal audit entry: %w", err)
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,60 @@
+package audit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"time"
+
+	"app-store-api/pkg/values"
+)
+
+// Service records audit entries to the configured sink.
+type Service struct {
+	sink Sink
+}
+
+// NewService creates a new audit service writing to sink.
+func NewService(sink Sink) *Service {
+	return &Service{sink: sink}
+}
+
+// Record fills in the entry timestamp and writes it to the sink.
+// Failures are logged rather than returned so auditing never blocks an operation
+// that has already happened.
+func (s *Service) Record(entry Entry) {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now().UTC()
+	}
+	if entry.Outcome == "" {
+		entry.Outcome = OutcomeSuccess
+		if entry.Error != "" {
+			entry.Outcome = OutcomeFailure
+		}
+	}
+	if err := s.sink.Write(entry); err != nil {
+		log.Printf("Error writing audit entry (%s %s by %s): %v", entry.Action, entry.Release, entry.Actor, err)
+	}
+}
+
+// Query returns the entries matching filter, newest first.
+func (s *Service) Query(filter Filter) ([]Entry, error) {
+	return s.sink.Query(filter)
+}
+
+// HashValues returns a stable sha256 of the values after secret paths and
+// secret-looking keys have been redacted, so the hash cannot be used to guess credentials.
+func HashValues(vals map[string]interface{}, secretPaths []string) string {
+	if len(vals) == 0 {
+		return ""
+	}
+	// encoding/json sorts map keys, which makes the output deterministic.
+	data, err := json.Marshal(values.Redact(vals, secretPaths))
+	if err != nil {
+		log.Printf("Warning: Could not marshal values for audit hash: %v", err)
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,53 @@
+package audit
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"k8s.io/client-go/kubernetes"
+
+	"app-store-api/pkg/config"
+)
+
+// ErrQueryNotSupported is returned by sinks that can only write entries.
+var ErrQueryNotSupported = errors.New("audit sink does not support queries")
+
+// Sink stores audit entries.
+type Sink interface {
+	Write(entry Entry) error
+	Query(filter Filter) ([]Entry, error)
+}
+
+// NewSink builds the sink selected by AUDIT_SINK.
+func NewSink(cfg *config.AppConfig, kubeClient kubernetes.Interface) (Sink, error) {
+	switch cfg.AuditSink {
+	case "", "stdout":
+		return NewStdoutSink(), nil
+	case "file":
+		return NewFileSink(cfg.AuditFilePath)
+	case "configmap":
+		return NewKubeRingSink(kubeClient, cfg.AppInstallNamespace, cfg.AuditRingName, cfg.AuditRingSize, false), nil
+	case "secret":
+		return NewKubeRingSink(kubeClient, cfg.AppInstallNamespace, cfg.AuditRingName, cfg.AuditRingSize, true), nil
+	default:
+		return nil, fmt.Errorf("unknown audit sink '%s' (expected stdout, file, configmap or secret)", cfg.AuditSink)
+	}
+}
+
+// selectEntries applies the filter to entries and returns the matches newest first.
+func selectEntries(entries []Entry, filter Filter) []Entry {
+	matched := make([]Entry, 0, len(entries))
+	for _, e := range entries {
+		if filter.Matches(e) {
+			matched = append(matched, e)
+		}
+	}
+	sort.SliceStable(matched, func(i, j int) bool {
+		return matched[i].Time.After(matched[j].Time)
+	})
+	if filter.Limit > 0 && len(matched) > filter.Limit {
+		matched = matched[:filter.Limit]
+	}
+	return matched
+}
@@ -0,0 +1,37 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// StdoutSink prints entries as JSON lines on standard output, for log collectors to pick up.
+type StdoutSink struct {
+	mu sync.Mutex
+}
+
+// NewStdoutSink creates a StdoutSink.
+func NewStdoutSink() *StdoutSink {
+	return &StdoutSink{}
+}
+
+// Write prints one entry.
+func (s *StdoutSink) Write(entry Entry) error {
+	line, err := json.Marshal(struct {
+		Audit Entry `json:"audit"`
+	}{entry})
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit entry: %w", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = fmt.Fprintln(os.Stdout, string(line))
+	return err
+}
+
+// Query is not supported: entries written to stdout cannot be read back.
+func (s *StdoutSink) Query(Filter) ([]Entry, error) {
+	return nil, ErrQueryNotSupported
+}
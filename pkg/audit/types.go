@@ -0,0 +1,71 @@
+package audit
+
+import "time"
+
+// Action identifies the kind of mutating operation being audited.
+type Action string
+
+const (
+	ActionInstall       Action = "install"
+	ActionUninstall     Action = "uninstall"
+	ActionUpgrade       Action = "upgrade"
+	ActionCatalogChange Action = "catalog_change"
+	ActionExec          Action = "exec"
+	ActionRestart       Action = "restart"
+	ActionScale         Action = "scale"
+	ActionSuspend       Action = "suspend"
+	ActionResume        Action = "resume"
+	ActionSchedule      Action = "schedule"
+	ActionExtend        Action = "extend"
+)
+
+// Outcome is the result of an audited operation.
+type Outcome string
+
+const (
+	OutcomeSuccess Outcome = "success"
+	OutcomeFailure Outcome = "failure"
+)
+
+// Entry is a single audit record.
+type Entry struct {
+	Time       time.Time `json:"time"`
+	Action     Action    `json:"action"`
+	Actor      string    `json:"actor"`
+	ApprovedBy string    `json:"approved_by,omitempty"` // Admin who approved the operation, if it required approval
+	SourceIP   string    `json:"source_ip,omitempty"`
+	Chart      string    `json:"chart,omitempty"`
+	Release    string    `json:"release,omitempty"`
+	Target     string    `json:"target,omitempty"`      // Object acted upon within the release, e.g. "pod/container" for exec
+	ValuesHash string    `json:"values_hash,omitempty"` // sha256 of the values with secrets redacted
+	Outcome    Outcome   `json:"outcome"`
+	Error      string    `json:"error,omitempty"`
+	DurationMs int64     `json:"duration_ms"`
+}
+
+// Filter restricts the entries returned by a query.
+// Zero values mean "no restriction".
+type Filter struct {
+	Since   time.Time
+	Until   time.Time
+	Actor   string
+	Release string
+	Limit   int
+}
+
+// Matches reports whether the entry satisfies the filter (ignoring Limit).
+func (f Filter) Matches(e Entry) bool {
+	if !f.Since.IsZero() && e.Time.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && e.Time.After(f.Until) {
+		return false
+	}
+	if f.Actor != "" && e.Actor != f.Actor {
+		return false
+	}
+	if f.Release != "" && e.Release != f.Release {
+		return false
+	}
+	return true
+}
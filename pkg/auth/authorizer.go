@@ -0,0 +1,59 @@
+package auth
+
+import "app-store-api/pkg/config"
+
+// Authorizer decides which identities hold elevated roles.
+type Authorizer struct {
+	admins    role
+	operators role
+}
+
+// role is a set of users and groups.
+type role struct {
+	users  map[string]bool
+	groups []string
+}
+
+func newRole(users, groups []string) role {
+	r := role{users: make(map[string]bool, len(users)), groups: groups}
+	for _, u := range users {
+		r.users[u] = true
+	}
+	return r
+}
+
+// has reports whether the identity holds the role. The anonymous user never does.
+func (r role) has(id Identity) bool {
+	if id.User == "" || id.User == AnonymousUser {
+		return false
+	}
+	if r.users[id.User] {
+		return true
+	}
+	for _, g := range r.groups {
+		if id.InGroup(g) {
+			return true
+		}
+	}
+	return false
+}
+
+// NewAuthorizer creates an Authorizer from ADMIN_USERS/ADMIN_GROUPS and OPERATOR_USERS/OPERATOR_GROUPS.
+func NewAuthorizer(cfg *config.AppConfig) *Authorizer {
+	return &Authorizer{
+		admins:    newRole(cfg.AdminUsers, cfg.AdminGroups),
+		operators: newRole(cfg.OperatorUsers, cfg.OperatorGroups),
+	}
+}
+
+// IsAdmin reports whether the identity may approve restricted installs.
+// The anonymous user is never an admin.
+func (a *Authorizer) IsAdmin(id Identity) bool {
+	return a.admins.has(id)
+}
+
+// IsOperator reports whether the identity may run commands in release containers.
+// Admins are operators.
+func (a *Authorizer) IsOperator(id Identity) bool {
+	return a.operators.has(id) || a.admins.has(id)
+}
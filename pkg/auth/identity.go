@@ -0,0 +1,21 @@
+package auth
+
+// AnonymousUser is the actor name used when a request carries no identity.
+const AnonymousUser = "anonymous"
+
+// Identity describes the caller of an API request.
+// It is populated from headers set by the authenticating proxy in front of the API.
+type Identity struct {
+	User   string   `json:"user"`
+	Groups []string `json:"groups,omitempty"`
+}
+
+// InGroup reports whether the identity is a member of the given group.
+func (id Identity) InGroup(group string) bool {
+	for _, g := range id.Groups {
+		if g == group {
+			return true
+		}
+	}
+	return false
+}
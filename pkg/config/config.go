@@ -2,14 +2,19 @@ package config
 
 import (
 	"log"
+	"net"
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
+	_ "time/tzdata" // SCHEDULE_TIMEZONE must resolve in images without a zoneinfo database
 
 	"k8s.io/client-go/util/homedir"
 )
 
+const defaultCORSAllowedHeaders = "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With"
+
 // AppConfig holds the application configuration.
 type AppConfig struct {
 	ListenPort          string
@@ -19,6 +24,86 @@ type AppConfig struct {
 	HelmDriver          string
 	HelmTimeout         time.Duration
 	ChartConfigPath     string // Path to a YAML/JSON file defining available charts
+
+	// Identity headers set by the authenticating proxy in front of the API. They are only read
+	// from connections of TrustedProxyCIDRs or presenting a verified TLS client certificate.
+	AuthUserHeader    string
+	AuthGroupsHeader  string
+	TrustedProxyCIDRs []*net.IPNet
+
+	// Roles
+	AdminUsers  []string // Users allowed to approve restricted installs
+	AdminGroups []string // Groups allowed to approve restricted installs
+	// Operators may open a shell in release containers; admins are always operators
+	OperatorUsers  []string
+	OperatorGroups []string
+
+	// Audit log
+	AuditSink     string // "stdout", "file", "configmap" or "secret"
+	AuditFilePath string // Used by the "file" sink
+	AuditRingName string // ConfigMap/Secret name used by the "configmap" and "secret" sinks
+	AuditRingSize int    // Maximum number of entries kept by the ring sinks
+
+	// CORS
+	CORSAllowedOrigins   []string // "*" allows any origin (credentials are then never allowed)
+	CORSAllowedMethods   []string
+	CORSAllowedHeaders   []string
+	CORSAllowCredentials bool
+
+	// TLS serving. TLS is enabled when both TLSCertFile and TLSKeyFile are set.
+	TLSCertFile     string
+	TLSKeyFile      string
+	TLSClientCAFile string // Enables mTLS client verification when set
+	TLSClientAuth   string // "request" or "require" (default "require" when a client CA is set)
+
+	// Limits on mutating endpoints
+	RateLimitPerMinute  int   // Sustained requests per minute per identity and per IP; 0 disables rate limiting
+	RateLimitBurst      int   // Requests allowed in a burst before throttling
+	MaxRequestBodyBytes int64 // Maximum size of a request body
+	MaxValuesDepth      int   // Maximum nesting depth of InstallRequest.Values
+
+	// Metrics
+	MetricsPollInterval    time.Duration // Interval of the shared metrics collector
+	MetricsCollectReleases bool          // Whether the collector also gathers per-release metrics for the stream
+	MetricsHistoryFile     string        // Optional file the metrics history is persisted to
+
+	// Install preflight
+	EnforcePreflight bool // Block installs whose pods the preflight check predicts cannot be scheduled
+
+	// Automatic Ingress exposure
+	IngressBaseDomain    string // Wildcard domain apps are published on; empty disables exposure
+	IngressTenant        string // Optional label between the release and the base domain
+	IngressClass         string // IngressClass of the created ingresses; empty uses the cluster default
+	IngressTLS           bool   // Serve the app hosts over TLS
+	IngressClusterIssuer string // cert-manager ClusterIssuer issuing the certificates; empty uses the controller default certificate
+
+	// App proxy
+	ProxyDomain string // Apps are proxied on "<release>.<ProxyDomain>" instead of under the API origin; empty keeps them under /api
+
+	// Web terminal
+	ExecIdleTimeout time.Duration // Exec sessions without input or output for this long are closed
+	ExecMaxDuration time.Duration // Exec sessions are closed after this long regardless of activity
+
+	// Sleep schedules
+	ScheduleLocation *time.Location // Time zone the release schedules are read in
+	ScheduleInterval time.Duration  // How often the schedules are applied
+
+	// Ephemeral releases
+	ExpiryCheckInterval time.Duration // How often expired releases are looked for
+	ExpiryWebhookURL    string        // Receives the expiry warnings and notices; empty disables them
+	ExpiryWarningBefore time.Duration // How long before its expiry a release is warned about
+	ExpiryMaxTTL        time.Duration // Longest expiry allowed at install or extension; 0 for no limit
+
+	// Desired-state sync
+	SyncFile     string        // apps.yaml file the releases are reconciled with; empty disables sync
+	SyncInterval time.Duration // How often the releases are compared with the file
+	SyncPrune    bool          // Uninstall the releases the file does not declare
+	SyncDryRun   bool          // Only compute the plan, without applying it
+
+	// NodePort allocation
+	NodePortRangeStart int32 // First port handed out to catalog node port paths
+	NodePortRangeEnd   int32 // Last port, inclusive
+	NodePortConfigMap  string
 }
 
 // LoadConfig loads configuration from environment variables or defaults.
@@ -36,14 +121,64 @@ func LoadConfig() (*AppConfig, error) {
 		helmTimeoutSec = 300
 	}
 
+	nodePortStart, nodePortEnd := getEnvPortRange("NODEPORT_RANGE", 30000, 32767)
+
 	return &AppConfig{
-		ListenPort:          getEnv("APP_PORT", "8080"),
-		GinMode:             getEnv("GIN_MODE", "debug"), // "release" for production
-		AppInstallNamespace: getEnv("APP_INSTALL_NAMESPACE", "app-store-apps"),
-		KubeconfigPath:      getEnv("KUBECONFIG", defaultKubeconfig),
-		HelmDriver:          getEnv("HELM_DRIVER", "secret"), // "secret", "configmap", or "memory"
-		HelmTimeout:         time.Duration(helmTimeoutSec) * time.Second,
-		ChartConfigPath:     getEnv("CHART_CONFIG_PATH", "charts.yaml"), // Example path
+		ListenPort:             getEnv("APP_PORT", "8080"),
+		GinMode:                getEnv("GIN_MODE", "debug"), // "release" for production
+		AppInstallNamespace:    getEnv("APP_INSTALL_NAMESPACE", "app-store-apps"),
+		KubeconfigPath:         getEnv("KUBECONFIG", defaultKubeconfig),
+		HelmDriver:             getEnv("HELM_DRIVER", "secret"), // "secret", "configmap", or "memory"
+		HelmTimeout:            time.Duration(helmTimeoutSec) * time.Second,
+		ChartConfigPath:        getEnv("CHART_CONFIG_PATH", "charts.yaml"), // Example path
+		AuthUserHeader:         getEnv("AUTH_USER_HEADER", "X-Remote-User"),
+		AuthGroupsHeader:       getEnv("AUTH_GROUPS_HEADER", "X-Remote-Groups"),
+		TrustedProxyCIDRs:      getEnvCIDRs("TRUSTED_PROXY_CIDRS"),
+		AdminUsers:             getEnvList("ADMIN_USERS", ""),
+		AdminGroups:            getEnvList("ADMIN_GROUPS", ""),
+		OperatorUsers:          getEnvList("OPERATOR_USERS", ""),
+		OperatorGroups:         getEnvList("OPERATOR_GROUPS", ""),
+		AuditSink:              getEnv("AUDIT_SINK", "stdout"),
+		AuditFilePath:          getEnv("AUDIT_FILE_PATH", "audit.jsonl"),
+		AuditRingName:          getEnv("AUDIT_RING_NAME", "app-store-audit"),
+		AuditRingSize:          getEnvInt("AUDIT_RING_SIZE", 500),
+		CORSAllowedOrigins:     getEnvList("CORS_ALLOWED_ORIGINS", "*"),
+		CORSAllowedMethods:     getEnvList("CORS_ALLOWED_METHODS", "GET, POST, PUT, DELETE, OPTIONS"),
+		CORSAllowedHeaders:     getEnvList("CORS_ALLOWED_HEADERS", defaultCORSAllowedHeaders),
+		CORSAllowCredentials:   getEnvBool("CORS_ALLOW_CREDENTIALS", false),
+		TLSCertFile:            getEnv("TLS_CERT_FILE", ""),
+		TLSKeyFile:             getEnv("TLS_KEY_FILE", ""),
+		TLSClientCAFile:        getEnv("TLS_CLIENT_CA_FILE", ""),
+		TLSClientAuth:          getEnv("TLS_CLIENT_AUTH", "require"),
+		RateLimitPerMinute:     getEnvInt("RATE_LIMIT_PER_MINUTE", 10),
+		RateLimitBurst:         getEnvInt("RATE_LIMIT_BURST", 3),
+		MaxRequestBodyBytes:    int64(getEnvInt("MAX_REQUEST_BODY_BYTES", 1<<20)), // 1 MiB
+		MaxValuesDepth:         getEnvInt("MAX_VALUES_DEPTH", 20),
+		MetricsPollInterval:    getEnvDuration("METRICS_POLL_INTERVAL", 2*time.Second),
+		MetricsCollectReleases: getEnvBool("METRICS_COLLECT_RELEASES", true),
+		MetricsHistoryFile:     getEnv("METRICS_HISTORY_FILE", ""),
+		EnforcePreflight:       getEnvBool("ENFORCE_PREFLIGHT", false),
+		IngressBaseDomain:      getEnv("INGRESS_BASE_DOMAIN", ""),
+		IngressTenant:          getEnv("INGRESS_TENANT", ""),
+		IngressClass:           getEnv("INGRESS_CLASS", ""),
+		IngressTLS:             getEnvBool("INGRESS_TLS", true),
+		IngressClusterIssuer:   getEnv("INGRESS_CLUSTER_ISSUER", ""),
+		ProxyDomain:            strings.Trim(getEnv("PROXY_DOMAIN", ""), "."),
+		ExecIdleTimeout:        getEnvDuration("EXEC_IDLE_TIMEOUT", 10*time.Minute),
+		ExecMaxDuration:        getEnvDuration("EXEC_MAX_DURATION", 2*time.Hour),
+		ScheduleLocation:       getEnvLocation("SCHEDULE_TIMEZONE", "UTC"),
+		ScheduleInterval:       getEnvDuration("SCHEDULE_INTERVAL", time.Minute),
+		ExpiryCheckInterval:    getEnvDuration("EXPIRY_CHECK_INTERVAL", time.Minute),
+		ExpiryWebhookURL:       getEnv("EXPIRY_WEBHOOK_URL", ""),
+		ExpiryWarningBefore:    getEnvDuration("EXPIRY_WARNING_BEFORE", 30*time.Minute),
+		ExpiryMaxTTL:           getEnvDuration("EXPIRY_MAX_TTL", 0),
+		SyncFile:               getEnv("SYNC_FILE", ""),
+		SyncInterval:           getEnvDuration("SYNC_INTERVAL", 5*time.Minute),
+		SyncPrune:              getEnvBool("SYNC_PRUNE", false),
+		SyncDryRun:             getEnvBool("SYNC_DRY_RUN", false),
+		NodePortRangeStart:     nodePortStart,
+		NodePortRangeEnd:       nodePortEnd,
+		NodePortConfigMap:      getEnv("NODEPORT_CONFIGMAP", "app-store-nodeports"),
 	}, nil
 }
 
@@ -53,3 +188,106 @@ func getEnv(key, fallback string) string {
 	}
 	return fallback
 }
+
+func getEnvInt(key string, fallback int) int {
+	valueStr, exists := os.LookupEnv(key)
+	if !exists {
+		return fallback
+	}
+	value, err := strconv.Atoi(valueStr)
+	if err != nil {
+		log.Printf("Warning: Invalid %s value '%s', using default %d. Error: %v", key, valueStr, fallback, err)
+		return fallback
+	}
+	return value
+}
+
+func getEnvBool(key string, fallback bool) bool {
+	valueStr, exists := os.LookupEnv(key)
+	if !exists {
+		return fallback
+	}
+	value, err := strconv.ParseBool(valueStr)
+	if err != nil {
+		log.Printf("Warning: Invalid %s value '%s', using default %t. Error: %v", key, valueStr, fallback, err)
+		return fallback
+	}
+	return value
+}
+
+// getEnvDuration reads a Go duration such as "2s" or "1m30s".
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	valueStr, exists := os.LookupEnv(key)
+	if !exists {
+		return fallback
+	}
+	value, err := time.ParseDuration(valueStr)
+	if err != nil || value <= 0 {
+		log.Printf("Warning: Invalid %s value '%s', using default %v. Error: %v", key, valueStr, fallback, err)
+		return fallback
+	}
+	return value
+}
+
+// getEnvLocation reads an IANA time zone name such as "Europe/Paris".
+func getEnvLocation(key, fallback string) *time.Location {
+	name := getEnv(key, fallback)
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		log.Printf("Warning: Invalid %s value '%s', using default %s. Error: %v", key, name, fallback, err)
+		location, _ = time.LoadLocation(fallback)
+	}
+	return location
+}
+
+// getEnvList reads a comma-separated list, trimming blanks around each item.
+func getEnvList(key, fallback string) []string {
+	var items []string
+	for _, item := range strings.Split(getEnv(key, fallback), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// getEnvCIDRs reads a comma-separated list of CIDRs such as "10.42.0.0/16". A bare IP
+// stands for itself. Invalid entries are skipped with a warning.
+func getEnvCIDRs(key string) []*net.IPNet {
+	var nets []*net.IPNet
+	for _, item := range getEnvList(key, "") {
+		if !strings.Contains(item, "/") {
+			if ip := net.ParseIP(item); ip != nil {
+				bits := 8 * len(ip.To16())
+				if ip.To4() != nil {
+					ip, bits = ip.To4(), 32
+				}
+				nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+				continue
+			}
+		}
+		_, ipNet, err := net.ParseCIDR(item)
+		if err != nil {
+			log.Printf("Warning: Invalid %s entry '%s', ignoring it. Error: %v", key, item, err)
+			continue
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets
+}
+
+// getEnvPortRange reads an inclusive port range such as "30000-32767".
+func getEnvPortRange(key string, fallbackStart, fallbackEnd int32) (int32, int32) {
+	valueStr, exists := os.LookupEnv(key)
+	if !exists {
+		return fallbackStart, fallbackEnd
+	}
+	startStr, endStr, found := strings.Cut(valueStr, "-")
+	start, errStart := strconv.ParseInt(strings.TrimSpace(startStr), 10, 32)
+	end, errEnd := strconv.ParseInt(strings.TrimSpace(endStr), 10, 32)
+	if !found || errStart != nil || errEnd != nil || start <= 0 || end > 65535 || start > end {
+		log.Printf("Warning: Invalid %s value '%s', using default %d-%d.", key, valueStr, fallbackStart, fallbackEnd)
+		return fallbackStart, fallbackEnd
+	}
+	return int32(start), int32(end)
+}
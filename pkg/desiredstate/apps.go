@@ -0,0 +1,80 @@
+package desiredstate
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// App is a release declared in the desired-state file.
+type App struct {
+	Release      string                 `json:"release" yaml:"release"`                                 // Defaults to the chart name
+	Chart        string                 `json:"chart" yaml:"chart"`                                     // Catalog name of the chart (e.g., "nginx")
+	Version      string                 `json:"version,omitempty" yaml:"version,omitempty"`             // Defaults to the catalog version
+	StorageClass string                 `json:"storage_class,omitempty" yaml:"storage_class,omitempty"` // For charts declaring storage class paths
+	Values       map[string]interface{} `json:"values,omitempty" yaml:"values,omitempty"`
+}
+
+// File is the desired-state file:
+//
+//	apps:
+//	  - release: wiki
+//	    chart: dokuwiki
+//	    version: 16.2.4
+//	    values:
+//	      dokuwikiWikiName: Team wiki
+type File struct {
+	Apps []App `yaml:"apps"`
+}
+
+// Hash identifies the declaration of an app. It changes whenever the chart, the version,
+// the storage class or the values change, including when a value is removed.
+func (a App) Hash() string {
+	data, _ := json.Marshal(a)
+	sum := sha256.Sum256(data)
+	// Release labels are limited to 63 characters
+	return hex.EncodeToString(sum[:16])
+}
+
+// Load reads the desired-state file. Unknown fields, apps without a chart, values with
+// non-string keys and release names declared twice are rejected.
+func Load(filePath string) ([]App, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read desired-state file %s: %w", filePath, err)
+	}
+
+	var file File
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&file); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to unmarshal desired-state file %s: %w", filePath, err)
+	}
+
+	seen := make(map[string]bool, len(file.Apps))
+	for i := range file.Apps {
+		app := &file.Apps[i]
+		if app.Chart == "" {
+			return nil, fmt.Errorf("app %d of %s has no chart", i+1, filePath)
+		}
+		if app.Release == "" {
+			app.Release = app.Chart
+		}
+		if _, err := json.Marshal(app.Values); err != nil {
+			// Helm values must have string keys
+			return nil, fmt.Errorf("values of release '%s' in %s are invalid: %w", app.Release, filePath, err)
+		}
+		if seen[app.Release] {
+			return nil, fmt.Errorf("release '%s' is declared twice in %s", app.Release, filePath)
+		}
+		seen[app.Release] = true
+	}
+	return file.Apps, nil
+}
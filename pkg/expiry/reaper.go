@@ -0,0 +1,192 @@
+package expiry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"app-store-api/pkg/audit"
+	"app-store-api/pkg/helm"
+)
+
+// ReaperActor is the audit actor of the uninstalls made by the reaper.
+const ReaperActor = "reaper"
+
+// Webhook events.
+const (
+	EventExpiring = "release_expiring"
+	EventExpired  = "release_expired"
+)
+
+// WebhookEvent is the JSON body posted to the expiry webhook.
+type WebhookEvent struct {
+	Event     string    `json:"event"`
+	Release   string    `json:"release"`
+	ExpiresAt time.Time `json:"expires_at"`
+	SetBy     string    `json:"set_by,omitempty"`     // Who installed or last extended the release
+	ExtendURL string    `json:"extend_url,omitempty"` // API path extending the release, for release_expiring
+}
+
+// Options configures a reaper.
+type Options struct {
+	WebhookURL    string        // Receives the expiry warnings; empty disables them
+	WarningBefore time.Duration // How long before the expiry the warning is sent
+	// Cleanup is called with the name of every uninstalled release; it may be nil.
+	Cleanup func(releaseName string)
+}
+
+// Reaper uninstalls the releases whose expiry has passed.
+type Reaper struct {
+	store   *Store
+	helm    *helm.HelmClient
+	audit   *audit.Service // May be nil
+	options Options
+	client  *http.Client
+}
+
+// NewReaper creates a reaper of the expiries in store.
+func NewReaper(store *Store, hc *helm.HelmClient, auditService *audit.Service, opts Options) *Reaper {
+	return &Reaper{store: store, helm: hc, audit: auditService, options: opts, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// Run checks the expiries every interval until ctx is cancelled.
+func (r *Reaper) Run(ctx context.Context, interval time.Duration) {
+	log.Printf("Starting release reaper (interval %v)", interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		r.reap(ctx, time.Now())
+		select {
+		case <-ctx.Done():
+			log.Println("Release reaper stopped.")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// reap uninstalls the expired releases and warns about those expiring soon.
+func (r *Reaper) reap(ctx context.Context, now time.Time) {
+	records, err := r.store.List()
+	if err != nil {
+		log.Printf("Reaper: %v", err)
+		return
+	}
+	for i := range records {
+		rec := &records[i]
+		switch {
+		case !now.Before(rec.ExpiresAt):
+			r.uninstall(ctx, rec, now)
+		case rec.WarnedAt == nil && r.options.WebhookURL != "" && now.Add(r.options.WarningBefore).After(rec.ExpiresAt):
+			r.warn(ctx, rec, now)
+		}
+	}
+}
+
+// uninstall removes an expired release with its volumes, then its expiry.
+func (r *Reaper) uninstall(ctx context.Context, rec *Record, now time.Time) {
+	// The release may have been extended since the listing
+	current, err := r.store.Get(rec.Release)
+	if err != nil || now.Before(current.ExpiresAt) {
+		return
+	}
+	rel, err := r.helm.GetRelease(rec.Release)
+	if err != nil && !errors.Is(err, helm.ErrReleaseNotFound) {
+		// Retried on the next run
+		log.Printf("Reaper: could not read release '%s': %v", rec.Release, err)
+		return
+	}
+	if rel == nil || !current.Matches(rel) {
+		// Uninstalled, or installed again without an expiry since: the record is stale
+		log.Printf("Reaper: expiry of release '%s' does not match its current install, dropping it", rec.Release)
+		if err := r.store.Delete(rec.Release); err != nil {
+			log.Printf("Reaper: %v", err)
+		}
+		return
+	}
+	log.Printf("Reaper: release '%s' expired at %s, uninstalling", rec.Release, rec.ExpiresAt.Format(time.RFC3339))
+	_, err = r.helm.UninstallRelease(rec.Release, helm.UninstallOptions{DeletePVCs: true})
+	if errors.Is(err, helm.ErrReleaseNotFound) {
+		// Uninstalled by other means
+		err = nil
+	}
+	r.record(audit.Entry{Time: now, Action: audit.ActionUninstall, Actor: ReaperActor, Release: rec.Release}, now, err)
+	if err != nil {
+		// Retried on the next run
+		log.Printf("Reaper: could not uninstall release '%s': %v", rec.Release, err)
+		return
+	}
+
+	if err := r.store.Delete(rec.Release); err != nil {
+		log.Printf("Reaper: %v", err)
+	}
+	if r.options.Cleanup != nil {
+		r.options.Cleanup(rec.Release)
+	}
+	if r.options.WebhookURL != "" {
+		if err := r.post(ctx, WebhookEvent{Event: EventExpired, Release: rec.Release, ExpiresAt: rec.ExpiresAt, SetBy: rec.SetBy}); err != nil {
+			log.Printf("Reaper: could not notify the expiry of release '%s': %v", rec.Release, err)
+		}
+	}
+}
+
+// warn posts the expiry warning of a release and records it, so it is sent once per expiry.
+func (r *Reaper) warn(ctx context.Context, rec *Record, now time.Time) {
+	event := WebhookEvent{
+		Event:     EventExpiring,
+		Release:   rec.Release,
+		ExpiresAt: rec.ExpiresAt,
+		SetBy:     rec.SetBy,
+		ExtendURL: fmt.Sprintf("/api/releases/%s/extend", rec.Release),
+	}
+	if err := r.post(ctx, event); err != nil {
+		// Retried on the next run, until the release expires
+		log.Printf("Reaper: could not send the expiry warning of release '%s': %v", rec.Release, err)
+		return
+	}
+	rec.WarnedAt = &now
+	if err := r.store.Save(rec); err != nil {
+		log.Printf("Reaper: could not record the expiry warning of release '%s': %v", rec.Release, err)
+	}
+}
+
+// post sends an event to the webhook.
+func (r *Reaper) post(ctx context.Context, event WebhookEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.options.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook answered %s", resp.Status)
+	}
+	return nil
+}
+
+func (r *Reaper) record(entry audit.Entry, startTime time.Time, opErr error) {
+	if r.audit == nil {
+		return
+	}
+	entry.DurationMs = time.Since(startTime).Milliseconds()
+	if opErr != nil {
+		entry.Outcome = audit.OutcomeFailure
+		entry.Error = opErr.Error()
+	} else {
+		entry.Outcome = audit.OutcomeSuccess
+	}
+	r.audit.Record(entry)
+}
@@ -0,0 +1,71 @@
+package expiry
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestReaperWarnsOncePerExpiry(t *testing.T) {
+	var events []WebhookEvent
+	failing := true
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		var event WebhookEvent
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			t.Errorf("webhook body: %v", err)
+		}
+		events = append(events, event)
+	}))
+	defer webhook.Close()
+
+	store := NewStore(fake.NewSimpleClientset(), "apps")
+	now := time.Date(2026, time.January, 5, 9, 0, 0, 0, time.UTC)
+	for name, expiresIn := range map[string]time.Duration{"wiki": 20 * time.Minute, "blog": 3 * time.Hour} {
+		if err := store.Save(&Record{Release: name, ExpiresAt: now.Add(expiresIn), SetBy: "alice"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Releases are only uninstalled once expired, so the reaper needs no Helm client here
+	reaper := NewReaper(store, nil, nil, Options{WebhookURL: webhook.URL, WarningBefore: 30 * time.Minute})
+
+	// A failed delivery is retried on the next run
+	reaper.reap(t.Context(), now)
+	if rec, err := store.Get("wiki"); err != nil || rec.WarnedAt != nil {
+		t.Fatalf("after a failed delivery, the record is %+v (error %v), want no warning recorded", rec, err)
+	}
+
+	failing = false
+	for minute := 1; minute <= 5; minute++ {
+		reaper.reap(t.Context(), now.Add(time.Duration(minute)*time.Minute))
+	}
+	if len(events) != 1 {
+		t.Fatalf("the webhook received %d events, want a single warning: %+v", len(events), events)
+	}
+	want := WebhookEvent{Event: EventExpiring, Release: "wiki", ExpiresAt: now.Add(20 * time.Minute), SetBy: "alice", ExtendURL: "/api/releases/wiki/extend"}
+	if got := events[0]; got.Event != want.Event || got.Release != want.Release || !got.ExpiresAt.Equal(want.ExpiresAt) ||
+		got.SetBy != want.SetBy || got.ExtendURL != want.ExtendURL {
+		t.Errorf("warning = %+v, want %+v", got, want)
+	}
+
+	// Extended: the warning of the new expiry is sent in its turn
+	rec, err := store.Get("wiki")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec.ExpiresAt, rec.WarnedAt, rec.Extended = now.Add(2*time.Hour), nil, 1
+	if err := store.Save(rec); err != nil {
+		t.Fatal(err)
+	}
+	reaper.reap(t.Context(), now.Add(100*time.Minute))
+	if len(events) != 2 || events[1].Release != "wiki" || !events[1].ExpiresAt.Equal(now.Add(2*time.Hour)) {
+		t.Errorf("after the extension, the webhook received %+v", events)
+	}
+}
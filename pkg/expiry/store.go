@@ -0,0 +1,145 @@
+package expiry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	expiryDataKey        = "expiry.json"
+	componentLabel       = "app.kubernetes.io/component"
+	componentValue       = "expiry"
+	managedByLabel       = "app.kubernetes.io/managed-by"
+	managedByValue       = "app-store-api"
+	releaseInstanceLabel = "app.kubernetes.io/instance"
+	configMapPrefix      = "expiry-"
+)
+
+// Store persists the release expiries as ConfigMaps so they survive restarts.
+type Store struct {
+	kubeClient kubernetes.Interface
+	namespace  string
+}
+
+// NewStore creates a store keeping its ConfigMaps in namespace.
+func NewStore(kc kubernetes.Interface, namespace string) *Store {
+	return &Store{kubeClient: kc, namespace: namespace}
+}
+
+// Get loads the expiry of a release.
+func (s *Store) Get(releaseName string) (*Record, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	cm, err := s.kubeClient.CoreV1().ConfigMaps(s.namespace).Get(ctx, configMapPrefix+releaseName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read expiry of release '%s': %w", releaseName, err)
+	}
+	return fromConfigMap(cm)
+}
+
+// List returns every expiry, soonest first.
+func (s *Store) List() ([]Record, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	list, err := s.kubeClient.CoreV1().ConfigMaps(s.namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("%s=%s", componentLabel, componentValue),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list expiries: %w", err)
+	}
+
+	records := make([]Record, 0, len(list.Items))
+	for i := range list.Items {
+		rec, err := fromConfigMap(&list.Items[i])
+		if err != nil {
+			continue
+		}
+		records = append(records, *rec)
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].ExpiresAt.Before(records[j].ExpiresAt) })
+	return records, nil
+}
+
+// Save creates or updates the expiry of a release. An update fails with ErrConflict if the
+// expiry changed since it was read; a record that was not read overwrites the stored one.
+func (s *Store) Save(rec *Record) error {
+	cm, err := s.toConfigMap(rec)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	configMaps := s.kubeClient.CoreV1().ConfigMaps(s.namespace)
+
+	if rec.resourceVersion == "" {
+		created, err := configMaps.Create(ctx, cm, metav1.CreateOptions{})
+		if err == nil {
+			rec.resourceVersion = created.ResourceVersion
+			return nil
+		}
+		if !apierrors.IsAlreadyExists(err) {
+			return fmt.Errorf("failed to store expiry of release '%s': %w", rec.Release, err)
+		}
+	}
+
+	cm.ResourceVersion = rec.resourceVersion
+	updated, err := configMaps.Update(ctx, cm, metav1.UpdateOptions{})
+	if apierrors.IsConflict(err) {
+		return ErrConflict
+	} else if apierrors.IsNotFound(err) {
+		return ErrNotFound
+	} else if err != nil {
+		return fmt.Errorf("failed to update expiry of release '%s': %w", rec.Release, err)
+	}
+	rec.resourceVersion = updated.ResourceVersion
+	return nil
+}
+
+// Delete removes the expiry of a release. It is not an error if there is none.
+func (s *Store) Delete(releaseName string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	err := s.kubeClient.CoreV1().ConfigMaps(s.namespace).Delete(ctx, configMapPrefix+releaseName, metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete expiry of release '%s': %w", releaseName, err)
+	}
+	return nil
+}
+
+func (s *Store) toConfigMap(rec *Record) (*corev1.ConfigMap, error) {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal expiry: %w", err)
+	}
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      configMapPrefix + rec.Release,
+			Namespace: s.namespace,
+			Labels: map[string]string{
+				managedByLabel:       managedByValue,
+				componentLabel:       componentValue,
+				releaseInstanceLabel: rec.Release,
+			},
+		},
+		Data: map[string]string{expiryDataKey: string(data)},
+	}, nil
+}
+
+func fromConfigMap(cm *corev1.ConfigMap) (*Record, error) {
+	var rec Record
+	if err := json.Unmarshal([]byte(cm.Data[expiryDataKey]), &rec); err != nil {
+		return nil, fmt.Errorf("failed to decode expiry %s: %w", cm.Name, err)
+	}
+	rec.resourceVersion = cm.ResourceVersion
+	return &rec, nil
+}
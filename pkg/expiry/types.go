@@ -0,0 +1,64 @@
+package expiry
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"helm.sh/helm/v3/pkg/release"
+)
+
+// ErrNotFound is returned when a release has no expiry.
+var ErrNotFound = errors.New("expiry not found")
+
+// ErrConflict is returned when an expiry was modified concurrently.
+var ErrConflict = errors.New("expiry was modified concurrently")
+
+// Record is the expiry of an ephemeral release.
+type Record struct {
+	Release   string     `json:"release"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	SetBy     string     `json:"set_by"`
+	SetAt     time.Time  `json:"set_at"`
+	WarnedAt  *time.Time `json:"warned_at,omitempty"` // When the expiry warning was sent, for the current expiry
+	Extended  int        `json:"extended"`            // Number of extensions
+	// Revision and FirstDeployed identify the install the expiry was set on, so the record of
+	// an uninstalled release never reaps a later install under the same name
+	Revision      int       `json:"revision,omitempty"`
+	FirstDeployed time.Time `json:"first_deployed"`
+
+	resourceVersion string
+}
+
+// Matches reports whether rel is the install the expiry was set on and is still installed.
+// Records without a revision match any installed release.
+func (r *Record) Matches(rel *release.Release) bool {
+	if rel.Info == nil || rel.Info.Status == release.StatusUninstalled || rel.Info.Status == release.StatusUninstalling {
+		return false
+	}
+	if r.Revision == 0 {
+		return true
+	}
+	return rel.Version >= r.Revision && rel.Info.FirstDeployed.Unix() == r.FirstDeployed.Unix()
+}
+
+// Deadline returns the expiry given by either a TTL such as "4h" or an absolute time, from now.
+// It is an error to give both, or neither, or an expiry that is not in the future.
+func Deadline(ttl string, expiresAt *time.Time, now time.Time) (time.Time, error) {
+	switch {
+	case ttl != "" && expiresAt != nil:
+		return time.Time{}, errors.New("give either 'ttl' or 'expires_at', not both")
+	case ttl != "":
+		d, err := time.ParseDuration(ttl)
+		if err != nil || d <= 0 {
+			return time.Time{}, fmt.Errorf("invalid 'ttl' '%s', expected a positive duration such as '4h'", ttl)
+		}
+		return now.Add(d).UTC(), nil
+	case expiresAt != nil:
+		if !expiresAt.After(now) {
+			return time.Time{}, errors.New("'expires_at' must be in the future")
+		}
+		return expiresAt.UTC(), nil
+	}
+	return time.Time{}, errors.New("give 'ttl' or 'expires_at'")
+}
@@ -0,0 +1,87 @@
+package expiry
+
+import (
+	"testing"
+	"time"
+
+	"helm.sh/helm/v3/pkg/release"
+	helmtime "helm.sh/helm/v3/pkg/time"
+)
+
+// installed is the release Helm reports after installing wiki at firstDeployed.
+func installed(firstDeployed time.Time) *release.Release {
+	return &release.Release{
+		Name:    "wiki",
+		Version: 1,
+		Info:    &release.Info{Status: release.StatusDeployed, FirstDeployed: helmtime.Time{Time: firstDeployed}},
+	}
+}
+
+func TestRecordFollowsTheInstallItWasSetOn(t *testing.T) {
+	installedAt := time.Date(2026, time.January, 5, 9, 0, 0, 0, time.UTC)
+	rel := installed(installedAt)
+	// The record is stored as JSON, so FirstDeployed comes back without the monotonic clock
+	// and possibly in another zone
+	rec := &Record{Release: "wiki", Revision: rel.Version, FirstDeployed: installedAt.In(time.FixedZone("CET", 3600))}
+	if !rec.Matches(rel) {
+		t.Fatal("the record does not match the install it was set on")
+	}
+
+	// Upgrades, failed ones included, keep the expiry
+	rel.Version, rel.Info.Status = 2, release.StatusDeployed
+	if !rec.Matches(rel) {
+		t.Error("the record no longer matches after an upgrade")
+	}
+	rel.Version, rel.Info.Status = 3, release.StatusFailed
+	if !rec.Matches(rel) {
+		t.Error("the record no longer matches after a failed upgrade")
+	}
+
+	// Uninstalled, with its history kept
+	for _, status := range []release.Status{release.StatusUninstalling, release.StatusUninstalled} {
+		rel.Info.Status = status
+		if rec.Matches(rel) {
+			t.Errorf("the record matches a release that is %s", status)
+		}
+	}
+
+	// Installed again under the same name an hour later: the old record must not reap it
+	if rec.Matches(installed(installedAt.Add(time.Hour))) {
+		t.Error("the record matches a later install")
+	}
+	// A rollback of a reinstalled release down to the revision the record was set on
+	later := installed(installedAt.Add(time.Hour))
+	later.Version = 3
+	rec.Revision = 4
+	if rec.Matches(later) {
+		t.Error("the record matches an older revision")
+	}
+
+	// Records stored before the revision was kept match whatever is installed
+	legacy := &Record{Release: "wiki"}
+	if !legacy.Matches(installed(installedAt.Add(time.Hour))) || legacy.Matches(&release.Release{Name: "wiki"}) {
+		t.Error("a record without revision must match any installed release with an info")
+	}
+}
+
+func TestDeadline(t *testing.T) {
+	now := time.Date(2026, time.January, 5, 9, 0, 0, 0, time.FixedZone("CET", 3600))
+	tomorrow := now.Add(24 * time.Hour)
+
+	if got, err := Deadline("90m", nil, now); err != nil || !got.Equal(now.Add(90*time.Minute)) || got.Location() != time.UTC {
+		t.Errorf("Deadline(90m) = %v, %v, want 90 minutes from now in UTC", got, err)
+	}
+	if got, err := Deadline("", &tomorrow, now); err != nil || !got.Equal(tomorrow) {
+		t.Errorf("Deadline(expires_at) = %v, %v, want %v", got, err, tomorrow)
+	}
+
+	past, same := now.Add(-time.Second), now
+	for _, req := range []struct {
+		ttl       string
+		expiresAt *time.Time
+	}{{"4h", &tomorrow}, {"", nil}, {"4 hours", nil}, {"0s", nil}, {"-1h", nil}, {"", &past}, {"", &same}} {
+		if got, err := Deadline(req.ttl, req.expiresAt, now); err == nil {
+			t.Errorf("Deadline(%q, %v) = %v, want an error", req.ttl, req.expiresAt, got)
+		}
+	}
+}
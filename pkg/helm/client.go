@@ -3,25 +3,32 @@ package helm
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log" // Consider replacing with a structured logger in a real app
 	"os"
 	"os/exec"
+	"path"
 	"strings"
 	"sync"
 	"time"
 
 	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart"
 	"helm.sh/helm/v3/pkg/chart/loader"
 	"helm.sh/helm/v3/pkg/cli"
 	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage/driver"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/cli-runtime/pkg/genericclioptions" // Needed for ConfigFlags
 	"k8s.io/client-go/kubernetes"
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
 	"app-store-api/pkg/config"
+	"app-store-api/pkg/telemetry"
 )
 
 // HelmClient interacts with Helm and Kubernetes.
@@ -30,6 +37,7 @@ type HelmClient struct {
 	settings     *cli.EnvSettings // Still useful for RepositoryCache, etc.
 	actionConfig *action.Configuration
 	kubeClient   kubernetes.Interface
+	nodePorts    *NodePortAllocator
 	repoUpdateMu sync.Mutex
 }
 
@@ -63,6 +71,7 @@ func NewHelmClient(cfg *config.AppConfig, kubeClientset kubernetes.Interface) (*
 		settings:     settings,
 		actionConfig: actionCfg,
 		kubeClient:   kubeClientset, // Use the passed clientset
+		nodePorts:    NewNodePortAllocator(kubeClientset, cfg.AppInstallNamespace, cfg.NodePortConfigMap, cfg.NodePortRangeStart, cfg.NodePortRangeEnd),
 	}
 
 	// Namespace check (optional, good to have)
@@ -109,7 +118,9 @@ func (hc *HelmClient) UpdateRepos(charts []ChartDefinition) error {
 		// though repo commands are usually global or use specific config files.
 		// cmd.Env = append(cmd.Env, "HELM_NAMESPACE="+hc.config.AppInstallNamespace)
 
-		if output, err := cmd.CombinedOutput(); err != nil {
+		output, err := cmd.CombinedOutput()
+		telemetry.ObserveRepoRefresh(repoName, err)
+		if err != nil {
 			log.Printf("Error adding/updating repo %s with Helm CLI: %v\nOutput: %s", repoName, err, string(output))
 		} else {
 			log.Printf("Repo %s added/updated successfully via Helm CLI.", repoName)
@@ -125,7 +136,9 @@ func (hc *HelmClient) UpdateRepos(charts []ChartDefinition) error {
 			cmd.Env = append(cmd.Env, "KUBECONFIG="+hc.settings.KubeConfig)
 		}
 
-		if output, err := cmd.CombinedOutput(); err != nil {
+		output, err := cmd.CombinedOutput()
+		telemetry.ObserveRepoRefresh("all", err)
+		if err != nil {
 			log.Printf("Warning: Error updating Helm repositories via Helm CLI: %v\nOutput: %s", err, string(output))
 			// Do not return error here, as it might be a transient issue for one repo.
 			// The application can often proceed.
@@ -136,39 +149,159 @@ func (hc *HelmClient) UpdateRepos(charts []ChartDefinition) error {
 	return nil
 }
 
-// InstallChart installs a Helm chart.
-func (hc *HelmClient) InstallChart(chartDef ChartDefinition, releaseName string, values map[string]interface{}) (*release.Release, error) {
+// CheckInstallable returns ErrReleaseExists when a release named releaseName is installed.
+// replace is true when the name belongs to a release uninstalled with its history kept, which
+// can be installed again under the same name.
+func (hc *HelmClient) CheckInstallable(releaseName string) (replace bool, err error) {
+	histClient := action.NewHistory(hc.actionConfig)
+	histClient.Max = 1
+	history, err := histClient.Run(releaseName)
+	if err != nil {
+		if strings.Contains(err.Error(), "release: not found") {
+			return false, nil
+		}
+		return false, fmt.Errorf("error checking history for release %s: %w", releaseName, err)
+	}
+	if len(history) == 0 {
+		return false, nil
+	}
+	latest := history[0]
+	for _, r := range history {
+		if r.Version > latest.Version {
+			latest = r
+		}
+	}
+	if latest.Info.Status != release.StatusUninstalled {
+		return false, fmt.Errorf("%w: release '%s' already exists in namespace '%s'", ErrReleaseExists, releaseName, hc.config.AppInstallNamespace)
+	}
+	return true, nil
+}
+
+// InstallChart installs a Helm chart. Labels are stored on the release record; they may be nil.
+func (hc *HelmClient) InstallChart(chartDef ChartDefinition, releaseName string, values map[string]interface{}, labels map[string]string) (rel *release.Release, err error) {
 	if releaseName == "" {
 		releaseName = chartDef.Name
 	}
+	done := telemetry.StartHelmOperation("install")
+	// Label with the bare chart name ("nginx" for "bitnami/nginx"), as found in release metadata
+	defer func() { done(path.Base(chartDef.Chart), err) }()
 
-	histClient := action.NewHistory(hc.actionConfig)
-	histClient.Max = 1
-	if history, err := histClient.Run(releaseName); err == nil && len(history) > 0 {
-		return nil, fmt.Errorf("release '%s' already exists in namespace '%s'", releaseName, hc.config.AppInstallNamespace)
-	} else if err != nil && !strings.Contains(err.Error(), "release: not found") {
-		return nil, fmt.Errorf("error checking history for release %s: %w", releaseName, err)
+	client := action.NewInstall(hc.actionConfig)
+	if client.Replace, err = hc.CheckInstallable(releaseName); err != nil {
+		return nil, err
 	}
 
-	client := action.NewInstall(hc.actionConfig)
 	client.Namespace = hc.config.AppInstallNamespace // Target namespace for chart resources
 	client.ReleaseName = releaseName
 	client.Version = chartDef.Version
 	client.Wait = true
 	client.Timeout = hc.config.HelmTimeout
+	client.Labels = labels
+
+	chartRequested, err := hc.loadChart(chartDef, client.ChartPathOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Installing chart '%s' as release '%s' in namespace '%s'", chartRequested.Name(), releaseName, hc.config.AppInstallNamespace)
+	rel, err = client.Run(chartRequested, values)
+	if err != nil {
+		return nil, fmt.Errorf("failed to install chart '%s': %w", chartRequested.Name(), err)
+	}
+
+	log.Printf("Successfully installed chart '%s' (version %s) as release '%s'", rel.Chart.Metadata.Name, rel.Chart.Metadata.Version, rel.Name)
+	return rel, nil
+}
+
+// UpgradeRelease upgrades a release to chartDef with values. The values replace those of the
+// previous revision instead of being merged into them. Labels are merged into those of the release.
+func (hc *HelmClient) UpgradeRelease(chartDef ChartDefinition, releaseName string, values map[string]interface{}, labels map[string]string) (rel *release.Release, err error) {
+	done := telemetry.StartHelmOperation("upgrade")
+	defer func() { done(path.Base(chartDef.Chart), err) }()
+
+	client := action.NewUpgrade(hc.actionConfig)
+	client.Namespace = hc.config.AppInstallNamespace
+	client.Version = chartDef.Version
+	client.Wait = true
+	client.Timeout = hc.config.HelmTimeout
+	client.ResetValues = true
+	client.Labels = labels
+
+	chartRequested, err := hc.loadChart(chartDef, client.ChartPathOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Upgrading release '%s' to chart '%s' in namespace '%s'", releaseName, chartRequested.Name(), hc.config.AppInstallNamespace)
+	rel, err = client.Run(releaseName, chartRequested, values)
+	if errors.Is(err, driver.ErrNoDeployedReleases) || errors.Is(err, driver.ErrReleaseNotFound) {
+		return nil, ErrReleaseNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to upgrade release '%s': %w", releaseName, err)
+	}
+
+	log.Printf("Successfully upgraded release '%s' to chart '%s' (version %s), revision %d", rel.Name, rel.Chart.Metadata.Name, rel.Chart.Metadata.Version, rel.Version)
+	return rel, nil
+}
+
+// OwnerLabel holds, on a Helm release, OwnerLabelValue of the user who installed it.
+const OwnerLabel = "app-store-api/owner"
+
+// OwnerLabelValue encodes a user name as a label value: user names may hold characters
+// labels do not accept, such as "@", and be longer than 63 characters.
+func OwnerLabelValue(user string) string {
+	sum := sha256.Sum256([]byte(user))
+	return hex.EncodeToString(sum[:16])
+}
 
+// GetRelease returns the latest revision of a release, with the values it was installed with.
+func (hc *HelmClient) GetRelease(releaseName string) (*release.Release, error) {
+	rel, err := action.NewGet(hc.actionConfig).Run(releaseName)
+	if errors.Is(err, driver.ErrReleaseNotFound) {
+		return nil, ErrReleaseNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get release '%s': %w", releaseName, err)
+	}
+	return rel, nil
+}
+
+// RenderChart renders the manifest a chart would install as releaseName, without
+// creating anything. Hooks are not included.
+func (hc *HelmClient) RenderChart(chartDef ChartDefinition, releaseName string, values map[string]interface{}) (string, error) {
+	if releaseName == "" {
+		releaseName = chartDef.Name
+	}
+	client := action.NewInstall(hc.actionConfig)
+	client.Namespace = hc.config.AppInstallNamespace
+	client.ReleaseName = releaseName
+	client.Version = chartDef.Version
+	// A client-side dry run still reads the cluster capabilities, but unlike ClientOnly
+	// it does not swap the Kubernetes client of the shared action configuration.
+	client.DryRun = true
+	client.DryRunOption = "client"
+	client.Replace = true
+	client.SkipCRDs = true
+
+	chartRequested, err := hc.loadChart(chartDef, client.ChartPathOptions)
+	if err != nil {
+		return "", err
+	}
+	rel, err := client.Run(chartRequested, values)
+	if err != nil {
+		return "", fmt.Errorf("failed to render chart '%s': %w", chartRequested.Name(), err)
+	}
+	return rel.Manifest, nil
+}
+
+// loadChart locates and loads a chart, refreshing its repository once if it cannot be found.
+func (hc *HelmClient) loadChart(chartDef ChartDefinition, chartPathOptions action.ChartPathOptions) (*chart.Chart, error) {
 	// Use hc.settings for LocateChart as it contains repository configurations
-	chartPathOptions := client.ChartPathOptions
 	chartPathOptions.Version = chartDef.Version // Ensure version is set for locating
-	// Set settings for ChartPathOptions to use repository config
-	// This is crucial for LocateChart to find charts in repositories.
-	// client.ChartPathOptions.SetEnvSettings(hc.settings) // This method doesn't exist.
-	// Instead, LocateChart itself takes settings.
 
 	log.Printf("Locating chart '%s' version '%s'...", chartDef.Chart, chartDef.Version)
 	cp, err := chartPathOptions.LocateChart(chartDef.Chart, hc.settings) // Pass hc.settings here
 	if err != nil {
-		log.Printf("Error locating chart %s (version %s): %v. Attempting repo update before retry.", chartDef.Chart, client.Version, err)
+		log.Printf("Error locating chart %s (version %s): %v. Attempting repo update before retry.", chartDef.Chart, chartDef.Version, err)
 		if errUpdate := hc.UpdateRepos([]ChartDefinition{chartDef}); errUpdate != nil {
 			log.Printf("Repo update failed during chart location for %s: %v", chartDef.Chart, errUpdate)
 		}
@@ -183,15 +316,7 @@ func (hc *HelmClient) InstallChart(chartDef ChartDefinition, releaseName string,
 	if err != nil {
 		return nil, fmt.Errorf("failed to load chart from path %s: %w", cp, err)
 	}
-
-	log.Printf("Installing chart '%s' as release '%s' in namespace '%s'", chartRequested.Name(), releaseName, hc.config.AppInstallNamespace)
-	rel, err := client.Run(chartRequested, values)
-	if err != nil {
-		return nil, fmt.Errorf("failed to install chart '%s': %w", chartRequested.Name(), err)
-	}
-
-	log.Printf("Successfully installed chart '%s' (version %s) as release '%s'", rel.Chart.Metadata.Name, rel.Chart.Metadata.Version, rel.Name)
-	return rel, nil
+	return chartRequested, nil
 }
 
 // ListInstalledReleases lists all releases in the configured namespace.
@@ -209,8 +334,19 @@ func (hc *HelmClient) ListInstalledReleases() ([]ReleaseInfo, error) {
 		return []ReleaseInfo{}, nil
 	}
 
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	resolver, err := hc.newEndpointResolver(ctx, hc.config.AppInstallNamespace)
+	if err != nil {
+		log.Printf("Warning: Could not resolve release URLs: %v", err)
+	}
+
 	for _, rel := range results {
-		nodePorts := hc.getReleaseNodePorts(rel.Name, rel.Namespace)
+		var urls []AccessURL
+		nodePorts := map[string]int32{}
+		if resolver != nil {
+			urls, nodePorts = resolver.resolve(rel.Name)
+		}
 		releasesInfo = append(releasesInfo, ReleaseInfo{
 			Name:         rel.Name,
 			Namespace:    rel.Namespace,
@@ -221,59 +357,12 @@ func (hc *HelmClient) ListInstalledReleases() ([]ReleaseInfo, error) {
 			ChartVersion: rel.Chart.Metadata.Version,
 			AppVersion:   rel.Chart.Metadata.AppVersion,
 			NodePorts:    nodePorts,
+			URLs:         urls,
 		})
 	}
 	return releasesInfo, nil
 }
 
-func (hc *HelmClient) getReleaseNodePorts(releaseName, namespace string) map[string]int32 {
-	nodePorts := make(map[string]int32)
-	labelSelector := fmt.Sprintf("app.kubernetes.io/instance=%s", releaseName)
-
-	serviceList, err := hc.kubeClient.CoreV1().Services(namespace).List(context.TODO(), metav1.ListOptions{
-		LabelSelector: labelSelector,
-	})
-	if err != nil {
-		log.Printf("Warning: Could not list services for release '%s' in namespace '%s': %v", releaseName, namespace, err)
-		return nodePorts
-	}
-
-	for _, service := range serviceList.Items {
-		if service.Spec.Type == "NodePort" || service.Spec.Type == "LoadBalancer" {
-			for _, port := range service.Spec.Ports {
-				if port.NodePort > 0 {
-					portName := port.Name
-					if portName == "" {
-						portName = fmt.Sprintf("%d", port.Port)
-					}
-					nodePorts[portName] = port.NodePort
-				}
-			}
-			if len(nodePorts) > 0 {
-				break
-			}
-		}
-	}
-	return nodePorts
-}
-
-// UninstallRelease uninstalls a Helm release.
-func (hc *HelmClient) UninstallRelease(releaseName string) (*release.UninstallReleaseResponse, error) {
-	uninstallClient := action.NewUninstall(hc.actionConfig)
-	uninstallClient.Timeout = hc.config.HelmTimeout
-
-	log.Printf("Uninstalling release '%s' from namespace '%s'", releaseName, hc.config.AppInstallNamespace)
-	res, err := uninstallClient.Run(releaseName)
-	if err != nil {
-		if strings.Contains(err.Error(), "release: not found") {
-			return nil, fmt.Errorf("release '%s' not found in namespace '%s'", releaseName, hc.config.AppInstallNamespace)
-		}
-		return nil, fmt.Errorf("failed to uninstall release '%s': %w", releaseName, err)
-	}
-	log.Printf("Successfully uninstalled release '%s'", releaseName)
-	return res, nil
-}
-
 // GetReleaseStatus retrieves the status of a specific release.
 func (hc *HelmClient) GetReleaseStatus(releaseName string) (map[string]interface{}, error) {
 	cmd := exec.Command("helm", "status", releaseName, "-n", hc.config.AppInstallNamespace, "-o", "json")
@@ -0,0 +1,200 @@
+package helm
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"app-store-api/pkg/values"
+)
+
+const (
+	defaultCredentialLength = 24
+	// RequestedByAnnotation records who installed the release owning a generated credential.
+	RequestedByAnnotation = "app-store-api/requested-by"
+)
+
+// ErrNoCredentials is returned when a release has no generated credentials.
+var ErrNoCredentials = errors.New("release has no generated credentials")
+
+// ErrCredentialsOwned is returned when the credentials Secret of a release name was stored
+// for another user, e.g. by a failed install attempt.
+var ErrCredentialsOwned = errors.New("the credentials of this release name belong to another user")
+
+var credentialCharsets = map[string]string{
+	"alphanumeric": "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789",
+	"alpha":        "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz",
+	"numeric":      "0123456789",
+	"hex":          "0123456789abcdef",
+	"symbols":      "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789!#%+,-.:=?@^_~",
+}
+
+// credentialsSecretName is the Secret holding the generated credentials of a release.
+func credentialsSecretName(releaseName string) string {
+	return releaseName + "-app-store-credentials"
+}
+
+// ReleaseCredentials holds the generated credentials of a release, keyed by value path.
+type ReleaseCredentials struct {
+	Release     string            `json:"release"`
+	RequestedBy string            `json:"requested_by,omitempty"`
+	Credentials map[string]string `json:"credentials"`
+}
+
+// GenerateCredentials generates the credentials described by specs, stores them in a
+// Secret labelled with the release, and returns a copy of vals with them injected.
+// Paths already set in vals are left alone. Credentials already stored for the release
+// (e.g. from a failed install attempt) are reused so a retry keeps the same passwords, and
+// the other credentials of the Secret are kept. An existing Secret keeps its owner: on install,
+// it is only reused by requestedBy, and ErrCredentialsOwned is returned for any other user;
+// on upgrade, it is reused whoever upgrades, and requestedBy only owns a Secret created then.
+func (hc *HelmClient) GenerateCredentials(releaseName string, specs []CredentialSpec, vals map[string]interface{}, requestedBy string, upgrade bool) (map[string]interface{}, error) {
+	if len(specs) == 0 {
+		return vals, nil
+	}
+	out := values.Copy(vals)
+	if out == nil {
+		out = make(map[string]interface{})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	secrets := hc.kubeClient.CoreV1().Secrets(hc.config.AppInstallNamespace)
+	existing, err := secrets.Get(ctx, credentialsSecretName(releaseName), metav1.GetOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return nil, fmt.Errorf("failed to read credentials of release '%s': %w", releaseName, err)
+	} else if err != nil {
+		existing = nil
+	}
+	if existing != nil && !upgrade && existing.Annotations[RequestedByAnnotation] != requestedBy {
+		return nil, fmt.Errorf("%w: release '%s'", ErrCredentialsOwned, releaseName)
+	}
+
+	data := make(map[string][]byte)
+	generated := 0
+	if existing != nil {
+		for path, value := range existing.Data {
+			data[path] = value
+		}
+	}
+	for _, spec := range specs {
+		if _, set := values.Get(out, spec.Path); set {
+			continue
+		}
+		var credential string
+		if data[spec.Path] != nil {
+			credential = string(data[spec.Path])
+		} else {
+			if credential, err = generateCredential(spec); err != nil {
+				return nil, err
+			}
+			data[spec.Path] = []byte(credential)
+			generated++
+		}
+		if !values.Set(out, spec.Path, credential) {
+			return nil, fmt.Errorf("cannot inject generated credential at value path '%s'", spec.Path)
+		}
+	}
+	if generated == 0 {
+		// Every credential was reused, or set by the values
+		return out, nil
+	}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      credentialsSecretName(releaseName),
+			Namespace: hc.config.AppInstallNamespace,
+			Labels: map[string]string{
+				releaseInstanceLabel:           releaseName,
+				"app.kubernetes.io/managed-by": "app-store-api",
+				"app.kubernetes.io/component":  "credentials",
+			},
+			Annotations: map[string]string{RequestedByAnnotation: requestedBy},
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: data,
+	}
+	if existing != nil {
+		secret.Annotations = existing.Annotations
+		secret.ResourceVersion = existing.ResourceVersion
+		_, err = secrets.Update(ctx, secret, metav1.UpdateOptions{})
+	} else {
+		_, err = secrets.Create(ctx, secret, metav1.CreateOptions{})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to store generated credentials of release '%s': %w", releaseName, err)
+	}
+	log.Printf("Stored %d generated credential(s) for release '%s'", generated, releaseName)
+	return out, nil
+}
+
+// GetReleaseCredentials returns the generated credentials of a release.
+func (hc *HelmClient) GetReleaseCredentials(releaseName string) (*ReleaseCredentials, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	secret, err := hc.kubeClient.CoreV1().Secrets(hc.config.AppInstallNamespace).Get(ctx, credentialsSecretName(releaseName), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, ErrNoCredentials
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read credentials of release '%s': %w", releaseName, err)
+	}
+
+	creds := &ReleaseCredentials{
+		Release:     releaseName,
+		RequestedBy: secret.Annotations[RequestedByAnnotation],
+		Credentials: make(map[string]string, len(secret.Data)),
+	}
+	for path, value := range secret.Data {
+		creds.Credentials[path] = string(value)
+	}
+	return creds, nil
+}
+
+// deleteReleaseCredentials removes the generated credentials of an uninstalled release.
+// It reports whether a credentials Secret was deleted.
+func (hc *HelmClient) deleteReleaseCredentials(releaseName string) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	err := hc.kubeClient.CoreV1().Secrets(hc.config.AppInstallNamespace).Delete(ctx, credentialsSecretName(releaseName), metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		log.Printf("Warning: Could not delete generated credentials of release '%s': %v", releaseName, err)
+	}
+	return err == nil
+}
+
+func generateCredential(spec CredentialSpec) (string, error) {
+	charsetName := spec.Charset
+	if charsetName == "" {
+		charsetName = "alphanumeric"
+	}
+	charset, ok := credentialCharsets[charsetName]
+	if !ok {
+		return "", fmt.Errorf("unknown charset '%s' for generated credential '%s'", spec.Charset, spec.Path)
+	}
+	length := spec.Length
+	if length <= 0 {
+		length = defaultCredentialLength
+	}
+
+	buf := make([]byte, length)
+	max := big.NewInt(int64(len(charset)))
+	for i := range buf {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", fmt.Errorf("failed to generate credential: %w", err)
+		}
+		buf[i] = charset[n.Int64()]
+	}
+	return string(buf), nil
+}
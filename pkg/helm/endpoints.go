@@ -0,0 +1,214 @@
+package helm
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// AccessURL is an address a release can be reached at from outside the cluster.
+type AccessURL struct {
+	URL     string `json:"url"`
+	Kind    string `json:"kind"` // "ingress", "loadbalancer" or "nodeport"
+	Service string `json:"service,omitempty"`
+	Port    string `json:"port,omitempty"` // Service port name, or number when unnamed
+	Ingress string `json:"ingress,omitempty"`
+}
+
+// endpointResolver computes the access URLs of releases from a single listing of the
+// Services, Ingresses and Nodes, shared by every release.
+type endpointResolver struct {
+	services    []corev1.Service
+	ingresses   []networkingv1.Ingress
+	nodeAddress string // Address NodePorts are reached at; empty when no node is ready
+}
+
+// newEndpointResolver lists the objects needed to resolve the URLs of the releases of namespace.
+func (hc *HelmClient) newEndpointResolver(ctx context.Context, namespace string) (*endpointResolver, error) {
+	services, err := hc.kubeClient.CoreV1().Services(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list services in namespace %s: %w", namespace, err)
+	}
+	ingresses, err := hc.kubeClient.NetworkingV1().Ingresses(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ingresses in namespace %s: %w", namespace, err)
+	}
+	nodes, err := hc.kubeClient.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list nodes: %w", err)
+	}
+	return &endpointResolver{
+		services:    services.Items,
+		ingresses:   ingresses.Items,
+		nodeAddress: pickNodeAddress(nodes.Items),
+	}, nil
+}
+
+// pickNodeAddress returns the ExternalIP of the first ready node that has one, or else
+// the InternalIP of the first ready node. Nodes are taken in name order so the choice is stable.
+func pickNodeAddress(nodes []corev1.Node) string {
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Name < nodes[j].Name })
+	internal := ""
+	for _, n := range nodes {
+		if !nodeReady(&n) {
+			continue
+		}
+		for _, addr := range n.Status.Addresses {
+			switch addr.Type {
+			case corev1.NodeExternalIP:
+				return addr.Address
+			case corev1.NodeInternalIP:
+				if internal == "" {
+					internal = addr.Address
+				}
+			}
+		}
+	}
+	return internal
+}
+
+func nodeReady(n *corev1.Node) bool {
+	for _, cond := range n.Status.Conditions {
+		if cond.Type == corev1.NodeReady {
+			return cond.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// belongsTo reports whether an object is part of a release, by its instance label or Helm annotation.
+func belongsTo(meta *metav1.ObjectMeta, releaseName string) bool {
+	return meta.Labels[releaseInstanceLabel] == releaseName || meta.Annotations[releaseNameAnnotation] == releaseName
+}
+
+// resolve returns the access URLs of a release, ingresses first, and its node ports by port name.
+func (r *endpointResolver) resolve(releaseName string) ([]AccessURL, map[string]int32) {
+	var urls []AccessURL
+	nodePorts := make(map[string]int32)
+
+	for i := range r.ingresses {
+		ing := &r.ingresses[i]
+		if !belongsTo(&ing.ObjectMeta, releaseName) {
+			continue
+		}
+		tlsHosts := make(map[string]bool)
+		for _, t := range ing.Spec.TLS {
+			for _, host := range t.Hosts {
+				tlsHosts[host] = true
+			}
+		}
+		for _, rule := range ing.Spec.Rules {
+			host := rule.Host
+			if host == "" {
+				// A rule without a host answers on any address of the ingress controller
+				if host = ingressAddress(ing); host == "" {
+					host = r.nodeAddress
+				}
+				if host == "" {
+					continue
+				}
+			}
+			scheme := "http"
+			if tlsHosts[rule.Host] {
+				scheme = "https"
+			}
+			paths := []string{"/"}
+			if rule.HTTP != nil && len(rule.HTTP.Paths) > 0 {
+				paths = paths[:0]
+				for _, p := range rule.HTTP.Paths {
+					paths = append(paths, p.Path)
+				}
+			}
+			for _, p := range paths {
+				if !strings.HasPrefix(p, "/") {
+					p = "/" + p
+				}
+				urls = append(urls, AccessURL{URL: fmt.Sprintf("%s://%s%s", scheme, host, p), Kind: "ingress", Ingress: ing.Name})
+			}
+		}
+	}
+
+	for i := range r.services {
+		svc := &r.services[i]
+		if !belongsTo(&svc.ObjectMeta, releaseName) {
+			continue
+		}
+		for _, port := range svc.Spec.Ports {
+			portName := port.Name
+			if portName == "" {
+				portName = strconv.Itoa(int(port.Port))
+			}
+			scheme := portScheme(port)
+
+			switch svc.Spec.Type {
+			case corev1.ServiceTypeLoadBalancer:
+				for _, lb := range svc.Status.LoadBalancer.Ingress {
+					host := lb.IP
+					if lb.Hostname != "" {
+						host = lb.Hostname
+					}
+					urls = append(urls, AccessURL{URL: formatURL(scheme, host, port.Port), Kind: "loadbalancer", Service: svc.Name, Port: portName})
+				}
+				fallthrough
+			case corev1.ServiceTypeNodePort:
+				if port.NodePort == 0 {
+					continue
+				}
+				key := portName
+				if _, taken := nodePorts[key]; taken {
+					key = svc.Name + "/" + portName
+				}
+				nodePorts[key] = port.NodePort
+				if r.nodeAddress != "" {
+					urls = append(urls, AccessURL{URL: formatURL(scheme, r.nodeAddress, port.NodePort), Kind: "nodeport", Service: svc.Name, Port: portName})
+				}
+			}
+		}
+	}
+	return urls, nodePorts
+}
+
+// ingressAddress returns the address published in the status of an ingress.
+func ingressAddress(ing *networkingv1.Ingress) string {
+	for _, lb := range ing.Status.LoadBalancer.Ingress {
+		if lb.Hostname != "" {
+			return lb.Hostname
+		}
+		if lb.IP != "" {
+			return lb.IP
+		}
+	}
+	return ""
+}
+
+// portScheme guesses whether a service port speaks HTTPS from its name, app protocol or number.
+func portScheme(port corev1.ServicePort) string {
+	if port.AppProtocol != nil && strings.EqualFold(*port.AppProtocol, "https") {
+		return "https"
+	}
+	if strings.Contains(strings.ToLower(port.Name), "https") || port.Port == 443 || port.Port == 8443 {
+		return "https"
+	}
+	return "http"
+}
+
+func formatURL(scheme, host string, port int32) string {
+	if (scheme == "http" && port == 80) || (scheme == "https" && port == 443) {
+		return fmt.Sprintf("%s://%s", scheme, bracketIPv6(host))
+	}
+	return fmt.Sprintf("%s://%s", scheme, net.JoinHostPort(host, strconv.Itoa(int(port))))
+}
+
+func bracketIPv6(host string) string {
+	if strings.Contains(host, ":") {
+		return "[" + host + "]"
+	}
+	return host
+}
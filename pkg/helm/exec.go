@@ -0,0 +1,105 @@
+package helm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/httpstream"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/remotecommand"
+)
+
+// defaultContainerAnnotation names the container kubectl execs into when none is given.
+const defaultContainerAnnotation = "kubectl.kubernetes.io/default-container"
+
+// ErrPodNotFound is returned when a pod does not exist or does not belong to the release.
+var ErrPodNotFound = errors.New("pod not found in release")
+
+// ExecOptions selects the command run by ExecInPod and the streams attached to it.
+type ExecOptions struct {
+	Container string   // Empty for the pod default container
+	Command   []string // Command and arguments
+	Stdin     io.Reader
+	Stdout    io.Writer // Receives stderr too, a TTY merges both streams
+	// Resize receives the terminal size changes; it may be nil.
+	Resize remotecommand.TerminalSizeQueue
+}
+
+// ResolveExecContainer checks that the pod belongs to the release and is running, and returns
+// the container to exec into: the requested one, or the pod default container.
+func (hc *HelmClient) ResolveExecContainer(ctx context.Context, releaseName, podName, container string) (string, error) {
+	pod, err := hc.kubeClient.CoreV1().Pods(hc.config.AppInstallNamespace).Get(ctx, podName, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return "", ErrPodNotFound
+		}
+		return "", fmt.Errorf("failed to get pod '%s': %w", podName, err)
+	}
+	if pod.Labels[releaseInstanceLabel] != releaseName {
+		return "", ErrPodNotFound
+	}
+	if pod.Status.Phase != corev1.PodRunning {
+		return "", fmt.Errorf("pod '%s' is %s, not Running", podName, pod.Status.Phase)
+	}
+
+	if container == "" {
+		container = pod.Annotations[defaultContainerAnnotation]
+	}
+	if container == "" && len(pod.Spec.Containers) > 0 {
+		container = pod.Spec.Containers[0].Name
+	}
+	for _, c := range pod.Spec.Containers {
+		if c.Name == container {
+			return container, nil
+		}
+	}
+	return "", fmt.Errorf("pod '%s' has no container '%s'", podName, container)
+}
+
+// ExecInPod runs a command with a TTY in a container of a release pod until the command exits
+// or ctx is cancelled. It talks WebSocket to the API server and falls back to SPDY when the
+// server does not support it. Call ResolveExecContainer first.
+func (hc *HelmClient) ExecInPod(ctx context.Context, podName string, opts ExecOptions) error {
+	restConfig, err := hc.actionConfig.RESTClientGetter.ToRESTConfig()
+	if err != nil {
+		return fmt.Errorf("failed to get REST config: %w", err)
+	}
+
+	req := hc.kubeClient.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Namespace(hc.config.AppInstallNamespace).
+		Name(podName).
+		SubResource("exec").
+		VersionedParams(&corev1.PodExecOptions{
+			Container: opts.Container,
+			Command:   opts.Command,
+			Stdin:     opts.Stdin != nil,
+			Stdout:    true,
+			TTY:       true,
+		}, scheme.ParameterCodec)
+
+	wsExec, err := remotecommand.NewWebSocketExecutor(restConfig, "GET", req.URL().String())
+	if err != nil {
+		return fmt.Errorf("failed to create WebSocket executor: %w", err)
+	}
+	spdyExec, err := remotecommand.NewSPDYExecutor(restConfig, "POST", req.URL())
+	if err != nil {
+		return fmt.Errorf("failed to create SPDY executor: %w", err)
+	}
+	executor, err := remotecommand.NewFallbackExecutor(wsExec, spdyExec, httpstream.IsUpgradeFailure)
+	if err != nil {
+		return fmt.Errorf("failed to create executor: %w", err)
+	}
+
+	return executor.StreamWithContext(ctx, remotecommand.StreamOptions{
+		Stdin:             opts.Stdin,
+		Stdout:            opts.Stdout,
+		Tty:               true,
+		TerminalSizeQueue: opts.Resize,
+	})
+}
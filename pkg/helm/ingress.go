@@ -0,0 +1,99 @@
+package helm
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ClusterIssuerAnnotation makes cert-manager issue the certificate of an Ingress.
+const ClusterIssuerAnnotation = "cert-manager.io/cluster-issuer"
+
+// IngressSpec describes the Ingress the API creates for a release whose chart cannot create one.
+type IngressSpec struct {
+	Host          string
+	ClassName     string // Empty uses the cluster default IngressClass
+	TLS           bool
+	TLSSecretName string // Empty uses the default certificate of the controller
+	Annotations   map[string]string
+	Service       string
+	Port          int32
+}
+
+// releaseIngressName is the Ingress created by the API for a release.
+func releaseIngressName(releaseName string) string {
+	return releaseName + "-app-store"
+}
+
+// CreateReleaseIngress creates, or replaces, the Ingress publishing a release.
+func (hc *HelmClient) CreateReleaseIngress(releaseName string, spec IngressSpec) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	pathType := networkingv1.PathTypePrefix
+	ing := &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      releaseIngressName(releaseName),
+			Namespace: hc.config.AppInstallNamespace,
+			Labels: map[string]string{
+				releaseInstanceLabel:           releaseName,
+				"app.kubernetes.io/managed-by": "app-store-api",
+				"app.kubernetes.io/component":  "ingress",
+			},
+			Annotations: spec.Annotations,
+		},
+		Spec: networkingv1.IngressSpec{
+			Rules: []networkingv1.IngressRule{{
+				Host: spec.Host,
+				IngressRuleValue: networkingv1.IngressRuleValue{HTTP: &networkingv1.HTTPIngressRuleValue{
+					Paths: []networkingv1.HTTPIngressPath{{
+						Path:     "/",
+						PathType: &pathType,
+						Backend: networkingv1.IngressBackend{Service: &networkingv1.IngressServiceBackend{
+							Name: spec.Service,
+							Port: networkingv1.ServiceBackendPort{Number: spec.Port},
+						}},
+					}},
+				}},
+			}},
+		},
+	}
+	if spec.ClassName != "" {
+		ing.Spec.IngressClassName = &spec.ClassName
+	}
+	if spec.TLS {
+		ing.Spec.TLS = []networkingv1.IngressTLS{{Hosts: []string{spec.Host}, SecretName: spec.TLSSecretName}}
+	}
+
+	ingresses := hc.kubeClient.NetworkingV1().Ingresses(hc.config.AppInstallNamespace)
+	existing, err := ingresses.Get(ctx, ing.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = ingresses.Create(ctx, ing, metav1.CreateOptions{})
+	} else if err == nil {
+		ing.ResourceVersion = existing.ResourceVersion
+		_, err = ingresses.Update(ctx, ing, metav1.UpdateOptions{})
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create ingress of release '%s': %w", releaseName, err)
+	}
+	log.Printf("Exposed release '%s' at host %s", releaseName, spec.Host)
+	return nil
+}
+
+// deleteReleaseIngress removes the Ingress created by the API for a release.
+// It reports whether an Ingress was deleted.
+func (hc *HelmClient) deleteReleaseIngress(releaseName string) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	err := hc.kubeClient.NetworkingV1().Ingresses(hc.config.AppInstallNamespace).Delete(ctx, releaseIngressName(releaseName), metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		log.Printf("Warning: Could not delete ingress of release '%s': %v", releaseName, err)
+	}
+	return err == nil
+}
@@ -0,0 +1,132 @@
+package helm
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// maxLogStreams bounds the containers multiplexed into one log stream.
+const maxLogStreams = 20
+
+// ErrNoLogTargets is returned when no pod container of the release matches the log options.
+var ErrNoLogTargets = errors.New("no matching pod container")
+
+// LogOptions selects the logs streamed by StreamReleaseLogs.
+type LogOptions struct {
+	Pod          string // Only this pod; empty for every pod of the release
+	Container    string // Only this container; empty for every (non-init) container
+	Follow       bool
+	TailLines    *int64
+	SinceSeconds *int64
+	Previous     bool // Logs of the previous, terminated, container instance
+}
+
+// LogLine is one line of a container log.
+type LogLine struct {
+	Pod       string `json:"pod"`
+	Container string `json:"container"`
+	Line      string `json:"line,omitempty"`
+	Error     string `json:"error,omitempty"` // Set on the last message of a stream that failed
+}
+
+// logTarget is one container whose log is streamed.
+type logTarget struct {
+	pod, container string
+}
+
+// StreamReleaseLogs streams the logs of the release pods to lines, multiplexing every selected
+// container, until the logs end or ctx is cancelled. It closes lines when done. Pods created
+// after the call are not picked up.
+func (hc *HelmClient) StreamReleaseLogs(ctx context.Context, releaseName string, opts LogOptions, lines chan<- LogLine) error {
+	targets, err := hc.logTargets(ctx, releaseName, opts)
+	if err != nil {
+		close(lines)
+		return err
+	}
+
+	var wg sync.WaitGroup
+	for _, t := range targets {
+		wg.Add(1)
+		go func(t logTarget) {
+			defer wg.Done()
+			if err := hc.streamContainerLog(ctx, t, opts, lines); err != nil && ctx.Err() == nil {
+				select {
+				case lines <- LogLine{Pod: t.pod, Container: t.container, Error: err.Error()}:
+				case <-ctx.Done():
+				}
+			}
+		}(t)
+	}
+	go func() {
+		wg.Wait()
+		close(lines)
+	}()
+	return nil
+}
+
+// logTargets returns the containers of the release pods selected by opts.
+func (hc *HelmClient) logTargets(ctx context.Context, releaseName string, opts LogOptions) ([]logTarget, error) {
+	pods, err := hc.kubeClient.CoreV1().Pods(hc.config.AppInstallNamespace).List(ctx, metav1.ListOptions{
+		LabelSelector: labels.Set{releaseInstanceLabel: releaseName}.String(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods of release '%s': %w", releaseName, err)
+	}
+
+	var targets []logTarget
+	for _, pod := range pods.Items {
+		if opts.Pod != "" && pod.Name != opts.Pod {
+			continue
+		}
+		containers := pod.Spec.Containers
+		if opts.Container != "" {
+			// A named container may also be an init container
+			containers = append(append([]corev1.Container{}, pod.Spec.InitContainers...), pod.Spec.Containers...)
+		}
+		for _, c := range containers {
+			if opts.Container == "" || c.Name == opts.Container {
+				targets = append(targets, logTarget{pod: pod.Name, container: c.Name})
+			}
+		}
+	}
+	if len(targets) == 0 {
+		return nil, ErrNoLogTargets
+	}
+	if len(targets) > maxLogStreams {
+		return nil, fmt.Errorf("%d containers match, select a pod or container to stream at most %d", len(targets), maxLogStreams)
+	}
+	return targets, nil
+}
+
+// streamContainerLog copies the log of one container to lines.
+func (hc *HelmClient) streamContainerLog(ctx context.Context, t logTarget, opts LogOptions, lines chan<- LogLine) error {
+	stream, err := hc.kubeClient.CoreV1().Pods(hc.config.AppInstallNamespace).GetLogs(t.pod, &corev1.PodLogOptions{
+		Container:    t.container,
+		Follow:       opts.Follow,
+		TailLines:    opts.TailLines,
+		SinceSeconds: opts.SinceSeconds,
+		Previous:     opts.Previous,
+	}).Stream(ctx)
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+
+	scanner := bufio.NewScanner(stream)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		select {
+		case lines <- LogLine{Pod: t.pod, Container: t.container, Line: scanner.Text()}:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,74 @@
+package helm
+
+import (
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"app-store-api/pkg/values"
+)
+
+// manifestSeparator matches the YAML document separators of a rendered manifest.
+var manifestSeparator = regexp.MustCompile(`(?m)^---\s*$`)
+
+// splitManifest splits a rendered release manifest into its YAML documents, skipping empty ones.
+func splitManifest(manifest string) []string {
+	var docs []string
+	for _, doc := range manifestSeparator.Split(manifest, -1) {
+		if strings.TrimSpace(doc) != "" {
+			docs = append(docs, doc)
+		}
+	}
+	return docs
+}
+
+// manifestObject identifies an object of a rendered manifest.
+type manifestObject struct {
+	Kind     string `yaml:"kind"`
+	Metadata struct {
+		Name        string            `yaml:"name"`
+		Annotations map[string]string `yaml:"annotations"`
+	} `yaml:"metadata"`
+}
+
+// parseManifestObjects returns the objects of a rendered manifest. Documents that
+// cannot be parsed or are not objects are skipped.
+func parseManifestObjects(manifest string) []manifestObject {
+	var objects []manifestObject
+	for _, doc := range splitManifest(manifest) {
+		var obj manifestObject
+		if err := yaml.Unmarshal([]byte(doc), &obj); err != nil || obj.Kind == "" {
+			continue
+		}
+		objects = append(objects, obj)
+	}
+	return objects
+}
+
+// RedactManifestSecrets replaces the data of every Secret in a rendered manifest
+// with a placeholder. Other documents are returned unchanged.
+func RedactManifestSecrets(manifest string) string {
+	docs := splitManifest(manifest)
+	for i, doc := range docs {
+		var obj map[string]interface{}
+		if err := yaml.Unmarshal([]byte(doc), &obj); err != nil || obj["kind"] != "Secret" {
+			continue
+		}
+		for _, field := range []string{"data", "stringData"} {
+			if data, ok := obj[field].(map[string]interface{}); ok {
+				for k := range data {
+					data[k] = values.RedactedPlaceholder
+				}
+			}
+		}
+		redacted, err := yaml.Marshal(obj)
+		if err != nil {
+			// Never fall back to the original document, it holds the secret data
+			docs[i] = "# Secret redacted\n"
+			continue
+		}
+		docs[i] = "\n" + string(redacted)
+	}
+	return strings.Join(docs, "---")
+}
@@ -0,0 +1,322 @@
+package helm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+)
+
+// ErrNoFreeNodePort is returned when every port of the configured range is taken.
+var ErrNoFreeNodePort = errors.New("no free NodePort left in the configured range")
+
+// NodePortAllocation is a NodePort of the cluster and who owns it.
+type NodePortAllocation struct {
+	Port      int32  `json:"port"`
+	Release   string `json:"release,omitempty"`
+	ValuePath string `json:"value_path,omitempty"` // Value path the reserved port is injected at
+	Service   string `json:"service,omitempty"`    // "namespace/name" of the Service using the port
+	Reserved  bool   `json:"reserved"`             // Reserved by the app store for a release
+	InUse     bool   `json:"in_use"`               // A Service currently uses the port
+}
+
+// NodePortAllocator hands out NodePorts from a range and keeps them reserved per release,
+// so reinstalls and upgrades of a release get the same ports. Reservations are stored in
+// a ConfigMap, one key per release holding its ports by value path.
+type NodePortAllocator struct {
+	kubeClient kubernetes.Interface
+	namespace  string
+	name       string
+	start, end int32
+	mu         sync.Mutex
+}
+
+// NewNodePortAllocator creates an allocator for the inclusive port range [start, end].
+func NewNodePortAllocator(kc kubernetes.Interface, namespace, name string, start, end int32) *NodePortAllocator {
+	return &NodePortAllocator{kubeClient: kc, namespace: namespace, name: name, start: start, end: end}
+}
+
+// Range returns the inclusive port range of the allocator.
+func (a *NodePortAllocator) Range() (int32, int32) {
+	return a.start, a.end
+}
+
+// Allocate returns a port for each value path of a release, reusing its existing
+// reservations and reserving free ports for the others. It also returns the paths given
+// a new port, so a failed install can free them. A reservation is not reused when its port
+// has left the range or is now taken by a Service of another release.
+func (a *NodePortAllocator) Allocate(releaseName string, paths []string) (map[string]int32, []string, error) {
+	if len(paths) == 0 {
+		return nil, nil, nil
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var allocated map[string]int32
+	var fresh []string
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		reservations, resourceVersion, err := a.load(ctx)
+		if err != nil {
+			return err
+		}
+		used, err := a.servicePorts(ctx, releaseName)
+		if err != nil {
+			return err
+		}
+		for release, ports := range reservations {
+			if release == releaseName {
+				continue
+			}
+			for _, p := range ports {
+				used[p] = release
+			}
+		}
+
+		allocated = make(map[string]int32, len(paths))
+		fresh = nil
+		previous := reservations[releaseName]
+		changed := false
+		for _, path := range paths {
+			if p, ok := previous[path]; ok && p >= a.start && p <= a.end {
+				if _, taken := used[p]; !taken {
+					allocated[path] = p
+					used[p] = releaseName
+					continue
+				}
+				log.Printf("Warning: NodePort %d reserved for release '%s' is used by %s, reserving another", p, releaseName, used[p])
+			}
+			p, err := a.pickFree(used)
+			if err != nil {
+				return err
+			}
+			allocated[path] = p
+			used[p] = releaseName
+			fresh = append(fresh, path)
+			changed = true
+		}
+		if !changed && len(previous) == len(allocated) {
+			return nil
+		}
+		reservations[releaseName] = allocated
+		return a.store(ctx, reservations, resourceVersion)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	log.Printf("NodePorts of release '%s': %v", releaseName, allocated)
+	return allocated, fresh, nil
+}
+
+// Free drops the reservations of a release, or only those at the given value paths.
+// It reports whether any was dropped.
+func (a *NodePortAllocator) Free(releaseName string, paths ...string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	freed := false
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		reservations, resourceVersion, err := a.load(ctx)
+		if err != nil {
+			return err
+		}
+		ports, ok := reservations[releaseName]
+		if !ok {
+			return nil
+		}
+		if len(paths) == 0 {
+			delete(reservations, releaseName)
+			freed = true
+		} else {
+			for _, path := range paths {
+				if _, ok := ports[path]; ok {
+					delete(ports, path)
+					freed = true
+				}
+			}
+			if len(ports) == 0 {
+				delete(reservations, releaseName)
+			}
+		}
+		if !freed {
+			return nil
+		}
+		return a.store(ctx, reservations, resourceVersion)
+	})
+	if err != nil {
+		log.Printf("Warning: Could not free the NodePorts of release '%s': %v", releaseName, err)
+		return false
+	}
+	return freed
+}
+
+// List returns the NodePorts used by Services across the cluster and the reservations of
+// the app store, sorted by port.
+func (a *NodePortAllocator) List() ([]NodePortAllocation, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	reservations, _, err := a.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	services, err := a.kubeClient.CoreV1().Services(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list services: %w", err)
+	}
+
+	byPort := make(map[int32]*NodePortAllocation)
+	for release, ports := range reservations {
+		for path, p := range ports {
+			byPort[p] = &NodePortAllocation{Port: p, Release: release, ValuePath: path, Reserved: true}
+		}
+	}
+	for _, svc := range services.Items {
+		for _, port := range svc.Spec.Ports {
+			if port.NodePort == 0 {
+				continue
+			}
+			alloc, ok := byPort[port.NodePort]
+			if !ok {
+				alloc = &NodePortAllocation{Port: port.NodePort}
+				byPort[port.NodePort] = alloc
+			}
+			alloc.Service = svc.Namespace + "/" + svc.Name
+			alloc.InUse = true
+			if alloc.Release == "" {
+				alloc.Release = svc.Labels[releaseInstanceLabel]
+			}
+		}
+	}
+
+	result := make([]NodePortAllocation, 0, len(byPort))
+	for _, alloc := range byPort {
+		result = append(result, *alloc)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Port < result[j].Port })
+	return result, nil
+}
+
+// pickFree returns the lowest port of the range absent from used.
+func (a *NodePortAllocator) pickFree(used map[int32]string) (int32, error) {
+	for p := a.start; p <= a.end; p++ {
+		if _, taken := used[p]; !taken {
+			return p, nil
+		}
+	}
+	return 0, ErrNoFreeNodePort
+}
+
+// servicePorts returns the NodePorts used by Services across the cluster, by the "namespace/name" of their Service.
+// The Services of releaseName are left out, as their ports are its own.
+func (a *NodePortAllocator) servicePorts(ctx context.Context, releaseName string) (map[int32]string, error) {
+	services, err := a.kubeClient.CoreV1().Services(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list services: %w", err)
+	}
+	used := make(map[int32]string)
+	for _, svc := range services.Items {
+		if svc.Namespace == a.namespace && svc.Labels[releaseInstanceLabel] == releaseName {
+			continue
+		}
+		for _, port := range svc.Spec.Ports {
+			if port.NodePort != 0 {
+				used[port.NodePort] = svc.Namespace + "/" + svc.Name
+			}
+		}
+	}
+	return used, nil
+}
+
+// load returns the reservations by release and the resourceVersion of the ConfigMap.
+// An empty resourceVersion means the ConfigMap does not exist yet.
+func (a *NodePortAllocator) load(ctx context.Context) (map[string]map[string]int32, string, error) {
+	reservations := make(map[string]map[string]int32)
+	cm, err := a.kubeClient.CoreV1().ConfigMaps(a.namespace).Get(ctx, a.name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return reservations, "", nil
+	} else if err != nil {
+		return nil, "", fmt.Errorf("failed to read NodePort reservations %s/%s: %w", a.namespace, a.name, err)
+	}
+	for release, data := range cm.Data {
+		var ports map[string]int32
+		if err := json.Unmarshal([]byte(data), &ports); err != nil {
+			log.Printf("Warning: Ignoring invalid NodePort reservation of release '%s': %v", release, err)
+			continue
+		}
+		reservations[release] = ports
+	}
+	return reservations, cm.ResourceVersion, nil
+}
+
+// store writes the reservations. Updates carry the resourceVersion read by load, so
+// concurrent allocations from other replicas surface as conflicts.
+func (a *NodePortAllocator) store(ctx context.Context, reservations map[string]map[string]int32, resourceVersion string) error {
+	data := make(map[string]string, len(reservations))
+	for release, ports := range reservations {
+		encoded, err := json.Marshal(ports)
+		if err != nil {
+			return fmt.Errorf("failed to marshal NodePort reservation of release '%s': %w", release, err)
+		}
+		data[release] = string(encoded)
+	}
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            a.name,
+			Namespace:       a.namespace,
+			ResourceVersion: resourceVersion,
+			Labels: map[string]string{
+				"app.kubernetes.io/managed-by": "app-store-api",
+				"app.kubernetes.io/component":  "nodeports",
+			},
+		},
+		Data: data,
+	}
+
+	var err error
+	if resourceVersion != "" {
+		_, err = a.kubeClient.CoreV1().ConfigMaps(a.namespace).Update(ctx, cm, metav1.UpdateOptions{})
+	} else {
+		_, err = a.kubeClient.CoreV1().ConfigMaps(a.namespace).Create(ctx, cm, metav1.CreateOptions{})
+	}
+	if apierrors.IsAlreadyExists(err) {
+		// Another replica created the ConfigMap first; retry as an update.
+		return apierrors.NewConflict(corev1.Resource("configmaps"), a.name, err)
+	}
+	return err
+}
+
+// AllocateNodePorts returns a reserved NodePort for each value path of a release, and the
+// paths given a new port.
+func (hc *HelmClient) AllocateNodePorts(releaseName string, paths []string) (map[string]int32, []string, error) {
+	return hc.nodePorts.Allocate(releaseName, paths)
+}
+
+// FreeNodePorts drops the NodePorts reserved for a release at the given value paths.
+func (hc *HelmClient) FreeNodePorts(releaseName string, paths []string) {
+	if len(paths) > 0 && hc.nodePorts.Free(releaseName, paths...) {
+		log.Printf("Freed the NodePorts of release '%s' at %v", releaseName, paths)
+	}
+}
+
+// ListNodePorts returns the NodePorts of the cluster and their owners, with the allocation range.
+func (hc *HelmClient) ListNodePorts() (start, end int32, allocations []NodePortAllocation, err error) {
+	start, end = hc.nodePorts.Range()
+	allocations, err = hc.nodePorts.List()
+	return start, end, allocations, err
+}
@@ -0,0 +1,126 @@
+package helm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/storage/driver"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/rest"
+)
+
+// ErrNoProxyService is returned when a release has no Service port to proxy to.
+var ErrNoProxyService = errors.New("release has no service to proxy to")
+
+// webPortNames are the Service port names preferred when proxying to a release.
+var webPortNames = []string{"http", "https", "web", "ui", "http-web"}
+
+// ProxyTarget is a release Service port reached through the API server service proxy.
+type ProxyTarget struct {
+	Service string
+	Port    string   // Port name, or number when unnamed
+	BaseURL *url.URL // Service proxy URL; the request path is appended to it
+	// Transport authenticates to the API server as the app store service account.
+	Transport http.RoundTripper
+}
+
+// ReleaseChartName returns the name of the chart a release was installed from.
+func (hc *HelmClient) ReleaseChartName(releaseName string) (string, error) {
+	rel, err := action.NewGet(hc.actionConfig).Run(releaseName)
+	if errors.Is(err, driver.ErrReleaseNotFound) {
+		return "", ErrReleaseNotFound
+	} else if err != nil {
+		return "", fmt.Errorf("failed to get release '%s': %w", releaseName, err)
+	}
+	if rel.Chart == nil || rel.Chart.Metadata == nil {
+		return "", nil
+	}
+	return rel.Chart.Metadata.Name, nil
+}
+
+// ReleaseProxyTarget returns the Service port of a release to proxy to. When service is set
+// (and port, if not zero) that port is used; otherwise the Services of the release are taken in
+// name order, preferring a port named like a web port.
+func (hc *HelmClient) ReleaseProxyTarget(ctx context.Context, releaseName, service string, port int32) (*ProxyTarget, error) {
+	services, err := hc.kubeClient.CoreV1().Services(hc.config.AppInstallNamespace).List(ctx, metav1.ListOptions{
+		LabelSelector: labels.Set{releaseInstanceLabel: releaseName}.String(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list services of release '%s': %w", releaseName, err)
+	}
+
+	svc, svcPort := pickProxyPort(services.Items, service, port)
+	if svc == nil {
+		return nil, ErrNoProxyService
+	}
+
+	restConfig, err := hc.actionConfig.RESTClientGetter.ToRESTConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get REST config: %w", err)
+	}
+	transport, err := rest.TransportFor(restConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create API server transport: %w", err)
+	}
+
+	portName := svcPort.Name
+	if portName == "" {
+		portName = strconv.Itoa(int(svcPort.Port))
+	}
+	baseURL := hc.kubeClient.CoreV1().RESTClient().Get().
+		Namespace(hc.config.AppInstallNamespace).
+		Resource("services").
+		Name(fmt.Sprintf("%s:%s:%s", portScheme(*svcPort), svc.Name, portName)).
+		SubResource("proxy").
+		URL()
+	return &ProxyTarget{Service: svc.Name, Port: portName, BaseURL: baseURL, Transport: transport}, nil
+}
+
+// pickProxyPort selects the Service port to proxy to, see ReleaseProxyTarget.
+// Only TCP ports are considered.
+func pickProxyPort(services []corev1.Service, service string, port int32) (*corev1.Service, *corev1.ServicePort) {
+	sort.Slice(services, func(i, j int) bool { return services[i].Name < services[j].Name })
+
+	var fallbackSvc *corev1.Service
+	var fallbackPort *corev1.ServicePort
+	for i := range services {
+		svc := &services[i]
+		if service != "" && svc.Name != service {
+			continue
+		}
+		for j := range svc.Spec.Ports {
+			p := &svc.Spec.Ports[j]
+			if p.Protocol != "" && p.Protocol != corev1.ProtocolTCP {
+				continue
+			}
+			if port != 0 && p.Port == port {
+				return svc, p
+			}
+			if port == 0 && isWebPortName(p.Name) {
+				return svc, p
+			}
+			if fallbackSvc == nil && (port == 0 || service == "") {
+				fallbackSvc, fallbackPort = svc, p
+			}
+		}
+	}
+	return fallbackSvc, fallbackPort
+}
+
+func isWebPortName(name string) bool {
+	for _, n := range webPortNames {
+		if strings.EqualFold(name, n) {
+			return true
+		}
+	}
+	return false
+}
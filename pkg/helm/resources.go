@@ -0,0 +1,322 @@
+package helm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"time"
+
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/storage/driver"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// maxEventsPerObject bounds the events reported for each object, newest first.
+const maxEventsPerObject = 5
+
+// ErrReleaseNotFound is returned when a release does not exist.
+var ErrReleaseNotFound = errors.New("release not found")
+
+// ErrReleaseExists is returned when installing a release under the name of an installed one.
+var ErrReleaseExists = errors.New("release already exists")
+
+// ReplicaStatus is the rollout state of a workload.
+type ReplicaStatus struct {
+	Desired   int32 `json:"desired"`
+	Ready     int32 `json:"ready"`
+	Updated   int32 `json:"updated"`
+	Available int32 `json:"available"`
+}
+
+// ObjectEvent is a Kubernetes Event about an object.
+type ObjectEvent struct {
+	Type     string    `json:"type"` // Normal or Warning
+	Reason   string    `json:"reason"`
+	Message  string    `json:"message"`
+	Count    int32     `json:"count"`
+	LastSeen time.Time `json:"last_seen"`
+}
+
+// ResourceStatus is the live state of an object of a release manifest.
+type ResourceStatus struct {
+	Kind      string         `json:"kind"`
+	Name      string         `json:"name"`
+	Found     bool           `json:"found"` // False when the object is missing from the cluster
+	Ready     *bool          `json:"ready,omitempty"`
+	Replicas  *ReplicaStatus `json:"replicas,omitempty"`
+	Type      string         `json:"type,omitempty"`      // Service type
+	Endpoints []string       `json:"endpoints,omitempty"` // Ready Service endpoints, "ip:port"
+	Phase     string         `json:"phase,omitempty"`     // PersistentVolumeClaim phase
+	Capacity  string         `json:"capacity,omitempty"`  // Provisioned PersistentVolumeClaim size
+	Events    []ObjectEvent  `json:"events,omitempty"`
+	Error     string         `json:"error,omitempty"`
+}
+
+// ContainerStatus is the state of a container of a release pod.
+type ContainerStatus struct {
+	Name                  string `json:"name"`
+	Ready                 bool   `json:"ready"`
+	RestartCount          int32  `json:"restart_count"`
+	State                 string `json:"state"`            // running, waiting or terminated
+	Reason                string `json:"reason,omitempty"` // e.g. CrashLoopBackOff, ImagePullBackOff
+	Message               string `json:"message,omitempty"`
+	LastTerminationReason string `json:"last_termination_reason,omitempty"` // e.g. OOMKilled, Error
+	LastTerminationExit   *int32 `json:"last_termination_exit_code,omitempty"`
+}
+
+// PodStatus is the state of a pod of a release.
+type PodStatus struct {
+	Name       string            `json:"name"`
+	Phase      string            `json:"phase"`
+	Ready      bool              `json:"ready"`
+	Node       string            `json:"node,omitempty"`
+	Owner      string            `json:"owner,omitempty"` // "Kind/name" of the controller
+	Restarts   int32             `json:"restarts"`
+	StartedAt  *time.Time        `json:"started_at,omitempty"`
+	Containers []ContainerStatus `json:"containers"`
+	Events     []ObjectEvent     `json:"events,omitempty"`
+}
+
+// ReleaseResources is the live state of the objects of a release and of its pods.
+type ReleaseResources struct {
+	Release   string           `json:"release"`
+	Revision  int              `json:"revision"`
+	Status    string           `json:"status"`
+	Resources []ResourceStatus `json:"resources"`
+	Pods      []PodStatus      `json:"pods"`
+}
+
+// GetReleaseResources returns every object of the stored release manifest with its live status,
+// the pods of the release and the latest events about them.
+func (hc *HelmClient) GetReleaseResources(releaseName string) (*ReleaseResources, error) {
+	rel, err := action.NewGet(hc.actionConfig).Run(releaseName)
+	if errors.Is(err, driver.ErrReleaseNotFound) {
+		return nil, ErrReleaseNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get release '%s': %w", releaseName, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	namespace := hc.config.AppInstallNamespace
+
+	eventList, err := hc.kubeClient.CoreV1().Events(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list events: %w", err)
+	}
+	events := groupEvents(eventList.Items)
+
+	result := &ReleaseResources{
+		Release:   rel.Name,
+		Revision:  rel.Version,
+		Status:    rel.Info.Status.String(),
+		Resources: []ResourceStatus{},
+		Pods:      []PodStatus{},
+	}
+	for _, obj := range parseManifestObjects(rel.Manifest) {
+		status := hc.resourceStatus(ctx, obj.Kind, obj.Metadata.Name)
+		status.Events = events[obj.Kind+"/"+obj.Metadata.Name]
+		result.Resources = append(result.Resources, status)
+	}
+
+	pods, err := hc.kubeClient.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: labels.Set{releaseInstanceLabel: releaseName}.String(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods of release '%s': %w", releaseName, err)
+	}
+	for i := range pods.Items {
+		ps := podStatus(&pods.Items[i])
+		ps.Events = events["Pod/"+ps.Name]
+		result.Pods = append(result.Pods, ps)
+	}
+	sort.Slice(result.Pods, func(i, j int) bool { return result.Pods[i].Name < result.Pods[j].Name })
+	return result, nil
+}
+
+// resourceStatus fetches the live state of one object. Kinds without a specific status
+// are only checked for existence when they are core kinds commonly found in charts.
+func (hc *HelmClient) resourceStatus(ctx context.Context, kind, name string) ResourceStatus {
+	status := ResourceStatus{Kind: kind, Name: name}
+	namespace := hc.config.AppInstallNamespace
+	var err error
+
+	switch kind {
+	case "Deployment":
+		d, getErr := hc.kubeClient.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err = getErr; err == nil {
+			desired := int32(1)
+			if d.Spec.Replicas != nil {
+				desired = *d.Spec.Replicas
+			}
+			status.Replicas = &ReplicaStatus{Desired: desired, Ready: d.Status.ReadyReplicas, Updated: d.Status.UpdatedReplicas, Available: d.Status.AvailableReplicas}
+		}
+	case "StatefulSet":
+		s, getErr := hc.kubeClient.AppsV1().StatefulSets(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err = getErr; err == nil {
+			desired := int32(1)
+			if s.Spec.Replicas != nil {
+				desired = *s.Spec.Replicas
+			}
+			status.Replicas = &ReplicaStatus{Desired: desired, Ready: s.Status.ReadyReplicas, Updated: s.Status.UpdatedReplicas, Available: s.Status.AvailableReplicas}
+		}
+	case "DaemonSet":
+		ds, getErr := hc.kubeClient.AppsV1().DaemonSets(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err = getErr; err == nil {
+			status.Replicas = &ReplicaStatus{Desired: ds.Status.DesiredNumberScheduled, Ready: ds.Status.NumberReady, Updated: ds.Status.UpdatedNumberScheduled, Available: ds.Status.NumberAvailable}
+		}
+	case "Service":
+		svc, getErr := hc.kubeClient.CoreV1().Services(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err = getErr; err == nil {
+			status.Type = string(svc.Spec.Type)
+			status.Endpoints, err = hc.serviceEndpoints(ctx, name)
+			if svc.Spec.Type != corev1.ServiceTypeExternalName {
+				ready := len(status.Endpoints) > 0
+				status.Ready = &ready
+			}
+		}
+	case "PersistentVolumeClaim":
+		pvc, getErr := hc.kubeClient.CoreV1().PersistentVolumeClaims(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err = getErr; err == nil {
+			status.Phase = string(pvc.Status.Phase)
+			if q, ok := pvc.Status.Capacity[corev1.ResourceStorage]; ok {
+				status.Capacity = q.String()
+			}
+			bound := pvc.Status.Phase == corev1.ClaimBound
+			status.Ready = &bound
+		}
+	case "Job":
+		job, getErr := hc.kubeClient.BatchV1().Jobs(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err = getErr; err == nil {
+			complete := job.Status.Succeeded > 0 && job.Status.Active == 0
+			status.Ready = &complete
+		}
+	case "ConfigMap":
+		_, err = hc.kubeClient.CoreV1().ConfigMaps(namespace).Get(ctx, name, metav1.GetOptions{})
+	case "Secret":
+		_, err = hc.kubeClient.CoreV1().Secrets(namespace).Get(ctx, name, metav1.GetOptions{})
+	case "ServiceAccount":
+		_, err = hc.kubeClient.CoreV1().ServiceAccounts(namespace).Get(ctx, name, metav1.GetOptions{})
+	case "Ingress":
+		_, err = hc.kubeClient.NetworkingV1().Ingresses(namespace).Get(ctx, name, metav1.GetOptions{})
+	default:
+		// Not inspected; reported as found since Helm installed it
+		status.Found = true
+		return status
+	}
+
+	switch {
+	case apierrors.IsNotFound(err):
+		status.Found = false
+	case err != nil:
+		status.Found = true
+		status.Error = err.Error()
+	default:
+		status.Found = true
+		if status.Replicas != nil {
+			ready := status.Replicas.Ready >= status.Replicas.Desired
+			status.Ready = &ready
+		}
+	}
+	return status
+}
+
+// serviceEndpoints returns the ready "ip:port" endpoints of a Service.
+func (hc *HelmClient) serviceEndpoints(ctx context.Context, serviceName string) ([]string, error) {
+	slices, err := hc.kubeClient.DiscoveryV1().EndpointSlices(hc.config.AppInstallNamespace).List(ctx, metav1.ListOptions{
+		LabelSelector: labels.Set{discoveryv1.LabelServiceName: serviceName}.String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	var result []string
+	for _, slice := range slices.Items {
+		for _, ep := range slice.Endpoints {
+			if ep.Conditions.Ready != nil && !*ep.Conditions.Ready {
+				continue
+			}
+			for _, addr := range ep.Addresses {
+				for _, port := range slice.Ports {
+					if port.Port != nil {
+						result = append(result, net.JoinHostPort(addr, strconv.Itoa(int(*port.Port))))
+					}
+				}
+			}
+		}
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+// podStatus summarises the state of a pod and its containers.
+func podStatus(pod *corev1.Pod) PodStatus {
+	ps := PodStatus{Name: pod.Name, Phase: string(pod.Status.Phase), Node: pod.Spec.NodeName, Containers: []ContainerStatus{}}
+	if pod.Status.StartTime != nil {
+		started := pod.Status.StartTime.Time
+		ps.StartedAt = &started
+	}
+	for _, ref := range pod.OwnerReferences {
+		if ref.Controller != nil && *ref.Controller {
+			ps.Owner = ref.Kind + "/" + ref.Name
+		}
+	}
+	for _, cond := range pod.Status.Conditions {
+		if cond.Type == corev1.PodReady {
+			ps.Ready = cond.Status == corev1.ConditionTrue
+		}
+	}
+	for _, cs := range append(append([]corev1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...) {
+		c := ContainerStatus{Name: cs.Name, Ready: cs.Ready, RestartCount: cs.RestartCount}
+		switch {
+		case cs.State.Running != nil:
+			c.State = "running"
+		case cs.State.Waiting != nil:
+			c.State, c.Reason, c.Message = "waiting", cs.State.Waiting.Reason, cs.State.Waiting.Message
+		case cs.State.Terminated != nil:
+			c.State, c.Reason, c.Message = "terminated", cs.State.Terminated.Reason, cs.State.Terminated.Message
+		}
+		if t := cs.LastTerminationState.Terminated; t != nil {
+			c.LastTerminationReason = t.Reason
+			exitCode := t.ExitCode
+			c.LastTerminationExit = &exitCode
+		}
+		ps.Restarts += cs.RestartCount
+		ps.Containers = append(ps.Containers, c)
+	}
+	return ps
+}
+
+// groupEvents indexes events by "Kind/name" of their object, newest first, keeping the latest few.
+func groupEvents(events []corev1.Event) map[string][]ObjectEvent {
+	grouped := make(map[string][]ObjectEvent)
+	for _, e := range events {
+		lastSeen := e.LastTimestamp.Time
+		if lastSeen.IsZero() {
+			lastSeen = e.EventTime.Time
+		}
+		if lastSeen.IsZero() {
+			lastSeen = e.CreationTimestamp.Time
+		}
+		count := e.Count
+		if e.Series != nil {
+			count = e.Series.Count
+		}
+		key := e.InvolvedObject.Kind + "/" + e.InvolvedObject.Name
+		grouped[key] = append(grouped[key], ObjectEvent{Type: e.Type, Reason: e.Reason, Message: e.Message, Count: count, LastSeen: lastSeen})
+	}
+	for key, list := range grouped {
+		sort.Slice(list, func(i, j int) bool { return list[i].LastSeen.After(list[j].LastSeen) })
+		if len(list) > maxEventsPerObject {
+			list = list[:maxEventsPerObject]
+		}
+		grouped[key] = list
+	}
+	return grouped
+}
@@ -0,0 +1,125 @@
+package helm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// SecretRefKey marks a value to be read from an existing Kubernetes Secret in the
+// install namespace at install time, instead of being inlined in the request:
+//
+//	{"auth": {"password": {"$secretRef": {"name": "db-credentials", "key": "password"}}}}
+const SecretRefKey = "$secretRef"
+
+// ReferenceableLabel opts a Secret in to being read by secret references. Secrets without it,
+// such as the generated credentials and the Helm release records, cannot be referenced.
+const ReferenceableLabel = "app-store-api/referenceable"
+
+// ErrSecretRef is returned when a secret reference is malformed or cannot be resolved.
+var ErrSecretRef = errors.New("invalid secret reference")
+
+// IsSecretRef reports whether v is a secret reference.
+func IsSecretRef(v interface{}) bool {
+	typed, ok := v.(map[string]interface{})
+	if !ok || len(typed) != 1 {
+		return false
+	}
+	_, ok = typed[SecretRefKey]
+	return ok
+}
+
+// ResolveSecretRefs returns a copy of vals where every secret reference is replaced
+// by the referenced Secret value. The input map is never modified. Only Secrets labelled
+// ReferenceableLabel=true, and not labelled with a release other than releaseName, are read.
+func (hc *HelmClient) ResolveSecretRefs(releaseName string, vals map[string]interface{}) (map[string]interface{}, error) {
+	if vals == nil {
+		return nil, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cache := make(map[string]map[string][]byte)
+	resolved, err := hc.resolveSecretRefs(ctx, releaseName, vals, "", cache)
+	if err != nil {
+		return nil, err
+	}
+	return resolved.(map[string]interface{}), nil
+}
+
+func (hc *HelmClient) resolveSecretRefs(ctx context.Context, releaseName string, v interface{}, path string, cache map[string]map[string][]byte) (interface{}, error) {
+	switch typed := v.(type) {
+	case map[string]interface{}:
+		if IsSecretRef(typed) {
+			return hc.readSecretRef(ctx, releaseName, typed[SecretRefKey], path, cache)
+		}
+		out := make(map[string]interface{}, len(typed))
+		for k, child := range typed {
+			resolved, err := hc.resolveSecretRefs(ctx, releaseName, child, joinPath(path, k), cache)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = resolved
+		}
+		return out, nil
+	case []interface{}:
+		out := make([]interface{}, len(typed))
+		for i, child := range typed {
+			resolved, err := hc.resolveSecretRefs(ctx, releaseName, child, joinPath(path, fmt.Sprintf("%d", i)), cache)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = resolved
+		}
+		return out, nil
+	default:
+		return v, nil
+	}
+}
+
+func (hc *HelmClient) readSecretRef(ctx context.Context, releaseName string, ref interface{}, path string, cache map[string]map[string][]byte) (string, error) {
+	refMap, ok := ref.(map[string]interface{})
+	if !ok {
+		return "", fmt.Errorf("%w at '%s': expected an object with 'name' and 'key'", ErrSecretRef, path)
+	}
+	name, _ := refMap["name"].(string)
+	key, _ := refMap["key"].(string)
+	if name == "" || key == "" {
+		return "", fmt.Errorf("%w at '%s': 'name' and 'key' are required", ErrSecretRef, path)
+	}
+
+	data, ok := cache[name]
+	if !ok {
+		secret, err := hc.kubeClient.CoreV1().Secrets(hc.config.AppInstallNamespace).Get(ctx, name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return "", fmt.Errorf("%w at '%s': secret '%s' not found in namespace '%s'", ErrSecretRef, path, name, hc.config.AppInstallNamespace)
+		} else if err != nil {
+			return "", fmt.Errorf("failed to read secret '%s' referenced at '%s': %w", name, path, err)
+		}
+		if secret.Labels[ReferenceableLabel] != "true" {
+			// Reported like a missing Secret, so references cannot probe which Secrets exist
+			return "", fmt.Errorf("%w at '%s': secret '%s' not found in namespace '%s'", ErrSecretRef, path, name, hc.config.AppInstallNamespace)
+		}
+		if owner := secret.Labels[releaseInstanceLabel]; owner != "" && owner != releaseName {
+			return "", fmt.Errorf("%w at '%s': secret '%s' belongs to another release", ErrSecretRef, path, name)
+		}
+		data = secret.Data
+		cache[name] = data
+	}
+	value, ok := data[key]
+	if !ok {
+		return "", fmt.Errorf("%w at '%s': key '%s' not found in secret '%s'", ErrSecretRef, path, key, name)
+	}
+	return string(value), nil
+}
+
+func joinPath(parent, segment string) string {
+	if parent == "" {
+		return segment
+	}
+	return parent + "." + segment
+}
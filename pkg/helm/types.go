@@ -1,5 +1,7 @@
 package helm
 
+import "time"
+
 // ReleaseInfo defines information about an installed Helm release.
 type ReleaseInfo struct {
 	Name         string           `json:"name"`
@@ -11,12 +13,18 @@ type ReleaseInfo struct {
 	ChartVersion string           `json:"chart_version"` // Version of the chart (e.g., "1.16.0")
 	AppVersion   string           `json:"app_version"`   // Application version from chart metadata
 	NodePorts    map[string]int32 `json:"node_ports,omitempty"`
+	URLs         []AccessURL      `json:"urls,omitempty"`       // Where the release can be opened, ingresses first
+	ExpiresAt    *time.Time       `json:"expires_at,omitempty"` // When an ephemeral release is uninstalled
 }
 
 // InstallRequest represents the payload for a chart installation request.
 type InstallRequest struct {
-	ReleaseName string                 `json:"release_name,omitempty"` // Optional name for the Helm release
-	Values      map[string]interface{} `json:"values,omitempty"`       // Helm values to customize the installation
+	ReleaseName   string                 `json:"release_name,omitempty"`  // Optional name for the Helm release
+	Values        map[string]interface{} `json:"values,omitempty"`        // Helm values to customize the installation
+	Justification string                 `json:"justification,omitempty"` // Why the app is needed, for charts requiring approval
+	StorageClass  string                 `json:"storage_class,omitempty"` // StorageClass of the release volumes, for charts declaring storage class paths
+	TTL           string                 `json:"ttl,omitempty"`           // Uninstall the release after this long (e.g. "4h")
+	ExpiresAt     *time.Time             `json:"expires_at,omitempty"`    // Uninstall the release at this time (RFC 3339)
 }
 
 // ChartDefinition is used by HelmClient to install charts and update repos.
@@ -28,3 +36,11 @@ type ChartDefinition struct {
 	RepoURL string // Helm repository URL
 	// DefaultValues map[string]interface{} // Future: default values for installation
 }
+
+// CredentialSpec describes a credential generated at install time.
+// It mirrors appcatalog.GeneratedSecret to avoid import cycles.
+type CredentialSpec struct {
+	Path    string // Value path receiving the credential
+	Length  int
+	Charset string
+}
@@ -0,0 +1,206 @@
+package helm
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/release"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"app-store-api/pkg/telemetry"
+)
+
+const (
+	releaseInstanceLabel = "app.kubernetes.io/instance"
+	// releaseNameAnnotation is set by Helm on every object it manages.
+	releaseNameAnnotation    = "meta.helm.sh/release-name"
+	resourcePolicyAnnotation = "helm.sh/resource-policy"
+	// helmStorageOwnerLabel marks the Secrets and ConfigMaps Helm stores release history in.
+	helmStorageOwnerLabel = "owner"
+)
+
+// UninstallOptions tunes an uninstall.
+type UninstallOptions struct {
+	KeepHistory bool          // Keep the release history, so the release can be inspected or rolled back
+	DeletePVCs  bool          // Also delete the PersistentVolumeClaims labelled with the release
+	Wait        bool          // Wait until the release resources are deleted
+	Timeout     time.Duration // Defaults to the configured Helm timeout
+}
+
+// ResourceRef identifies a namespaced object affected by an uninstall.
+type ResourceRef struct {
+	Kind   string `json:"kind"`
+	Name   string `json:"name"`
+	Reason string `json:"reason,omitempty"` // Why the object was left behind
+}
+
+// UninstallReport describes what an uninstall removed and what it left behind.
+type UninstallReport struct {
+	Release     string        `json:"release"`
+	KeptHistory bool          `json:"kept_history"`
+	Removed     []ResourceRef `json:"removed"`
+	LeftBehind  []ResourceRef `json:"left_behind"`
+}
+
+// UninstallRelease uninstalls a Helm release.
+func (hc *HelmClient) UninstallRelease(releaseName string, opts UninstallOptions) (*UninstallReport, error) {
+	uninstallClient := action.NewUninstall(hc.actionConfig)
+	uninstallClient.KeepHistory = opts.KeepHistory
+	uninstallClient.Wait = opts.Wait
+	uninstallClient.Timeout = hc.config.HelmTimeout
+	if opts.Timeout > 0 {
+		uninstallClient.Timeout = opts.Timeout
+	}
+
+	log.Printf("Uninstalling release '%s' from namespace '%s'", releaseName, hc.config.AppInstallNamespace)
+	done := telemetry.StartHelmOperation("uninstall")
+	res, err := uninstallClient.Run(releaseName)
+	chartName := ""
+	if res != nil && res.Release != nil && res.Release.Chart != nil && res.Release.Chart.Metadata != nil {
+		chartName = res.Release.Chart.Metadata.Name
+	}
+	done(chartName, err)
+	if err != nil {
+		if strings.Contains(err.Error(), "release: not found") {
+			return nil, fmt.Errorf("%w: '%s' in namespace '%s'", ErrReleaseNotFound, releaseName, hc.config.AppInstallNamespace)
+		}
+		return nil, fmt.Errorf("failed to uninstall release '%s': %w", releaseName, err)
+	}
+
+	report := &UninstallReport{Release: releaseName, KeptHistory: opts.KeepHistory, Removed: []ResourceRef{}, LeftBehind: []ResourceRef{}}
+	if res.Release != nil {
+		for _, obj := range parseManifestObjects(res.Release.Manifest) {
+			ref := ResourceRef{Kind: obj.Kind, Name: obj.Metadata.Name}
+			if obj.Metadata.Annotations[resourcePolicyAnnotation] == "keep" {
+				ref.Reason = resourcePolicyAnnotation + ": keep"
+				report.LeftBehind = append(report.LeftBehind, ref)
+			} else {
+				report.Removed = append(report.Removed, ref)
+			}
+		}
+	}
+	if hc.deleteReleaseCredentials(releaseName) {
+		report.Removed = append(report.Removed, ResourceRef{Kind: "Secret", Name: credentialsSecretName(releaseName)})
+	}
+	if hc.deleteReleaseIngress(releaseName) {
+		report.Removed = append(report.Removed, ResourceRef{Kind: "Ingress", Name: releaseIngressName(releaseName)})
+	}
+	hc.cleanupReleaseClaims(releaseName, opts.DeletePVCs, report)
+	// A release whose history is kept may be installed again, and should get the same ports back
+	if !opts.KeepHistory && hc.nodePorts.Free(releaseName) {
+		log.Printf("Freed the NodePorts reserved for release '%s'", releaseName)
+	}
+
+	log.Printf("Successfully uninstalled release '%s' (%d removed, %d left behind)", releaseName, len(report.Removed), len(report.LeftBehind))
+	return report, nil
+}
+
+// cleanupReleaseClaims deletes the PersistentVolumeClaims of a release, or reports them as
+// left behind. StatefulSet claims are not part of the release manifest, so Helm never removes them.
+func (hc *HelmClient) cleanupReleaseClaims(releaseName string, deleteClaims bool, report *UninstallReport) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	pvcs := hc.kubeClient.CoreV1().PersistentVolumeClaims(hc.config.AppInstallNamespace)
+	list, err := pvcs.List(ctx, metav1.ListOptions{LabelSelector: fmt.Sprintf("%s=%s", releaseInstanceLabel, releaseName)})
+	if err != nil {
+		log.Printf("Warning: Could not list persistent volume claims of release '%s': %v", releaseName, err)
+		return
+	}
+	for _, pvc := range list.Items {
+		ref := ResourceRef{Kind: "PersistentVolumeClaim", Name: pvc.Name}
+		if !deleteClaims {
+			ref.Reason = "persistent data, pass delete_pvcs=true to remove it"
+			report.LeftBehind = append(report.LeftBehind, ref)
+			continue
+		}
+		if err := pvcs.Delete(ctx, pvc.Name, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+			log.Printf("Warning: Could not delete persistent volume claim '%s' of release '%s': %v", pvc.Name, releaseName, err)
+			ref.Reason = fmt.Sprintf("deletion failed: %v", err)
+			report.LeftBehind = append(report.LeftBehind, ref)
+			continue
+		}
+		report.Removed = append(report.Removed, ref)
+	}
+}
+
+// OrphanedResource is an object labelled with a release that no longer exists.
+type OrphanedResource struct {
+	Kind      string    `json:"kind"`
+	Name      string    `json:"name"`
+	Release   string    `json:"release"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// FindOrphans lists the PersistentVolumeClaims, Secrets and ConfigMaps of the install
+// namespace whose release no longer exists. Objects without a release label or annotation,
+// and the release history Helm stores, are not considered.
+func (hc *HelmClient) FindOrphans() ([]OrphanedResource, error) {
+	listClient := action.NewList(hc.actionConfig)
+	listClient.SetStateMask()
+	releases, err := listClient.Run()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list Helm releases: %w", err)
+	}
+	existing := make(map[string]bool, len(releases))
+	for _, rel := range releases {
+		if rel.Info.Status != release.StatusUninstalled {
+			existing[rel.Name] = true
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	core := hc.kubeClient.CoreV1()
+	namespace := hc.config.AppInstallNamespace
+	orphans := []OrphanedResource{}
+	check := func(kind string, meta metav1.ObjectMeta) {
+		if meta.Labels[helmStorageOwnerLabel] == "helm" {
+			return
+		}
+		releaseName := meta.Labels[releaseInstanceLabel]
+		if releaseName == "" {
+			releaseName = meta.Annotations[releaseNameAnnotation]
+		}
+		if releaseName == "" || existing[releaseName] {
+			return
+		}
+		orphans = append(orphans, OrphanedResource{Kind: kind, Name: meta.Name, Release: releaseName, CreatedAt: meta.CreationTimestamp.Time})
+	}
+
+	pvcs, err := core.PersistentVolumeClaims(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list persistent volume claims: %w", err)
+	}
+	for _, o := range pvcs.Items {
+		check("PersistentVolumeClaim", o.ObjectMeta)
+	}
+	secrets, err := core.Secrets(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list secrets: %w", err)
+	}
+	for _, o := range secrets.Items {
+		check("Secret", o.ObjectMeta)
+	}
+	configMaps, err := core.ConfigMaps(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list config maps: %w", err)
+	}
+	for _, o := range configMaps.Items {
+		check("ConfigMap", o.ObjectMeta)
+	}
+
+	sort.Slice(orphans, func(i, j int) bool {
+		if orphans[i].Release != orphans[j].Release {
+			return orphans[i].Release < orphans[j].Release
+		}
+		return orphans[i].Kind+"/"+orphans[i].Name < orphans[j].Kind+"/"+orphans[j].Name
+	})
+	return orphans, nil
+}
@@ -0,0 +1,340 @@
+package helm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/storage/driver"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// restartedAtAnnotation is the pod template annotation `kubectl rollout restart` sets.
+	restartedAtAnnotation = "kubectl.kubernetes.io/restartedAt"
+	// SuspendedReplicasAnnotation records the replicas of a suspended Deployment or StatefulSet.
+	SuspendedReplicasAnnotation = "app-store-api/suspended-replicas"
+	// SuspendedAnnotation marks the CronJobs suspended with their release.
+	SuspendedAnnotation = "app-store-api/suspended"
+)
+
+// ErrNoWorkloads is returned when the manifest of a release holds no workload the action applies to.
+var ErrNoWorkloads = errors.New("release has no workload")
+
+// WorkloadAction names an action applied to the workloads of a release.
+type WorkloadAction string
+
+const (
+	WorkloadRestart WorkloadAction = "restart"
+	WorkloadScale   WorkloadAction = "scale"
+	WorkloadSuspend WorkloadAction = "suspend"
+	WorkloadResume  WorkloadAction = "resume"
+)
+
+// WorkloadResult is the outcome of an action on one workload.
+type WorkloadResult struct {
+	Kind             string `json:"kind"`
+	Name             string `json:"name"`
+	Replicas         *int32 `json:"replicas,omitempty"`          // Replicas after the action
+	PreviousReplicas *int32 `json:"previous_replicas,omitempty"` // Replicas before a scale, suspend or resume
+	Skipped          string `json:"skipped,omitempty"`           // Why the action did not apply
+	Error            string `json:"error,omitempty"`
+}
+
+// WorkloadReport describes an action applied to the workloads of a release.
+type WorkloadReport struct {
+	Release   string           `json:"release"`
+	Action    WorkloadAction   `json:"action"`
+	Workloads []WorkloadResult `json:"workloads"`
+}
+
+// Err returns the first workload error of the report, or nil.
+func (r *WorkloadReport) Err() error {
+	for _, w := range r.Workloads {
+		if w.Error != "" {
+			return fmt.Errorf("%s of %s/%s failed: %s", r.Action, w.Kind, w.Name, w.Error)
+		}
+	}
+	return nil
+}
+
+// workloadKinds are the manifest kinds the release actions apply to.
+var workloadKinds = map[string]bool{"Deployment": true, "StatefulSet": true, "DaemonSet": true, "CronJob": true}
+
+// releaseWorkloads returns the workloads of the stored release manifest.
+func (hc *HelmClient) releaseWorkloads(releaseName string) ([]manifestObject, error) {
+	rel, err := action.NewGet(hc.actionConfig).Run(releaseName)
+	if errors.Is(err, driver.ErrReleaseNotFound) {
+		return nil, ErrReleaseNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get release '%s': %w", releaseName, err)
+	}
+	var workloads []manifestObject
+	for _, obj := range parseManifestObjects(rel.Manifest) {
+		if workloadKinds[obj.Kind] {
+			workloads = append(workloads, obj)
+		}
+	}
+	if len(workloads) == 0 {
+		return nil, ErrNoWorkloads
+	}
+	return workloads, nil
+}
+
+// RestartRelease triggers a rolling restart of every Deployment, StatefulSet and DaemonSet of a
+// release, as `kubectl rollout restart` does.
+func (hc *HelmClient) RestartRelease(releaseName string) (*WorkloadReport, error) {
+	workloads, err := hc.releaseWorkloads(releaseName)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	patch, _ := json.Marshal(map[string]interface{}{
+		"spec": map[string]interface{}{"template": map[string]interface{}{"metadata": map[string]interface{}{
+			"annotations": map[string]string{restartedAtAnnotation: time.Now().Format(time.RFC3339)},
+		}}},
+	})
+	report := &WorkloadReport{Release: releaseName, Action: WorkloadRestart}
+	for _, w := range workloads {
+		result := WorkloadResult{Kind: w.Kind, Name: w.Metadata.Name}
+		if w.Kind == "CronJob" {
+			result.Skipped = "CronJobs start new pods on schedule"
+		} else if err := hc.patchWorkload(ctx, w.Kind, w.Metadata.Name, patch); err != nil {
+			result.Error = err.Error()
+		}
+		report.Workloads = append(report.Workloads, result)
+	}
+	log.Printf("Restarted the workloads of release '%s'", releaseName)
+	return report, nil
+}
+
+// ScaleRelease sets the replicas of the Deployments and StatefulSets of a release, or only of
+// the workload named workload when not empty. Scaling a suspended workload resumes it.
+func (hc *HelmClient) ScaleRelease(releaseName string, replicas int32, workload string) (*WorkloadReport, error) {
+	workloads, err := hc.releaseWorkloads(releaseName)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	report := &WorkloadReport{Release: releaseName, Action: WorkloadScale}
+	for _, w := range workloads {
+		if workload != "" && w.Metadata.Name != workload {
+			continue
+		}
+		result := WorkloadResult{Kind: w.Kind, Name: w.Metadata.Name}
+		if !scalable(w.Kind) {
+			result.Skipped = w.Kind + "s have no replicas"
+			report.Workloads = append(report.Workloads, result)
+			continue
+		}
+		if previous, _, err := hc.workloadReplicas(ctx, w.Kind, w.Metadata.Name); err != nil {
+			result.Error = err.Error()
+		} else if err := hc.patchWorkload(ctx, w.Kind, w.Metadata.Name, replicasPatch(replicas, nil)); err != nil {
+			result.Error = err.Error()
+		} else {
+			result.PreviousReplicas, result.Replicas = &previous, &replicas
+		}
+		report.Workloads = append(report.Workloads, result)
+	}
+	if len(report.Workloads) == 0 {
+		return nil, ErrNoWorkloads
+	}
+	log.Printf("Scaled the workloads of release '%s' to %d replicas", releaseName, replicas)
+	return report, nil
+}
+
+// SuspendRelease scales the Deployments and StatefulSets of a release to zero, remembering their
+// replicas in an annotation, and suspends its CronJobs. DaemonSets cannot be suspended and are skipped.
+func (hc *HelmClient) SuspendRelease(releaseName string) (*WorkloadReport, error) {
+	workloads, err := hc.releaseWorkloads(releaseName)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	report := &WorkloadReport{Release: releaseName, Action: WorkloadSuspend}
+	for _, w := range workloads {
+		result := WorkloadResult{Kind: w.Kind, Name: w.Metadata.Name}
+		switch w.Kind {
+		case "DaemonSet":
+			result.Skipped = "DaemonSets cannot be scaled to zero"
+		case "CronJob":
+			if suspended, _, err := hc.cronJobSuspended(ctx, w.Metadata.Name); err != nil {
+				result.Error = err.Error()
+			} else if suspended {
+				result.Skipped = "already suspended"
+			} else if err := hc.patchWorkload(ctx, w.Kind, w.Metadata.Name, cronJobSuspendPatch(true)); err != nil {
+				result.Error = err.Error()
+			}
+		default:
+			previous, annotations, err := hc.workloadReplicas(ctx, w.Kind, w.Metadata.Name)
+			if err != nil {
+				result.Error = err.Error()
+			} else if _, suspended := annotations[SuspendedReplicasAnnotation]; suspended {
+				result.Skipped = "already suspended"
+			} else {
+				zero := int32(0)
+				annotation := strconv.Itoa(int(previous))
+				if err := hc.patchWorkload(ctx, w.Kind, w.Metadata.Name, replicasPatch(zero, &annotation)); err != nil {
+					result.Error = err.Error()
+				} else {
+					result.PreviousReplicas, result.Replicas = &previous, &zero
+				}
+			}
+		}
+		report.Workloads = append(report.Workloads, result)
+	}
+	log.Printf("Suspended release '%s'", releaseName)
+	return report, nil
+}
+
+// ResumeRelease restores the replicas recorded by SuspendRelease and resumes the CronJobs it suspended.
+func (hc *HelmClient) ResumeRelease(releaseName string) (*WorkloadReport, error) {
+	workloads, err := hc.releaseWorkloads(releaseName)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	report := &WorkloadReport{Release: releaseName, Action: WorkloadResume}
+	for _, w := range workloads {
+		result := WorkloadResult{Kind: w.Kind, Name: w.Metadata.Name}
+		switch w.Kind {
+		case "DaemonSet":
+			result.Skipped = "DaemonSets are never suspended"
+		case "CronJob":
+			if _, byRelease, err := hc.cronJobSuspended(ctx, w.Metadata.Name); err != nil {
+				result.Error = err.Error()
+			} else if !byRelease {
+				// CronJobs suspended by other means are left alone
+				result.Skipped = "not suspended"
+			} else if err := hc.patchWorkload(ctx, w.Kind, w.Metadata.Name, cronJobSuspendPatch(false)); err != nil {
+				result.Error = err.Error()
+			}
+		default:
+			current, annotations, err := hc.workloadReplicas(ctx, w.Kind, w.Metadata.Name)
+			if err != nil {
+				result.Error = err.Error()
+				break
+			}
+			recorded, suspended := annotations[SuspendedReplicasAnnotation]
+			if !suspended {
+				result.Skipped = "not suspended"
+				break
+			}
+			restored, err := strconv.ParseInt(recorded, 10, 32)
+			if err != nil || restored < 0 {
+				result.Error = fmt.Sprintf("invalid %s annotation %q", SuspendedReplicasAnnotation, recorded)
+				break
+			}
+			replicas := int32(restored)
+			if err := hc.patchWorkload(ctx, w.Kind, w.Metadata.Name, replicasPatch(replicas, nil)); err != nil {
+				result.Error = err.Error()
+			} else {
+				result.PreviousReplicas, result.Replicas = &current, &replicas
+			}
+		}
+		report.Workloads = append(report.Workloads, result)
+	}
+	log.Printf("Resumed release '%s'", releaseName)
+	return report, nil
+}
+
+func scalable(kind string) bool {
+	return kind == "Deployment" || kind == "StatefulSet"
+}
+
+// replicasPatch sets the replicas of a workload. A nil annotation removes the suspended replicas
+// annotation; otherwise it is set to *annotation.
+func replicasPatch(replicas int32, annotation *string) []byte {
+	var value interface{}
+	if annotation != nil {
+		value = *annotation
+	}
+	patch, _ := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{"annotations": map[string]interface{}{SuspendedReplicasAnnotation: value}},
+		"spec":     map[string]interface{}{"replicas": replicas},
+	})
+	return patch
+}
+
+// cronJobSuspendPatch suspends or resumes a CronJob, marking the CronJobs suspended by the API.
+func cronJobSuspendPatch(suspend bool) []byte {
+	var marker interface{}
+	if suspend {
+		marker = "true"
+	}
+	patch, _ := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{"annotations": map[string]interface{}{SuspendedAnnotation: marker}},
+		"spec":     map[string]interface{}{"suspend": suspend},
+	})
+	return patch
+}
+
+// workloadReplicas returns the desired replicas and the annotations of a Deployment or StatefulSet.
+func (hc *HelmClient) workloadReplicas(ctx context.Context, kind, name string) (int32, map[string]string, error) {
+	apps := hc.kubeClient.AppsV1()
+	var replicas *int32
+	var meta metav1.ObjectMeta
+	switch kind {
+	case "Deployment":
+		d, err := apps.Deployments(hc.config.AppInstallNamespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return 0, nil, err
+		}
+		replicas, meta = d.Spec.Replicas, d.ObjectMeta
+	case "StatefulSet":
+		s, err := apps.StatefulSets(hc.config.AppInstallNamespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return 0, nil, err
+		}
+		replicas, meta = s.Spec.Replicas, s.ObjectMeta
+	default:
+		return 0, nil, fmt.Errorf("%s has no replicas", kind)
+	}
+	if replicas == nil {
+		return 1, meta.Annotations, nil
+	}
+	return *replicas, meta.Annotations, nil
+}
+
+// cronJobSuspended reports whether a CronJob is suspended, and whether SuspendRelease suspended it.
+func (hc *HelmClient) cronJobSuspended(ctx context.Context, name string) (suspended, byRelease bool, err error) {
+	cj, err := hc.kubeClient.BatchV1().CronJobs(hc.config.AppInstallNamespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return false, false, err
+	}
+	byRelease = cj.Annotations[SuspendedAnnotation] == "true"
+	return byRelease || (cj.Spec.Suspend != nil && *cj.Spec.Suspend), byRelease, nil
+}
+
+// patchWorkload applies a JSON merge patch to a workload of the release namespace.
+func (hc *HelmClient) patchWorkload(ctx context.Context, kind, name string, patch []byte) error {
+	ns := hc.config.AppInstallNamespace
+	var err error
+	switch kind {
+	case "Deployment":
+		_, err = hc.kubeClient.AppsV1().Deployments(ns).Patch(ctx, name, types.MergePatchType, patch, metav1.PatchOptions{})
+	case "StatefulSet":
+		_, err = hc.kubeClient.AppsV1().StatefulSets(ns).Patch(ctx, name, types.MergePatchType, patch, metav1.PatchOptions{})
+	case "DaemonSet":
+		_, err = hc.kubeClient.AppsV1().DaemonSets(ns).Patch(ctx, name, types.MergePatchType, patch, metav1.PatchOptions{})
+	case "CronJob":
+		_, err = hc.kubeClient.BatchV1().CronJobs(ns).Patch(ctx, name, types.MergePatchType, patch, metav1.PatchOptions{})
+	default:
+		err = fmt.Errorf("unsupported kind %s", kind)
+	}
+	return err
+}
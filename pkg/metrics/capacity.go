@@ -0,0 +1,99 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// NodeCapacity is the allocatable capacity of a node and what the pods already on it request.
+type NodeCapacity struct {
+	Name                     string
+	Ready                    bool
+	Unschedulable            bool
+	Labels                   map[string]string
+	Taints                   []corev1.Taint
+	AllocatableCPUMilliCores int64
+	AllocatableMemoryBytes   int64
+	AllocatablePods          int64
+	RequestedCPUMilliCores   int64
+	RequestedMemoryBytes     int64
+	Pods                     int
+}
+
+// FreeCPUMilliCores is the allocatable CPU not yet requested by pods.
+func (n NodeCapacity) FreeCPUMilliCores() int64 {
+	return n.AllocatableCPUMilliCores - n.RequestedCPUMilliCores
+}
+
+// FreeMemoryBytes is the allocatable memory not yet requested by pods.
+func (n NodeCapacity) FreeMemoryBytes() int64 {
+	return n.AllocatableMemoryBytes - n.RequestedMemoryBytes
+}
+
+// GetNodeCapacity returns the scheduling capacity of every node. Unlike the usage metrics
+// it only needs the core API, so it works without the Metrics Server.
+func (s *Service) GetNodeCapacity() ([]NodeCapacity, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	nodes, err := s.kubeClient.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list nodes: %w", err)
+	}
+	// Listed now rather than cached: a preflight right after an install must count its pods
+	usage, err := s.podUsageByNode(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]NodeCapacity, 0, len(nodes.Items))
+	for _, n := range nodes.Items {
+		ready := false
+		for _, cond := range n.Status.Conditions {
+			if cond.Type == corev1.NodeReady {
+				ready = cond.Status == corev1.ConditionTrue
+			}
+		}
+		u := usage[n.Name]
+		result = append(result, NodeCapacity{
+			Name:                     n.Name,
+			Ready:                    ready,
+			Unschedulable:            n.Spec.Unschedulable,
+			Labels:                   n.Labels,
+			Taints:                   n.Spec.Taints,
+			AllocatableCPUMilliCores: n.Status.Allocatable.Cpu().MilliValue(),
+			AllocatableMemoryBytes:   n.Status.Allocatable.Memory().Value(),
+			AllocatablePods:          n.Status.Allocatable.Pods().Value(),
+			RequestedCPUMilliCores:   u.CPURequestMilliCores,
+			RequestedMemoryBytes:     u.MemoryRequestBytes,
+			Pods:                     u.Pods,
+		})
+	}
+	return result, nil
+}
+
+// PodRequests returns the effective CPU and memory requests of a pod, as the scheduler
+// counts them: the sum of its containers, or the largest init container if that is higher.
+func PodRequests(spec *corev1.PodSpec) (cpuMilliCores, memoryBytes int64) {
+	for _, c := range spec.Containers {
+		cpuMilliCores += c.Resources.Requests.Cpu().MilliValue()
+		memoryBytes += c.Resources.Requests.Memory().Value()
+	}
+	for _, c := range spec.InitContainers {
+		cpuMilliCores = max(cpuMilliCores, c.Resources.Requests.Cpu().MilliValue())
+		memoryBytes = max(memoryBytes, c.Resources.Requests.Memory().Value())
+	}
+	for name, q := range spec.Overhead {
+		switch name {
+		case corev1.ResourceCPU:
+			cpuMilliCores += q.MilliValue()
+		case corev1.ResourceMemory:
+			memoryBytes += q.Value()
+		}
+	}
+	return cpuMilliCores, memoryBytes
+}
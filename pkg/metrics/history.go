@@ -0,0 +1,249 @@
+package metrics
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	// rawRetention is how long every collected sample is kept.
+	rawRetention = time.Hour
+	// minuteRetention is how long the 1-minute averages are kept.
+	minuteRetention = 24 * time.Hour
+)
+
+// ResourceSample is the CPU and memory usage of one entity at one point in time.
+type ResourceSample struct {
+	CPUUsageMilliCores int64 `json:"cpu_usage_milli_cores"`
+	MemoryUsageBytes   int64 `json:"memory_usage_bytes"`
+}
+
+// Sample is one point of the metrics history.
+type Sample struct {
+	Time     time.Time                 `json:"time"`
+	Cluster  ResourceSample            `json:"cluster"`
+	Nodes    map[string]ResourceSample `json:"nodes,omitempty"`
+	Releases map[string]ResourceSample `json:"releases,omitempty"`
+}
+
+// sampleFromSnapshot extracts the history sample of a collector snapshot.
+func sampleFromSnapshot(t time.Time, snapshot *ClusterMetrics) Sample {
+	sample := Sample{
+		Time: t,
+		Cluster: ResourceSample{
+			CPUUsageMilliCores: snapshot.TotalCPUUsageMilliCores,
+			MemoryUsageBytes:   snapshot.TotalMemoryUsageBytes,
+		},
+		Nodes: make(map[string]ResourceSample, len(snapshot.Nodes)),
+	}
+	for _, n := range snapshot.Nodes {
+		sample.Nodes[n.Name] = ResourceSample{CPUUsageMilliCores: n.CPUUsageMilliCores, MemoryUsageBytes: n.MemoryUsageBytes}
+	}
+	if len(snapshot.Releases) > 0 {
+		sample.Releases = make(map[string]ResourceSample, len(snapshot.Releases))
+		for _, r := range snapshot.Releases {
+			sample.Releases[r.Release] = ResourceSample{CPUUsageMilliCores: r.CPUUsageMilliCores, MemoryUsageBytes: r.MemoryUsageBytes}
+		}
+	}
+	return sample
+}
+
+// History is an in-memory, size-bounded time series with two tiers:
+// every sample for the last hour, and 1-minute averages for the last 24 hours.
+type History struct {
+	mu      sync.RWMutex
+	raw     []Sample
+	minutes []Sample
+	pending []Sample // Raw samples of the minute currently being accumulated
+}
+
+// NewHistory creates an empty history.
+func NewHistory() *History {
+	return &History{}
+}
+
+// Add records a sample. Samples must be added in chronological order.
+func (h *History) Add(sample Sample) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.raw = append(h.raw, sample)
+	h.raw = trimBefore(h.raw, sample.Time.Add(-rawRetention))
+
+	minute := sample.Time.Truncate(time.Minute)
+	if len(h.pending) > 0 && !h.pending[0].Time.Truncate(time.Minute).Equal(minute) {
+		h.minutes = append(h.minutes, averageSamples(h.pending[0].Time.Truncate(time.Minute), h.pending))
+		h.minutes = trimBefore(h.minutes, sample.Time.Add(-minuteRetention))
+		h.pending = nil
+	}
+	h.pending = append(h.pending, sample)
+}
+
+// Query returns the samples between from and to. Ranges reaching further back than
+// the raw tier are served from the 1-minute tier up to the first raw sample, then from
+// the raw tier. A positive step averages the samples into buckets of that width.
+func (h *History) Query(from, to time.Time, step time.Duration) []Sample {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	source := h.raw
+	if len(h.raw) == 0 {
+		source = h.minutes
+	} else if from.Before(h.raw[0].Time) {
+		// Only the minutes ending before the raw tier starts, so no time is counted twice
+		source = nil
+		for _, m := range h.minutes {
+			if m.Time.Add(time.Minute).After(h.raw[0].Time) {
+				break
+			}
+			source = append(source, m)
+		}
+		source = append(source, h.raw...)
+	}
+
+	var selected []Sample
+	for _, s := range source {
+		if !s.Time.Before(from) && !s.Time.After(to) {
+			selected = append(selected, s)
+		}
+	}
+	if step <= 0 || len(selected) == 0 {
+		return selected
+	}
+
+	var result []Sample
+	var bucket []Sample
+	bucketStart := selected[0].Time.Truncate(step)
+	for _, s := range selected {
+		if start := s.Time.Truncate(step); !start.Equal(bucketStart) {
+			result = append(result, averageSamples(bucketStart, bucket))
+			bucket = nil
+			bucketStart = start
+		}
+		bucket = append(bucket, s)
+	}
+	return append(result, averageSamples(bucketStart, bucket))
+}
+
+// historyFile is the on-disk format of a persisted history.
+type historyFile struct {
+	Raw     []Sample `json:"raw"`
+	Minutes []Sample `json:"minutes"`
+}
+
+// Save writes the history to path atomically.
+func (h *History) Save(path string) error {
+	h.mu.RLock()
+	data, err := json.Marshal(historyFile{Raw: h.raw, Minutes: h.minutes})
+	h.mu.RUnlock()
+	if err != nil {
+		return fmt.Errorf("failed to marshal metrics history: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".metrics-history-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary metrics history file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write metrics history: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write metrics history: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace metrics history file %s: %w", path, err)
+	}
+	return nil
+}
+
+// Load replaces the history with the one persisted at path, dropping expired samples.
+// A missing file is not an error.
+func (h *History) Load(path string) error {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to read metrics history file %s: %w", path, err)
+	}
+	var file historyFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("failed to decode metrics history file %s: %w", path, err)
+	}
+
+	now := time.Now()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.raw = trimBefore(file.Raw, now.Add(-rawRetention))
+	h.minutes = trimBefore(file.Minutes, now.Add(-minuteRetention))
+	h.pending = nil
+	return nil
+}
+
+// trimBefore drops the leading samples older than cutoff.
+func trimBefore(samples []Sample, cutoff time.Time) []Sample {
+	i := 0
+	for i < len(samples) && samples[i].Time.Before(cutoff) {
+		i++
+	}
+	if i == 0 {
+		return samples
+	}
+	// Copy so the dropped prefix can be garbage collected
+	return append([]Sample(nil), samples[i:]...)
+}
+
+// averageSamples averages a group of samples into one sample stamped t.
+// Nodes and releases are averaged over the samples they appear in.
+func averageSamples(t time.Time, samples []Sample) Sample {
+	avg := Sample{Time: t}
+	if len(samples) == 0 {
+		return avg
+	}
+
+	var cluster ResourceSample
+	nodeSums := make(map[string]ResourceSample)
+	nodeCounts := make(map[string]int64)
+	releaseSums := make(map[string]ResourceSample)
+	releaseCounts := make(map[string]int64)
+	for _, s := range samples {
+		cluster.CPUUsageMilliCores += s.Cluster.CPUUsageMilliCores
+		cluster.MemoryUsageBytes += s.Cluster.MemoryUsageBytes
+		accumulate(nodeSums, nodeCounts, s.Nodes)
+		accumulate(releaseSums, releaseCounts, s.Releases)
+	}
+	n := int64(len(samples))
+	avg.Cluster = ResourceSample{CPUUsageMilliCores: cluster.CPUUsageMilliCores / n, MemoryUsageBytes: cluster.MemoryUsageBytes / n}
+	avg.Nodes = divide(nodeSums, nodeCounts)
+	avg.Releases = divide(releaseSums, releaseCounts)
+	return avg
+}
+
+func accumulate(sums map[string]ResourceSample, counts map[string]int64, values map[string]ResourceSample) {
+	for name, v := range values {
+		sum := sums[name]
+		sum.CPUUsageMilliCores += v.CPUUsageMilliCores
+		sum.MemoryUsageBytes += v.MemoryUsageBytes
+		sums[name] = sum
+		counts[name]++
+	}
+}
+
+func divide(sums map[string]ResourceSample, counts map[string]int64) map[string]ResourceSample {
+	if len(sums) == 0 {
+		return nil
+	}
+	out := make(map[string]ResourceSample, len(sums))
+	for name, sum := range sums {
+		out[name] = ResourceSample{
+			CPUUsageMilliCores: sum.CPUUsageMilliCores / counts[name],
+			MemoryUsageBytes:   sum.MemoryUsageBytes / counts[name],
+		}
+	}
+	return out
+}
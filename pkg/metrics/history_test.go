@@ -0,0 +1,162 @@
+package metrics
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// collect feeds h as the collector would, with a sample every interval from start to end
+// included. The cluster CPU of each sample is the number of seconds since start, so the
+// 1-minute average of minute m is 60m+25 with a 10s interval.
+func collect(h *History, start, end time.Time, interval time.Duration) {
+	for t := start; !t.After(end); t = t.Add(interval) {
+		h.Add(Sample{
+			Time:    t,
+			Cluster: ResourceSample{CPUUsageMilliCores: int64(t.Sub(start) / time.Second)},
+			Nodes:   map[string]ResourceSample{"node-1": {MemoryUsageBytes: 1 << 30}},
+		})
+	}
+}
+
+func TestHistoryServesTheLastHourSampleBySample(t *testing.T) {
+	start := time.Date(2026, time.January, 5, 9, 0, 0, 0, time.UTC)
+	end := start.Add(3 * time.Hour)
+	h := NewHistory()
+	collect(h, start, end, 10*time.Second)
+
+	got := h.Query(end.Add(-30*time.Minute), end, 0)
+	if len(got) != 181 {
+		t.Fatalf("the last 30 minutes hold %d samples, want 181", len(got))
+	}
+	for i, s := range got {
+		wantTime := end.Add(-30 * time.Minute).Add(time.Duration(i) * 10 * time.Second)
+		if !s.Time.Equal(wantTime) || s.Cluster.CPUUsageMilliCores != int64(wantTime.Sub(start)/time.Second) {
+			t.Fatalf("sample %d = %v with %dm CPU, want the raw sample of %v", i, s.Time, s.Cluster.CPUUsageMilliCores, wantTime)
+		}
+	}
+}
+
+func TestHistoryServesOlderRangesAsMinuteAverages(t *testing.T) {
+	start := time.Date(2026, time.January, 5, 9, 0, 0, 0, time.UTC)
+	h := NewHistory()
+	collect(h, start, start.Add(3*time.Hour), 10*time.Second)
+
+	got := h.Query(start, start.Add(10*time.Minute), 0)
+	if len(got) != 11 {
+		t.Fatalf("the first 10 minutes hold %d samples, want 11 minute averages", len(got))
+	}
+	for m, s := range got {
+		if !s.Time.Equal(start.Add(time.Duration(m) * time.Minute)) {
+			t.Errorf("average %d is stamped %v, want the start of its minute", m, s.Time)
+		}
+		if want := int64(60*m + 25); s.Cluster.CPUUsageMilliCores != want {
+			t.Errorf("minute %d averages %dm CPU, want %dm", m, s.Cluster.CPUUsageMilliCores, want)
+		}
+		if s.Nodes["node-1"].MemoryUsageBytes != 1<<30 {
+			t.Errorf("minute %d lost the node usage: %+v", m, s.Nodes)
+		}
+	}
+}
+
+func TestHistoryStitchesTheTiersWithoutOverlap(t *testing.T) {
+	start := time.Date(2026, time.January, 5, 9, 0, 0, 0, time.UTC)
+	end := start.Add(3 * time.Hour)
+	h := NewHistory()
+	// Not aligned on the minute, so the raw tier starts in the middle of a minute average
+	collect(h, start.Add(5*time.Second), end, 10*time.Second)
+
+	got := h.Query(end.Add(-90*time.Minute), end, 0)
+	var minutes, raw []Sample
+	for i, s := range got {
+		if i > 0 && !s.Time.After(got[i-1].Time) {
+			t.Fatalf("samples %v and %v are out of order", got[i-1].Time, s.Time)
+		}
+		// Averages are stamped on the minute, raw samples 5 seconds past
+		if s.Time.Second() == 0 {
+			minutes = append(minutes, s)
+		} else {
+			raw = append(raw, s)
+		}
+	}
+	if len(minutes) == 0 || len(raw) == 0 {
+		t.Fatalf("got %d minute averages and %d raw samples, want both tiers", len(minutes), len(raw))
+	}
+	if last := minutes[len(minutes)-1]; last.Time.Add(time.Minute).After(raw[0].Time) {
+		t.Errorf("the minute average of %v overlaps the raw samples from %v", last.Time, raw[0].Time)
+	}
+	// From 10:30 to 10:58, the minute of 10:59 being covered by the raw samples from 10:59:55
+	if len(minutes) != 29 || len(raw) != 361 {
+		t.Errorf("got %d minute averages and %d raw samples, want 29 and 361", len(minutes), len(raw))
+	}
+}
+
+func TestHistoryQueryStep(t *testing.T) {
+	start := time.Date(2026, time.January, 5, 9, 0, 0, 0, time.UTC)
+	end := start.Add(3 * time.Hour)
+	h := NewHistory()
+	collect(h, start, end, 10*time.Second)
+
+	got := h.Query(end.Add(-30*time.Minute), end, 10*time.Minute)
+	var times []time.Time
+	var cpus []int64
+	for _, s := range got {
+		times = append(times, s.Time)
+		cpus = append(cpus, s.Cluster.CPUUsageMilliCores)
+	}
+	wantTimes := []time.Time{end.Add(-30 * time.Minute), end.Add(-20 * time.Minute), end.Add(-10 * time.Minute), end}
+	// Each full bucket averages 60 samples 10 seconds apart; the last one holds the final sample
+	wantCPU := []int64{9000 + 295, 9600 + 295, 10200 + 295, 10800}
+	if !reflect.DeepEqual(times, wantTimes) || !reflect.DeepEqual(cpus, wantCPU) {
+		t.Errorf("Query() with a 10m step = %v %v, want %v %v", times, cpus, wantTimes, wantCPU)
+	}
+}
+
+func TestHistoryRetention(t *testing.T) {
+	start := time.Date(2026, time.January, 5, 9, 0, 0, 0, time.UTC)
+	end := start.Add(26 * time.Hour)
+	h := NewHistory()
+	collect(h, start, end, time.Minute)
+
+	got := h.Query(start, end, 0)
+	if len(got) == 0 {
+		t.Fatal("the history is empty")
+	}
+	if oldest := got[0].Time; oldest.Before(end.Add(-minuteRetention)) {
+		t.Errorf("the oldest sample is from %v, more than 24 hours before %v", oldest, end)
+	}
+	if h.Query(start, end.Add(-25*time.Hour), 0) != nil {
+		t.Error("samples older than 24 hours were kept")
+	}
+}
+
+func TestHistorySaveAndLoad(t *testing.T) {
+	// Load drops what has expired by now, so the history ends now
+	end := time.Now().UTC().Truncate(time.Second)
+	start := end.Add(-2 * time.Hour)
+	saved := NewHistory()
+	collect(saved, start, end, 10*time.Second)
+
+	path := filepath.Join(t.TempDir(), "history.json")
+	if err := saved.Save(path); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	loaded := NewHistory()
+	if err := loaded.Load(path); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	// The oldest raw sample may have expired in between, so the tiers are compared apart
+	for _, r := range [][2]time.Time{{start, start.Add(30 * time.Minute)}, {end.Add(-30 * time.Minute), end}} {
+		want := saved.Query(r[0], r[1], 0)
+		got := loaded.Query(r[0], r[1], 0)
+		if len(got) == 0 || !reflect.DeepEqual(got, want) {
+			t.Errorf("from %v to %v, the loaded history holds %d samples, want the %d saved", r[0], r[1], len(got), len(want))
+		}
+	}
+
+	if err := NewHistory().Load(filepath.Join(t.TempDir(), "missing.json")); err != nil {
+		t.Errorf("Load() of a missing file error = %v, want none", err)
+	}
+}
@@ -0,0 +1,74 @@
+package metrics
+
+import "sync"
+
+// Subscription receives the snapshots published by a Hub.
+// C holds at most one pending snapshot: when a subscriber falls behind, the
+// stale snapshot is replaced by the newest one instead of blocking the publisher.
+type Subscription struct {
+	C  <-chan *ClusterMetrics
+	ch chan *ClusterMetrics
+}
+
+// Hub fans snapshots out to any number of subscribers.
+type Hub struct {
+	mu          sync.Mutex
+	subscribers map[*Subscription]struct{}
+	latest      *ClusterMetrics
+}
+
+// NewHub creates an empty hub.
+func NewHub() *Hub {
+	return &Hub{subscribers: make(map[*Subscription]struct{})}
+}
+
+// Subscribe registers a new subscriber. The latest snapshot, if any, is delivered right away.
+func (h *Hub) Subscribe() *Subscription {
+	ch := make(chan *ClusterMetrics, 1)
+	sub := &Subscription{C: ch, ch: ch}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.subscribers[sub] = struct{}{}
+	if h.latest != nil {
+		ch <- h.latest
+	}
+	return sub
+}
+
+// Unsubscribe removes a subscriber and closes its channel.
+func (h *Hub) Unsubscribe(sub *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.subscribers[sub]; ok {
+		delete(h.subscribers, sub)
+		close(sub.ch)
+	}
+}
+
+// Publish delivers a snapshot to every subscriber without ever blocking.
+func (h *Hub) Publish(snapshot *ClusterMetrics) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.latest = snapshot
+	for sub := range h.subscribers {
+		select {
+		case sub.ch <- snapshot:
+		default:
+			// Drop the stale snapshot the subscriber has not consumed yet, then deliver the new one.
+			// Both operations happen under h.mu, so the channel has room afterwards.
+			select {
+			case <-sub.ch:
+			default:
+			}
+			sub.ch <- snapshot
+		}
+	}
+}
+
+// Latest returns the most recent snapshot, or nil if none was published yet.
+func (h *Hub) Latest() *ClusterMetrics {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.latest
+}
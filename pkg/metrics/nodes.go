@@ -0,0 +1,309 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// kubeletSummaryTTL bounds how often the kubelet summary API of each node is queried.
+	kubeletSummaryTTL = 30 * time.Second
+	// kubeletSummaryTimeout bounds a refresh of the summaries, the nodes being queried in parallel.
+	kubeletSummaryTimeout = 5 * time.Second
+	// podUsageTTL bounds how often the collector lists the pods of the whole cluster.
+	podUsageTTL = 30 * time.Second
+	// podUsageTimeout bounds the listing of the pods, which takes a while on large clusters.
+	podUsageTimeout = 30 * time.Second
+
+	nodeRoleLabelPrefix = "node-role.kubernetes.io/"
+
+	diskUsageWarningPercentage = 85.0
+	podUsageWarningPercentage  = 90.0
+)
+
+// reportedConditions are the node conditions exposed in NodeMetrics.
+var reportedConditions = []corev1.NodeConditionType{
+	corev1.NodeReady, corev1.NodeMemoryPressure, corev1.NodeDiskPressure, corev1.NodePIDPressure,
+}
+
+// kubeletSummary is the subset of the kubelet /stats/summary response used by the service.
+type kubeletSummary struct {
+	Node struct {
+		Fs *kubeletFsStats `json:"fs"`
+	} `json:"node"`
+	Pods []struct {
+		Volumes []kubeletVolumeStats `json:"volume"`
+	} `json:"pods"`
+}
+
+// kubeletVolumeStats is the usage of a pod volume; PVCRef is set for persistent volume claims.
+type kubeletVolumeStats struct {
+	kubeletFsStats
+	PVCRef *struct {
+		Name      string `json:"name"`
+		Namespace string `json:"namespace"`
+	} `json:"pvcRef"`
+}
+
+type kubeletFsStats struct {
+	AvailableBytes *int64 `json:"availableBytes"`
+	CapacityBytes  *int64 `json:"capacityBytes"`
+	UsedBytes      *int64 `json:"usedBytes"`
+}
+
+// toFilesystemStats converts kubelet filesystem stats; missing figures are zero.
+func (fs *kubeletFsStats) toFilesystemStats() *FilesystemStats {
+	stats := &FilesystemStats{}
+	if fs.CapacityBytes != nil {
+		stats.CapacityBytes = *fs.CapacityBytes
+	}
+	if fs.UsedBytes != nil {
+		stats.UsedBytes = *fs.UsedBytes
+	}
+	if fs.AvailableBytes != nil {
+		stats.AvailableBytes = *fs.AvailableBytes
+	}
+	stats.UsagePercentage = percentage(stats.UsedBytes, stats.CapacityBytes)
+	return stats
+}
+
+// summaryCache keeps the kubelet summaries between collector ticks.
+type summaryCache struct {
+	mu         sync.Mutex
+	summaries  map[string]*kubeletSummary
+	fetchedAt  time.Time
+	refreshing bool
+	forbidden  bool // Set once the API server refused nodes/proxy, to stop asking
+}
+
+// kubeletSummaries returns the cached kubelet summary of every node. Stale summaries are
+// refreshed in the background and returned meanwhile, so slow or unreachable kubelets never
+// hold up the collector; only the first call waits for them. Nodes whose summary could not
+// be fetched are missing from the result.
+func (s *Service) kubeletSummaries(nodes []corev1.Node) map[string]*kubeletSummary {
+	s.summaries.mu.Lock()
+	cached := s.summaries.summaries
+	if s.summaries.forbidden || s.summaries.refreshing || time.Since(s.summaries.fetchedAt) < kubeletSummaryTTL {
+		s.summaries.mu.Unlock()
+		return cached
+	}
+	s.summaries.refreshing = true
+	first := s.summaries.fetchedAt.IsZero()
+	s.summaries.mu.Unlock()
+
+	if !first {
+		go s.refreshKubeletSummaries(nodes)
+		return cached
+	}
+	return s.refreshKubeletSummaries(nodes)
+}
+
+// refreshKubeletSummaries queries the summary API of every node in parallel, within
+// kubeletSummaryTimeout, and replaces the cached summaries.
+func (s *Service) refreshKubeletSummaries(nodes []corev1.Node) map[string]*kubeletSummary {
+	ctx, cancel := context.WithTimeout(context.Background(), kubeletSummaryTimeout)
+	defer cancel()
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	fresh := make(map[string]*kubeletSummary, len(nodes))
+	var forbidden error
+	for _, n := range nodes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			summary, err := s.fetchKubeletSummary(ctx, n.Name)
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case apierrors.IsForbidden(err):
+				forbidden = err
+			case err != nil:
+				log.Printf("Warning: Could not fetch kubelet summary of node %s: %v", n.Name, err)
+			default:
+				fresh[n.Name] = summary
+			}
+		}()
+	}
+	wg.Wait()
+
+	s.summaries.mu.Lock()
+	defer s.summaries.mu.Unlock()
+	s.summaries.refreshing = false
+	s.summaries.fetchedAt = time.Now()
+	if forbidden != nil {
+		log.Printf("Kubelet summary API not permitted (nodes/proxy), filesystem usage will not be reported: %v", forbidden)
+		s.summaries.forbidden = true
+		s.summaries.summaries = nil
+		return nil
+	}
+	s.summaries.summaries = fresh
+	return fresh
+}
+
+// fetchKubeletSummary reads the summary API of a node through the API server node proxy.
+func (s *Service) fetchKubeletSummary(ctx context.Context, nodeName string) (*kubeletSummary, error) {
+	raw, err := s.kubeClient.CoreV1().RESTClient().Get().
+		Resource("nodes").Name(nodeName).SubResource("proxy").Suffix("stats/summary").
+		DoRaw(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var summary kubeletSummary
+	if err := json.Unmarshal(raw, &summary); err != nil {
+		return nil, fmt.Errorf("failed to decode kubelet summary: %w", err)
+	}
+	return &summary, nil
+}
+
+// nodePodUsage is what the non-terminated pods scheduled on a node hold.
+type nodePodUsage struct {
+	Pods                 int
+	CPURequestMilliCores int64
+	MemoryRequestBytes   int64
+}
+
+// podUsageCache keeps the pod usage of the nodes between collector ticks.
+type podUsageCache struct {
+	mu         sync.Mutex
+	usage      map[string]nodePodUsage
+	fetchedAt  time.Time // Of the last listing, successful or not
+	refreshing bool
+}
+
+// cachedPodUsage returns the pod usage of each node. It is listed again in the background
+// once older than podUsageTTL, with a timeout of its own rather than the collector's; only
+// the first call waits for the listing. It is nil until a listing succeeds.
+func (s *Service) cachedPodUsage() map[string]nodePodUsage {
+	s.podUsage.mu.Lock()
+	usage := s.podUsage.usage
+	if s.podUsage.refreshing || time.Since(s.podUsage.fetchedAt) < podUsageTTL {
+		s.podUsage.mu.Unlock()
+		return usage
+	}
+	s.podUsage.refreshing = true
+	first := s.podUsage.fetchedAt.IsZero()
+	s.podUsage.mu.Unlock()
+
+	if !first {
+		go s.refreshPodUsage()
+		return usage
+	}
+	return s.refreshPodUsage()
+}
+
+// refreshPodUsage lists the pods within podUsageTimeout. On failure, the previous usage is
+// kept until the next attempt.
+func (s *Service) refreshPodUsage() map[string]nodePodUsage {
+	ctx, cancel := context.WithTimeout(context.Background(), podUsageTimeout)
+	defer cancel()
+	usage, err := s.podUsageByNode(ctx)
+
+	s.podUsage.mu.Lock()
+	defer s.podUsage.mu.Unlock()
+	s.podUsage.refreshing = false
+	if err != nil {
+		log.Printf("Warning: Could not sum pods per node: %v", err)
+		s.podUsage.fetchedAt = time.Now()
+		return s.podUsage.usage
+	}
+	return usage
+}
+
+// podUsageByNode returns the pod count and summed requests of the non-terminated pods of each node,
+// listed now, and refreshes the cached usage.
+func (s *Service) podUsageByNode(ctx context.Context) (map[string]nodePodUsage, error) {
+	pods, err := s.kubeClient.CoreV1().Pods(metav1.NamespaceAll).List(ctx, metav1.ListOptions{
+		FieldSelector: "status.phase!=Succeeded,status.phase!=Failed",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods: %w", err)
+	}
+	usage := make(map[string]nodePodUsage)
+	for _, pod := range pods.Items {
+		if pod.Spec.NodeName == "" {
+			continue
+		}
+		cpu, mem := PodRequests(&pod.Spec)
+		u := usage[pod.Spec.NodeName]
+		u.Pods++
+		u.CPURequestMilliCores += cpu
+		u.MemoryRequestBytes += mem
+		usage[pod.Spec.NodeName] = u
+	}
+
+	s.podUsage.mu.Lock()
+	s.podUsage.usage, s.podUsage.fetchedAt = usage, time.Now()
+	s.podUsage.mu.Unlock()
+	return usage, nil
+}
+
+// describeNode fills the status fields of NodeMetrics derived from the Node object,
+// the pods scheduled on it and the kubelet summary, and computes the node warnings.
+func describeNode(nm *NodeMetrics, n *corev1.Node, pods nodePodUsage, summary *kubeletSummary) {
+	nm.KubeletVersion = n.Status.NodeInfo.KubeletVersion
+	nm.PodCount = pods.Pods
+	nm.CPURequestedMilliCores = pods.CPURequestMilliCores
+	nm.MemoryRequestedBytes = pods.MemoryRequestBytes
+	nm.PodCapacity = n.Status.Allocatable.Pods().Value()
+	nm.EphemeralStorageAllocatableBytes = n.Status.Allocatable.StorageEphemeral().Value()
+
+	for label := range n.Labels {
+		if role := strings.TrimPrefix(label, nodeRoleLabelPrefix); role != label && role != "" {
+			nm.Roles = append(nm.Roles, role)
+		}
+	}
+	sort.Strings(nm.Roles)
+
+	for _, t := range n.Spec.Taints {
+		taint := t.Key
+		if t.Value != "" {
+			taint += "=" + t.Value
+		}
+		nm.Taints = append(nm.Taints, taint+":"+string(t.Effect))
+	}
+
+	for _, wanted := range reportedConditions {
+		for _, cond := range n.Status.Conditions {
+			if cond.Type != wanted {
+				continue
+			}
+			nm.Conditions = append(nm.Conditions, NodeCondition{
+				Type:    string(cond.Type),
+				Status:  string(cond.Status),
+				Reason:  cond.Reason,
+				Message: cond.Message,
+			})
+			if cond.Type == corev1.NodeReady {
+				nm.Ready = cond.Status == corev1.ConditionTrue
+				if !nm.Ready {
+					nm.Warnings = append(nm.Warnings, "node is not ready")
+				}
+			} else if cond.Status == corev1.ConditionTrue {
+				nm.Warnings = append(nm.Warnings, fmt.Sprintf("%s: %s", cond.Type, cond.Message))
+			}
+		}
+	}
+
+	if summary != nil && summary.Node.Fs != nil && summary.Node.Fs.CapacityBytes != nil {
+		fs := summary.Node.Fs.toFilesystemStats()
+		nm.Filesystem = fs
+		if fs.UsagePercentage >= diskUsageWarningPercentage {
+			nm.Warnings = append(nm.Warnings, fmt.Sprintf("root filesystem is %.0f%% full", fs.UsagePercentage))
+		}
+	}
+
+	if podUsage := percentage(int64(nm.PodCount), nm.PodCapacity); podUsage >= podUsageWarningPercentage {
+		nm.Warnings = append(nm.Warnings, fmt.Sprintf("%d of %d pods scheduled", nm.PodCount, nm.PodCapacity))
+	}
+}
@@ -0,0 +1,120 @@
+package metrics
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func scheduledPod(name, node, cpu string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "apps"},
+		Spec: corev1.PodSpec{
+			NodeName: node,
+			Containers: []corev1.Container{{
+				Name:      "app",
+				Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse(cpu)}},
+			}},
+		},
+	}
+}
+
+// expirePodUsage makes the cached pod usage stale.
+func expirePodUsage(s *Service) {
+	s.podUsage.mu.Lock()
+	s.podUsage.fetchedAt = time.Now().Add(-2 * podUsageTTL)
+	s.podUsage.mu.Unlock()
+}
+
+func TestCachedPodUsageRefreshesInTheBackground(t *testing.T) {
+	kc := fake.NewSimpleClientset(scheduledPod("web-1", "node-a", "250m"), scheduledPod("web-2", "node-a", "250m"))
+	s := NewService(kc, nil, Options{})
+
+	// Nothing cached yet: the first call waits for the listing
+	usage := s.cachedPodUsage()
+	if got := usage["node-a"]; got.Pods != 2 || got.CPURequestMilliCores != 500 {
+		t.Fatalf("first usage of node-a = %+v, want 2 pods requesting 500m", got)
+	}
+
+	// A slow listing no longer holds up the caller, which gets the cached usage meanwhile
+	release := make(chan struct{})
+	kc.PrependReactor("list", "pods", func(k8stesting.Action) (bool, runtime.Object, error) {
+		<-release
+		return false, nil, nil
+	})
+	if _, err := kc.CoreV1().Pods("apps").Create(t.Context(), scheduledPod("db-1", "node-b", "1"), metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	expirePodUsage(s)
+
+	returned := make(chan map[string]nodePodUsage)
+	go func() { returned <- s.cachedPodUsage() }()
+	select {
+	case usage = <-returned:
+	case <-time.After(time.Second):
+		close(release)
+		t.Fatal("cachedPodUsage() waited for the pods to be listed again")
+	}
+	if _, ok := usage["node-b"]; ok || usage["node-a"].Pods != 2 {
+		t.Errorf("usage during the refresh = %+v, want the cached usage", usage)
+	}
+	// Calls during the refresh do not start another listing
+	if again := s.cachedPodUsage(); again["node-a"].Pods != 2 {
+		t.Errorf("usage during the refresh = %+v", again)
+	}
+
+	close(release)
+	deadline := time.Now().Add(5 * time.Second)
+	for s.cachedPodUsage()["node-b"].CPURequestMilliCores != 1000 {
+		if time.Now().After(deadline) {
+			t.Fatal("the background listing never updated the usage")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestCachedPodUsageKeepsTheLastUsageOnFailure(t *testing.T) {
+	kc := fake.NewSimpleClientset(scheduledPod("web-1", "node-a", "100m"))
+	s := NewService(kc, nil, Options{})
+	s.cachedPodUsage()
+
+	listed := make(chan struct{}, 1)
+	kc.PrependReactor("list", "pods", func(k8stesting.Action) (bool, runtime.Object, error) {
+		listed <- struct{}{}
+		return true, nil, errors.New("the server was unable to return a response in the time allotted")
+	})
+	expirePodUsage(s)
+	s.cachedPodUsage()
+	<-listed
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		s.podUsage.mu.Lock()
+		refreshing, usage := s.podUsage.refreshing, s.podUsage.usage
+		s.podUsage.mu.Unlock()
+		if !refreshing {
+			if usage["node-a"].Pods != 1 {
+				t.Errorf("usage after a failed listing = %+v, want the previous usage", usage)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the failed refresh never finished")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	// The failure is not retried on every tick
+	s.cachedPodUsage()
+	select {
+	case <-listed:
+		t.Error("the pods were listed again right after a failure")
+	default:
+	}
+}
@@ -0,0 +1,73 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var (
+	nodeCPUUsageDesc = prometheus.NewDesc("appstore_node_cpu_usage_millicores",
+		"CPU used by the node, in millicores.", []string{"node"}, nil)
+	nodeMemUsageDesc = prometheus.NewDesc("appstore_node_memory_usage_bytes",
+		"Memory used by the node, in bytes.", []string{"node"}, nil)
+	nodeCPUAllocatableDesc = prometheus.NewDesc("appstore_node_cpu_allocatable_millicores",
+		"Allocatable CPU of the node, in millicores.", []string{"node"}, nil)
+	nodeMemAllocatableDesc = prometheus.NewDesc("appstore_node_memory_allocatable_bytes",
+		"Allocatable memory of the node, in bytes.", []string{"node"}, nil)
+	clusterCPUUsageDesc = prometheus.NewDesc("appstore_cluster_cpu_usage_millicores",
+		"CPU used by all nodes, in millicores.", nil, nil)
+	clusterCPUCapacityDesc = prometheus.NewDesc("appstore_cluster_cpu_capacity_millicores",
+		"Allocatable CPU of all nodes, in millicores.", nil, nil)
+	clusterMemUsageDesc = prometheus.NewDesc("appstore_cluster_memory_usage_bytes",
+		"Memory used by all nodes, in bytes.", nil, nil)
+	clusterMemCapacityDesc = prometheus.NewDesc("appstore_cluster_memory_capacity_bytes",
+		"Allocatable memory of all nodes, in bytes.", nil, nil)
+	releaseCPUUsageDesc = prometheus.NewDesc("appstore_release_cpu_usage_millicores",
+		"CPU used by the pods of a release, in millicores.", []string{"release"}, nil)
+	releaseMemUsageDesc = prometheus.NewDesc("appstore_release_memory_usage_bytes",
+		"Memory used by the pods of a release, in bytes.", []string{"release"}, nil)
+)
+
+// snapshotCollector exports the latest collector snapshot as Prometheus gauges.
+// It reads the shared snapshot instead of querying the cluster on every scrape.
+type snapshotCollector struct {
+	service *Service
+}
+
+// PrometheusCollector returns a collector exporting the node, cluster and release
+// gauges computed by the service.
+func (s *Service) PrometheusCollector() prometheus.Collector {
+	return &snapshotCollector{service: s}
+}
+
+func (c *snapshotCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{
+		nodeCPUUsageDesc, nodeMemUsageDesc, nodeCPUAllocatableDesc, nodeMemAllocatableDesc,
+		clusterCPUUsageDesc, clusterCPUCapacityDesc, clusterMemUsageDesc, clusterMemCapacityDesc,
+		releaseCPUUsageDesc, releaseMemUsageDesc,
+	} {
+		ch <- d
+	}
+}
+
+func (c *snapshotCollector) Collect(ch chan<- prometheus.Metric) {
+	snapshot := c.service.LatestSnapshot()
+	if snapshot == nil {
+		return
+	}
+	gauge := func(desc *prometheus.Desc, value int64, labels ...string) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, float64(value), labels...)
+	}
+
+	gauge(clusterCPUUsageDesc, snapshot.TotalCPUUsageMilliCores)
+	gauge(clusterCPUCapacityDesc, snapshot.TotalCPUCapacityMilliCores)
+	gauge(clusterMemUsageDesc, snapshot.TotalMemoryUsageBytes)
+	gauge(clusterMemCapacityDesc, snapshot.TotalMemoryCapacityBytes)
+	for _, n := range snapshot.Nodes {
+		gauge(nodeCPUUsageDesc, n.CPUUsageMilliCores, n.Name)
+		gauge(nodeMemUsageDesc, n.MemoryUsageBytes, n.Name)
+		gauge(nodeCPUAllocatableDesc, n.CPUAvailableMilliCores, n.Name)
+		gauge(nodeMemAllocatableDesc, n.MemoryAvailableBytes, n.Name)
+	}
+	for _, r := range snapshot.Releases {
+		gauge(releaseCPUUsageDesc, r.CPUUsageMilliCores, r.Release)
+		gauge(releaseMemUsageDesc, r.MemoryUsageBytes, r.Release)
+	}
+}
@@ -0,0 +1,129 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// releaseInstanceLabel is the standard label Helm charts put on the objects of a release.
+const releaseInstanceLabel = "app.kubernetes.io/instance"
+
+// releaseTotals accumulates the per-container figures of one release.
+type releaseTotals struct {
+	metrics      ReleaseMetrics
+	cpuUnlimited bool
+	memUnlimited bool
+}
+
+// GetReleaseMetrics returns the resource usage of every release in the install namespace,
+// grouping pods by their app.kubernetes.io/instance label.
+func (s *Service) GetReleaseMetrics() ([]ReleaseMetrics, error) {
+	return s.collectReleaseMetrics(releaseInstanceLabel)
+}
+
+// GetReleaseMetricsByName returns the usage of a single release.
+// ok is false when the release has no running pods, or its name is no valid label value.
+func (s *Service) GetReleaseMetricsByName(releaseName string) (*ReleaseMetrics, bool, error) {
+	selector, err := labels.ValidatedSelectorFromSet(labels.Set{releaseInstanceLabel: releaseName})
+	if err != nil {
+		// No release can have such a name, and it must not widen the selector
+		return nil, false, nil
+	}
+	result, err := s.collectReleaseMetrics(selector.String())
+	if err != nil || len(result) == 0 {
+		return nil, false, err
+	}
+	return &result[0], true, nil
+}
+
+// collectReleaseMetrics aggregates the pods matching labelSelector per release.
+func (s *Service) collectReleaseMetrics(labelSelector string) ([]ReleaseMetrics, error) {
+	if s.metricsClient == nil {
+		return nil, fmt.Errorf("metrics client not initialized, ensure Metrics Server is installed and API has permissions")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	listOptions := metav1.ListOptions{LabelSelector: labelSelector}
+	podMetricsList, err := s.metricsClient.MetricsV1beta1().PodMetricses(s.options.Namespace).List(ctx, listOptions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pod metrics in namespace %s: %w", s.options.Namespace, err)
+	}
+	pods, err := s.kubeClient.CoreV1().Pods(s.options.Namespace).List(ctx, listOptions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods in namespace %s: %w", s.options.Namespace, err)
+	}
+
+	totals := make(map[string]*releaseTotals)
+	podRelease := make(map[string]string, len(pods.Items))
+	for _, pod := range pods.Items {
+		if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		releaseName := pod.Labels[releaseInstanceLabel]
+		podRelease[pod.Name] = releaseName
+		t, ok := totals[releaseName]
+		if !ok {
+			t = &releaseTotals{metrics: ReleaseMetrics{Release: releaseName}}
+			totals[releaseName] = t
+		}
+		t.metrics.Pods++
+		for _, container := range pod.Spec.Containers {
+			t.metrics.CPURequestMilliCores += container.Resources.Requests.Cpu().MilliValue()
+			t.metrics.MemoryRequestBytes += container.Resources.Requests.Memory().Value()
+			if cpuLimit, ok := container.Resources.Limits[corev1.ResourceCPU]; ok {
+				t.metrics.CPULimitMilliCores += cpuLimit.MilliValue()
+			} else {
+				t.cpuUnlimited = true
+			}
+			if memLimit, ok := container.Resources.Limits[corev1.ResourceMemory]; ok {
+				t.metrics.MemoryLimitBytes += memLimit.Value()
+			} else {
+				t.memUnlimited = true
+			}
+		}
+	}
+
+	for _, pm := range podMetricsList.Items {
+		releaseName, ok := podRelease[pm.Name]
+		if !ok {
+			continue
+		}
+		t := totals[releaseName]
+		for _, container := range pm.Containers {
+			t.metrics.CPUUsageMilliCores += container.Usage.Cpu().MilliValue()
+			t.metrics.MemoryUsageBytes += container.Usage.Memory().Value()
+		}
+	}
+
+	result := make([]ReleaseMetrics, 0, len(totals))
+	for _, t := range totals {
+		m := t.metrics
+		if t.cpuUnlimited {
+			m.CPULimitMilliCores = 0
+		}
+		if t.memUnlimited {
+			m.MemoryLimitBytes = 0
+		}
+		m.CPUUsageOfRequestPercentage = percentage(m.CPUUsageMilliCores, m.CPURequestMilliCores)
+		m.MemUsageOfRequestPercentage = percentage(m.MemoryUsageBytes, m.MemoryRequestBytes)
+		m.CPUUsageOfLimitPercentage = percentage(m.CPUUsageMilliCores, m.CPULimitMilliCores)
+		m.MemUsageOfLimitPercentage = percentage(m.MemoryUsageBytes, m.MemoryLimitBytes)
+		result = append(result, m)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Release < result[j].Release })
+	return result, nil
+}
+
+func percentage(used, total int64) float64 {
+	if total <= 0 {
+		return 0
+	}
+	return float64(used*100) / float64(total)
+}
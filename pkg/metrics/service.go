@@ -16,14 +16,105 @@ import (
 type Service struct {
 	kubeClient    kubernetes.Interface
 	metricsClient metricsv1beta1.Interface
+	hub           *Hub
+	history       *History
+	summaries     summaryCache
+	podUsage      podUsageCache
+	options       Options
+}
+
+// Options configures a metrics service.
+type Options struct {
+	Namespace       string // Namespace where releases are installed, for per-release metrics
+	CollectReleases bool   // Whether the collector also gathers per-release metrics
+	HistoryFile     string // Optional file the history is persisted to
 }
 
 // NewService creates a new metrics service.
-func NewService(kc kubernetes.Interface, mc metricsv1beta1.Interface) *Service {
+func NewService(kc kubernetes.Interface, mc metricsv1beta1.Interface, opts Options) *Service {
 	if mc == nil {
 		log.Println("Metrics client is nil in NewService, metrics features will be limited.")
 	}
-	return &Service{kubeClient: kc, metricsClient: mc}
+	s := &Service{kubeClient: kc, metricsClient: mc, hub: NewHub(), history: NewHistory(), options: opts}
+	if opts.HistoryFile != "" {
+		if err := s.history.Load(opts.HistoryFile); err != nil {
+			log.Printf("Warning: Could not load metrics history: %v", err)
+		} else {
+			log.Printf("Loaded metrics history from %s", opts.HistoryFile)
+		}
+	}
+	return s
+}
+
+// Run collects a cluster snapshot every interval and publishes it to the subscribers
+// until ctx is cancelled. A single collector serves every stream client, so the load on
+// the API server does not grow with the number of open dashboards.
+func (s *Service) Run(ctx context.Context, interval time.Duration) {
+	if s.metricsClient == nil {
+		log.Println("Metrics client not initialized, metrics collector not started.")
+		return
+	}
+	log.Printf("Starting metrics collector (interval %v)", interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	lastSave := time.Now()
+
+	for {
+		snapshot, err := s.GetClusterMetricsSnapshot()
+		if err != nil {
+			log.Printf("Error getting cluster metrics snapshot: %v", err)
+		} else {
+			if s.options.CollectReleases {
+				if snapshot.Releases, err = s.GetReleaseMetrics(); err != nil {
+					log.Printf("Error getting release metrics: %v", err)
+				}
+			}
+			s.hub.Publish(snapshot)
+			s.history.Add(sampleFromSnapshot(time.Now(), snapshot))
+		}
+
+		if s.options.HistoryFile != "" && time.Since(lastSave) >= time.Minute {
+			s.saveHistory()
+			lastSave = time.Now()
+		}
+
+		select {
+		case <-ctx.Done():
+			s.saveHistory()
+			log.Println("Metrics collector stopped.")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Service) saveHistory() {
+	if s.options.HistoryFile == "" {
+		return
+	}
+	if err := s.history.Save(s.options.HistoryFile); err != nil {
+		log.Printf("Warning: Could not persist metrics history: %v", err)
+	}
+}
+
+// GetHistory returns the collected samples between from and to, averaged into buckets of step if positive.
+func (s *Service) GetHistory(from, to time.Time, step time.Duration) []Sample {
+	return s.history.Query(from, to, step)
+}
+
+// Subscribe registers a consumer of the snapshots published by the collector.
+func (s *Service) Subscribe() *Subscription {
+	return s.hub.Subscribe()
+}
+
+// Unsubscribe removes a consumer registered with Subscribe.
+func (s *Service) Unsubscribe(sub *Subscription) {
+	s.hub.Unsubscribe(sub)
+}
+
+// LatestSnapshot returns the last snapshot published by the collector, or nil.
+func (s *Service) LatestSnapshot() *ClusterMetrics {
+	return s.hub.Latest()
 }
 
 // GetClusterMetricsSnapshot fetches a snapshot of current cluster, node, and pod metrics.
@@ -53,7 +144,11 @@ func (s *Service) GetClusterMetricsSnapshot() (*ClusterMetrics, error) {
 	}
 	log.Printf("Fetched %d nodes from API (node list took %v, total metrics time so far: %v)", len(nodes.Items), time.Since(nodesListStartTime), time.Since(startTime))
 
+	podUsage := s.cachedPodUsage()
+	summaries := s.kubeletSummaries(nodes.Items)
+
 	var detailedNodeMetrics []NodeMetrics
+	var warnings []string
 	var totalCPUUsageMilliCores, totalCPUCapacityMilliCores int64
 	var totalMemUsageBytes, totalMemCapacityBytes int64
 
@@ -97,7 +192,7 @@ func (s *Service) GetClusterMetricsSnapshot() (*ClusterMetrics, error) {
 			memUsagePercent = float64(nodeMemUsageBytes*100) / float64(allocatableMemBytes)
 		}
 
-		detailedNodeMetrics = append(detailedNodeMetrics, NodeMetrics{
+		nodeMetrics := NodeMetrics{
 			Name:                   n.Name,
 			CPUUsageMilliCores:     nodeCPUUsageMilli,
 			MemoryUsageBytes:       nodeMemUsageBytes,
@@ -105,7 +200,12 @@ func (s *Service) GetClusterMetricsSnapshot() (*ClusterMetrics, error) {
 			MemoryAvailableBytes:   allocatableMemBytes,
 			CPUUsagePercentage:     cpuUsagePercent,
 			MemUsagePercentage:     memUsagePercent,
-		})
+		}
+		describeNode(&nodeMetrics, &n, podUsage[n.Name], summaries[n.Name])
+		for _, w := range nodeMetrics.Warnings {
+			warnings = append(warnings, fmt.Sprintf("%s: %s", n.Name, w))
+		}
+		detailedNodeMetrics = append(detailedNodeMetrics, nodeMetrics)
 	}
 
 	avgCPUUsage := 0.0
@@ -126,5 +226,6 @@ func (s *Service) GetClusterMetricsSnapshot() (*ClusterMetrics, error) {
 		AverageCPUUsagePercentage:  avgCPUUsage,
 		AverageMemUsagePercentage:  avgMemUsage,
 		Nodes:                      detailedNodeMetrics,
+		Warnings:                   warnings,
 	}, nil
 }
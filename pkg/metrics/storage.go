@@ -0,0 +1,172 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Annotations marking the default StorageClass, current and beta.
+var defaultStorageClassAnnotations = []string{
+	"storageclass.kubernetes.io/is-default-class",
+	"storageclass.beta.kubernetes.io/is-default-class",
+}
+
+// StorageClassInfo describes a StorageClass of the cluster.
+type StorageClassInfo struct {
+	Name                 string `json:"name"`
+	Provisioner          string `json:"provisioner"`
+	IsDefault            bool   `json:"is_default"`
+	ReclaimPolicy        string `json:"reclaim_policy,omitempty"`
+	VolumeBindingMode    string `json:"volume_binding_mode,omitempty"`
+	AllowVolumeExpansion bool   `json:"allow_volume_expansion"`
+}
+
+// VolumeClaim describes a PersistentVolumeClaim of a release.
+type VolumeClaim struct {
+	Name           string           `json:"name"`
+	StorageClass   string           `json:"storage_class,omitempty"`
+	Phase          string           `json:"phase"` // Pending, Bound or Lost
+	Bound          bool             `json:"bound"`
+	VolumeName     string           `json:"volume_name,omitempty"`
+	AccessModes    []string         `json:"access_modes,omitempty"`
+	RequestedBytes int64            `json:"requested_bytes"`
+	CapacityBytes  int64            `json:"capacity_bytes"`  // Provisioned size, once bound
+	Usage          *FilesystemStats `json:"usage,omitempty"` // From the kubelet summary API, while a pod mounts the claim
+}
+
+// ReleaseStorage groups the claims of one release.
+type ReleaseStorage struct {
+	Release string        `json:"release"` // Empty for claims without the app.kubernetes.io/instance label
+	Claims  []VolumeClaim `json:"claims"`
+}
+
+// StorageOverview is the storage available to releases and what they use.
+type StorageOverview struct {
+	StorageClasses      []StorageClassInfo `json:"storage_classes"`
+	DefaultStorageClass string             `json:"default_storage_class,omitempty"`
+	Releases            []ReleaseStorage   `json:"releases"`
+	Warnings            []string           `json:"warnings,omitempty"`
+}
+
+// isDefaultStorageClass reports whether sc is annotated as the default StorageClass.
+func isDefaultStorageClass(sc *storagev1.StorageClass) bool {
+	for _, annotation := range defaultStorageClassAnnotations {
+		if sc.Annotations[annotation] == "true" {
+			return true
+		}
+	}
+	return false
+}
+
+// GetStorage lists the StorageClasses and the PersistentVolumeClaims of the install namespace,
+// grouped by release.
+func (s *Service) GetStorage() (*StorageOverview, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	classes, err := s.kubeClient.StorageV1().StorageClasses().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list storage classes: %w", err)
+	}
+	overview := &StorageOverview{StorageClasses: make([]StorageClassInfo, 0, len(classes.Items)), Releases: []ReleaseStorage{}}
+	for i := range classes.Items {
+		sc := &classes.Items[i]
+		info := StorageClassInfo{
+			Name:                 sc.Name,
+			Provisioner:          sc.Provisioner,
+			IsDefault:            isDefaultStorageClass(sc),
+			AllowVolumeExpansion: sc.AllowVolumeExpansion != nil && *sc.AllowVolumeExpansion,
+		}
+		if sc.ReclaimPolicy != nil {
+			info.ReclaimPolicy = string(*sc.ReclaimPolicy)
+		}
+		if sc.VolumeBindingMode != nil {
+			info.VolumeBindingMode = string(*sc.VolumeBindingMode)
+		}
+		if info.IsDefault {
+			overview.DefaultStorageClass = sc.Name
+		}
+		overview.StorageClasses = append(overview.StorageClasses, info)
+	}
+	if len(classes.Items) == 0 {
+		overview.Warnings = append(overview.Warnings, "The cluster has no StorageClass, charts requesting persistent volumes will stay Pending")
+	} else if overview.DefaultStorageClass == "" {
+		overview.Warnings = append(overview.Warnings, "No default StorageClass, claims without a storage class will stay Pending")
+	}
+
+	pvcs, err := s.kubeClient.CoreV1().PersistentVolumeClaims(s.options.Namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list persistent volume claims in namespace %s: %w", s.options.Namespace, err)
+	}
+	usage := s.volumeUsage(ctx)
+
+	byRelease := make(map[string][]VolumeClaim)
+	for _, pvc := range pvcs.Items {
+		claim := VolumeClaim{
+			Name:           pvc.Name,
+			Phase:          string(pvc.Status.Phase),
+			Bound:          pvc.Status.Phase == corev1.ClaimBound,
+			VolumeName:     pvc.Spec.VolumeName,
+			RequestedBytes: pvc.Spec.Resources.Requests.Storage().Value(),
+			CapacityBytes:  pvc.Status.Capacity.Storage().Value(),
+			Usage:          usage[pvc.Name],
+		}
+		if pvc.Spec.StorageClassName != nil {
+			claim.StorageClass = *pvc.Spec.StorageClassName
+		}
+		for _, mode := range pvc.Spec.AccessModes {
+			claim.AccessModes = append(claim.AccessModes, string(mode))
+		}
+		if pvc.Status.Phase == corev1.ClaimPending {
+			overview.Warnings = append(overview.Warnings, fmt.Sprintf("Claim %s is not bound", pvc.Name))
+		}
+		release := pvc.Labels[releaseInstanceLabel]
+		byRelease[release] = append(byRelease[release], claim)
+	}
+	for release, claims := range byRelease {
+		sort.Slice(claims, func(i, j int) bool { return claims[i].Name < claims[j].Name })
+		overview.Releases = append(overview.Releases, ReleaseStorage{Release: release, Claims: claims})
+	}
+	sort.Slice(overview.Releases, func(i, j int) bool { return overview.Releases[i].Release < overview.Releases[j].Release })
+	return overview, nil
+}
+
+// volumeUsage returns the usage of the mounted claims of the install namespace, by claim
+// name, from the kubelet summaries. It is empty when the summary API is not permitted.
+func (s *Service) volumeUsage(ctx context.Context) map[string]*FilesystemStats {
+	usage := make(map[string]*FilesystemStats)
+	nodes, err := s.kubeClient.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return usage
+	}
+	for _, summary := range s.kubeletSummaries(nodes.Items) {
+		for _, pod := range summary.Pods {
+			for _, vol := range pod.Volumes {
+				if vol.PVCRef != nil && vol.PVCRef.Namespace == s.options.Namespace && vol.CapacityBytes != nil {
+					usage[vol.PVCRef.Name] = vol.toFilesystemStats()
+				}
+			}
+		}
+	}
+	return usage
+}
+
+// StorageClassExists reports whether a StorageClass named name exists.
+func (s *Service) StorageClassExists(name string) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	_, err := s.kubeClient.StorageV1().StorageClasses().Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("failed to get storage class %s: %w", name, err)
+	}
+	return true, nil
+}
@@ -9,6 +9,35 @@ type NodeMetrics struct {
 	MemoryAvailableBytes   int64   `json:"memory_available_bytes"`    // Total allocatable bytes
 	CPUUsagePercentage     float64 `json:"cpu_usage_percentage"`
 	MemUsagePercentage     float64 `json:"mem_usage_percentage"`
+
+	CPURequestedMilliCores           int64            `json:"cpu_requested_milli_cores"` // Summed requests of the pods on the node
+	MemoryRequestedBytes             int64            `json:"memory_requested_bytes"`
+	Ready                            bool             `json:"ready"`
+	Conditions                       []NodeCondition  `json:"conditions,omitempty"` // Ready, MemoryPressure, DiskPressure, PIDPressure
+	KubeletVersion                   string           `json:"kubelet_version,omitempty"`
+	Roles                            []string         `json:"roles,omitempty"`
+	Taints                           []string         `json:"taints,omitempty"` // "key=value:Effect"
+	PodCount                         int              `json:"pod_count"`        // Non-terminated pods scheduled on the node
+	PodCapacity                      int64            `json:"pod_capacity"`     // Allocatable pods
+	EphemeralStorageAllocatableBytes int64            `json:"ephemeral_storage_allocatable_bytes"`
+	Filesystem                       *FilesystemStats `json:"filesystem,omitempty"` // From the kubelet summary API, when permitted
+	Warnings                         []string         `json:"warnings,omitempty"`
+}
+
+// NodeCondition is the state of one node condition.
+type NodeCondition struct {
+	Type    string `json:"type"`
+	Status  string `json:"status"` // "True", "False" or "Unknown"
+	Reason  string `json:"reason,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// FilesystemStats is the usage of the node root filesystem reported by the kubelet.
+type FilesystemStats struct {
+	CapacityBytes   int64   `json:"capacity_bytes"`
+	UsedBytes       int64   `json:"used_bytes"`
+	AvailableBytes  int64   `json:"available_bytes"`
+	UsagePercentage float64 `json:"usage_percentage"`
 }
 
 // ClusterMetrics aggregates metrics for the entire cluster.
@@ -20,4 +49,24 @@ type ClusterMetrics struct {
 	AverageCPUUsagePercentage  float64       `json:"average_cpu_usage_percentage"`
 	AverageMemUsagePercentage  float64       `json:"average_mem_usage_percentage"`
 	Nodes                      []NodeMetrics `json:"nodes,omitempty"`
+	Warnings                   []string      `json:"warnings,omitempty"` // Node warnings, prefixed with the node name
+	// Releases is only filled by the shared collector, and only sent to stream clients asking for it.
+	Releases []ReleaseMetrics `json:"releases,omitempty"`
+}
+
+// ReleaseMetrics aggregates the resource usage of the pods of one Helm release,
+// compared with the requests and limits declared in their specs.
+type ReleaseMetrics struct {
+	Release                     string  `json:"release"`
+	Pods                        int     `json:"pods"`
+	CPUUsageMilliCores          int64   `json:"cpu_usage_milli_cores"`
+	MemoryUsageBytes            int64   `json:"memory_usage_bytes"`
+	CPURequestMilliCores        int64   `json:"cpu_request_milli_cores"`
+	CPULimitMilliCores          int64   `json:"cpu_limit_milli_cores"` // 0 when any container is unlimited
+	MemoryRequestBytes          int64   `json:"memory_request_bytes"`
+	MemoryLimitBytes            int64   `json:"memory_limit_bytes"` // 0 when any container is unlimited
+	CPUUsageOfRequestPercentage float64 `json:"cpu_usage_of_request_percentage,omitempty"`
+	MemUsageOfRequestPercentage float64 `json:"mem_usage_of_request_percentage,omitempty"`
+	CPUUsageOfLimitPercentage   float64 `json:"cpu_usage_of_limit_percentage,omitempty"`
+	MemUsageOfLimitPercentage   float64 `json:"mem_usage_of_limit_percentage,omitempty"`
 }
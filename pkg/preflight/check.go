@@ -0,0 +1,214 @@
+package preflight
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"app-store-api/pkg/metrics"
+)
+
+// tightPercentage is the share of a node's allocatable CPU or memory above which
+// an install that still fits is reported as tight.
+const tightPercentage = 90.0
+
+// ErrWontSchedule is returned when an enforced preflight predicts Pending pods.
+var ErrWontSchedule = errors.New("the cluster does not have room for this release")
+
+// Verdict is the outcome of a preflight check.
+type Verdict string
+
+const (
+	VerdictFits         Verdict = "fits"
+	VerdictTight        Verdict = "tight"
+	VerdictWontSchedule Verdict = "wont_schedule"
+)
+
+// NodeFit is the state of a node once the release pods are placed on it.
+type NodeFit struct {
+	Name                      string  `json:"name"`
+	Eligible                  bool    `json:"eligible"` // Ready and schedulable
+	FreeCPUMilliCores         int64   `json:"free_cpu_milli_cores"`
+	FreeMemoryBytes           int64   `json:"free_memory_bytes"`
+	PlacedPods                int     `json:"placed_pods"`
+	CPURequestedPercentage    float64 `json:"cpu_requested_percentage"` // After the install
+	MemoryRequestedPercentage float64 `json:"memory_requested_percentage"`
+}
+
+// Report is the result of a preflight check.
+type Report struct {
+	Verdict                   Verdict    `json:"verdict"`
+	Reasons                   []string   `json:"reasons,omitempty"`
+	TotalCPURequestMilliCores int64      `json:"total_cpu_request_milli_cores"`
+	TotalMemoryRequestBytes   int64      `json:"total_memory_request_bytes"`
+	Workloads                 []Workload `json:"workloads"`
+	Nodes                     []NodeFit  `json:"nodes"`
+	ClusterWarnings           []string   `json:"cluster_warnings,omitempty"` // Set by the caller, from the latest cluster metrics
+}
+
+// pendingPod is one pod of a workload waiting for a node.
+type pendingPod struct {
+	workload *Workload
+	node     string // Required node, for DaemonSet pods
+}
+
+// nodeState tracks the remaining capacity of a node during placement.
+type nodeState struct {
+	capacity metrics.NodeCapacity
+	cpu, mem int64 // Requested, including placed pods
+	pods     int
+	placed   int
+}
+
+// Check simulates the scheduling of the workloads on the nodes. Pods are placed
+// largest first on the eligible node with the most free CPU, which approximates the
+// default scheduler spreading without reproducing affinity or topology rules.
+func Check(workloads []Workload, nodes []metrics.NodeCapacity) *Report {
+	report := &Report{Verdict: VerdictFits, Workloads: workloads}
+
+	states := make([]*nodeState, len(nodes))
+	for i, n := range nodes {
+		states[i] = &nodeState{capacity: n, cpu: n.RequestedCPUMilliCores, mem: n.RequestedMemoryBytes, pods: n.Pods}
+	}
+
+	var pods []pendingPod
+	for i := range workloads {
+		w := &workloads[i]
+		if w.PerNode {
+			w.Replicas = 0
+			for _, s := range states {
+				if eligible(s.capacity, w) {
+					pods = append(pods, pendingPod{workload: w, node: s.capacity.Name})
+					w.Replicas++
+				}
+			}
+		} else {
+			for r := 0; r < w.Replicas; r++ {
+				pods = append(pods, pendingPod{workload: w})
+			}
+		}
+		report.TotalCPURequestMilliCores += w.CPURequestMilliCores * int64(w.Replicas)
+		report.TotalMemoryRequestBytes += w.MemoryRequestBytes * int64(w.Replicas)
+	}
+	sort.SliceStable(pods, func(i, j int) bool {
+		a, b := pods[i].workload, pods[j].workload
+		if a.CPURequestMilliCores != b.CPURequestMilliCores {
+			return a.CPURequestMilliCores > b.CPURequestMilliCores
+		}
+		return a.MemoryRequestBytes > b.MemoryRequestBytes
+	})
+
+	unplaced := make(map[*Workload]int)
+	for _, pod := range pods {
+		var best *nodeState
+		for _, s := range states {
+			if pod.node != "" && s.capacity.Name != pod.node {
+				continue
+			}
+			if !eligible(s.capacity, pod.workload) || !s.fits(pod.workload) {
+				continue
+			}
+			if best == nil || s.capacity.AllocatableCPUMilliCores-s.cpu > best.capacity.AllocatableCPUMilliCores-best.cpu {
+				best = s
+			}
+		}
+		if best == nil {
+			unplaced[pod.workload]++
+			continue
+		}
+		best.cpu += pod.workload.CPURequestMilliCores
+		best.mem += pod.workload.MemoryRequestBytes
+		best.pods++
+		best.placed++
+	}
+
+	for i := range workloads {
+		w := &workloads[i]
+		if n := unplaced[w]; n > 0 {
+			report.Verdict = VerdictWontSchedule
+			report.Reasons = append(report.Reasons, fmt.Sprintf("%s %s: %d of %d pods (%s CPU, %s memory each) fit on no node",
+				w.Kind, w.Name, n, w.Replicas, formatCPU(w.CPURequestMilliCores), formatMemory(w.MemoryRequestBytes)))
+		}
+	}
+	if len(workloads) > 0 && report.TotalCPURequestMilliCores == 0 && report.TotalMemoryRequestBytes == 0 {
+		report.Reasons = append(report.Reasons, "The chart declares no resource requests, its actual usage cannot be predicted")
+	}
+
+	for _, s := range states {
+		fit := NodeFit{
+			Name:                      s.capacity.Name,
+			Eligible:                  s.capacity.Ready && !s.capacity.Unschedulable,
+			FreeCPUMilliCores:         s.capacity.AllocatableCPUMilliCores - s.cpu,
+			FreeMemoryBytes:           s.capacity.AllocatableMemoryBytes - s.mem,
+			PlacedPods:                s.placed,
+			CPURequestedPercentage:    percentage(s.cpu, s.capacity.AllocatableCPUMilliCores),
+			MemoryRequestedPercentage: percentage(s.mem, s.capacity.AllocatableMemoryBytes),
+		}
+		report.Nodes = append(report.Nodes, fit)
+		if s.placed > 0 && (fit.CPURequestedPercentage > tightPercentage || fit.MemoryRequestedPercentage > tightPercentage) {
+			if report.Verdict == VerdictFits {
+				report.Verdict = VerdictTight
+			}
+			report.Reasons = append(report.Reasons, fmt.Sprintf("Node %s would have %.0f%% of its CPU and %.0f%% of its memory requested",
+				fit.Name, fit.CPURequestedPercentage, fit.MemoryRequestedPercentage))
+		}
+	}
+	return report
+}
+
+// fits reports whether one more pod of w fits in the remaining capacity of the node.
+func (s *nodeState) fits(w *Workload) bool {
+	c := s.capacity
+	if c.AllocatablePods > 0 && int64(s.pods+1) > c.AllocatablePods {
+		return false
+	}
+	return s.cpu+w.CPURequestMilliCores <= c.AllocatableCPUMilliCores &&
+		s.mem+w.MemoryRequestBytes <= c.AllocatableMemoryBytes
+}
+
+// eligible reports whether the scheduler would consider the node for pods of w.
+func eligible(n metrics.NodeCapacity, w *Workload) bool {
+	if !n.Ready || n.Unschedulable {
+		return false
+	}
+	for k, v := range w.NodeSelector {
+		if n.Labels[k] != v {
+			return false
+		}
+	}
+	for i := range n.Taints {
+		taint := &n.Taints[i]
+		if taint.Effect == corev1.TaintEffectPreferNoSchedule {
+			continue
+		}
+		tolerated := false
+		for _, t := range w.Tolerations {
+			if t.ToleratesTaint(taint) {
+				tolerated = true
+				break
+			}
+		}
+		if !tolerated {
+			return false
+		}
+	}
+	return true
+}
+
+func percentage(used, total int64) float64 {
+	if total <= 0 {
+		return 0
+	}
+	return float64(used*100) / float64(total)
+}
+
+func formatCPU(milliCores int64) string {
+	return resource.NewMilliQuantity(milliCores, resource.DecimalSI).String()
+}
+
+func formatMemory(bytes int64) string {
+	return resource.NewQuantity(bytes, resource.BinarySI).String()
+}
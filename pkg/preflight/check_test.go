@@ -0,0 +1,214 @@
+package preflight
+
+import (
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"app-store-api/pkg/metrics"
+)
+
+const mib = 1 << 20
+
+// renderedChart is the manifest of a release as Helm renders it: a web Deployment with an
+// init container, a database StatefulSet without replicas, a log shipping DaemonSet, and
+// objects that create no pods at install time.
+const renderedChart = `---
+apiVersion: v1
+kind: Service
+metadata:
+  name: wiki
+spec:
+  ports: [{port: 80}]
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: wiki-web
+spec:
+  replicas: 2
+  template:
+    spec:
+      initContainers:
+        - name: migrate
+          resources: {requests: {cpu: 500m, memory: 32Mi}}
+      containers:
+        - name: web
+          resources: {requests: {cpu: 100m, memory: 128Mi}}
+        - name: metrics
+          resources: {requests: {cpu: 150m, memory: 64Mi}}
+---
+apiVersion: apps/v1
+kind: StatefulSet
+metadata:
+  name: wiki-db
+spec:
+  template:
+    spec:
+      containers:
+        - name: postgres
+          resources: {requests: {cpu: "1", memory: 1Gi}}
+---
+apiVersion: apps/v1
+kind: DaemonSet
+metadata:
+  name: wiki-logs
+spec:
+  template:
+    spec:
+      tolerations:
+        - {key: node-role.kubernetes.io/control-plane, operator: Exists, effect: NoSchedule}
+      containers:
+        - name: shipper
+          resources: {requests: {cpu: 50m, memory: 16Mi}}
+---
+apiVersion: batch/v1
+kind: CronJob
+metadata:
+  name: wiki-backup
+spec:
+  schedule: "0 3 * * *"
+  jobTemplate:
+    spec:
+      template:
+        spec:
+          containers:
+            - name: backup
+              resources: {requests: {cpu: "4"}}
+---
+apiVersion: monitoring.coreos.com/v1
+kind: ServiceMonitor
+metadata:
+  name: wiki
+`
+
+func TestWorkloadsFromManifest(t *testing.T) {
+	workloads, err := WorkloadsFromManifest(renderedChart)
+	if err != nil {
+		t.Fatalf("WorkloadsFromManifest() error = %v", err)
+	}
+	want := []Workload{
+		// The init container runs alone, so the pod needs the larger of it and the containers
+		{Kind: "Deployment", Name: "wiki-web", Replicas: 2, CPURequestMilliCores: 500, MemoryRequestBytes: 192 * mib},
+		{Kind: "StatefulSet", Name: "wiki-db", Replicas: 1, CPURequestMilliCores: 1000, MemoryRequestBytes: 1024 * mib},
+		{Kind: "DaemonSet", Name: "wiki-logs", Replicas: 1, PerNode: true, CPURequestMilliCores: 50, MemoryRequestBytes: 16 * mib},
+	}
+	if len(workloads) != len(want) {
+		t.Fatalf("WorkloadsFromManifest() found %d workloads, want %d: %+v", len(workloads), len(want), workloads)
+	}
+	for i, w := range workloads {
+		if w.Kind != want[i].Kind || w.Name != want[i].Name || w.Replicas != want[i].Replicas || w.PerNode != want[i].PerNode ||
+			w.CPURequestMilliCores != want[i].CPURequestMilliCores || w.MemoryRequestBytes != want[i].MemoryRequestBytes {
+			t.Errorf("workload %d = %s %s with %d replicas of %dm CPU and %d bytes, want %+v",
+				i, w.Kind, w.Name, w.Replicas, w.CPURequestMilliCores, w.MemoryRequestBytes, want[i])
+		}
+	}
+	if len(workloads[2].Tolerations) != 1 {
+		t.Errorf("the DaemonSet tolerations were not kept: %+v", workloads[2].Tolerations)
+	}
+}
+
+// worker is a ready worker node with 2 CPUs and 4Gi of memory, of which cpu and memory
+// are already requested.
+func worker(name string, cpu, memory int64) metrics.NodeCapacity {
+	return metrics.NodeCapacity{
+		Name: name, Ready: true,
+		AllocatableCPUMilliCores: 2000, AllocatableMemoryBytes: 4096 * mib, AllocatablePods: 110,
+		RequestedCPUMilliCores: cpu, RequestedMemoryBytes: memory,
+	}
+}
+
+// controlPlane is a ready control plane node, tainted against ordinary workloads.
+func controlPlane() metrics.NodeCapacity {
+	n := worker("control-plane", 0, 0)
+	n.Taints = []corev1.Taint{{Key: "node-role.kubernetes.io/control-plane", Effect: corev1.TaintEffectNoSchedule}}
+	return n
+}
+
+func placed(report *Report) map[string]int {
+	pods := make(map[string]int)
+	for _, n := range report.Nodes {
+		pods[n.Name] = n.PlacedPods
+	}
+	return pods
+}
+
+func TestCheckRenderedChart(t *testing.T) {
+	workloads, err := WorkloadsFromManifest(renderedChart)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("room on the workers", func(t *testing.T) {
+		report := Check(append([]Workload(nil), workloads...), []metrics.NodeCapacity{worker("a", 0, 0), worker("b", 0, 0), controlPlane()})
+		if report.Verdict != VerdictFits {
+			t.Fatalf("verdict = %s (%v), want %s", report.Verdict, report.Reasons, VerdictFits)
+		}
+		// The database goes first, then both web pods to the node with the most CPU left, and
+		// the DaemonSet, which tolerates the control plane taint, runs on every node
+		if got := placed(report); got["a"] != 2 || got["b"] != 3 || got["control-plane"] != 1 {
+			t.Errorf("pods placed = %v, want 2 on a, 3 on b and 1 on the control plane", got)
+		}
+		if report.TotalCPURequestMilliCores != 2*500+1000+3*50 {
+			t.Errorf("total CPU request = %dm, want %dm", report.TotalCPURequestMilliCores, 2*500+1000+3*50)
+		}
+	})
+
+	t.Run("busy workers", func(t *testing.T) {
+		// 800m and 400m of CPU left: room for a single web pod, and none for the database
+		report := Check(append([]Workload(nil), workloads...), []metrics.NodeCapacity{worker("a", 1200, 0), worker("b", 1600, 0), controlPlane()})
+		if report.Verdict != VerdictWontSchedule {
+			t.Fatalf("verdict = %s, want %s", report.Verdict, VerdictWontSchedule)
+		}
+		want := []string{"Deployment wiki-web: 1 of 2 pods", "StatefulSet wiki-db: 1 of 1 pods"}
+		if len(report.Reasons) != len(want) {
+			t.Fatalf("reasons = %q, want the web and database pods reported", report.Reasons)
+		}
+		for i, prefix := range want {
+			if !strings.HasPrefix(report.Reasons[i], prefix) {
+				t.Errorf("reason %d = %q, want it to start with %q", i, report.Reasons[i], prefix)
+			}
+		}
+	})
+
+	t.Run("nearly full worker", func(t *testing.T) {
+		report := Check(append([]Workload(nil), workloads[1:2]...), []metrics.NodeCapacity{worker("a", 900, 0)})
+		if report.Verdict != VerdictTight {
+			t.Fatalf("verdict = %s, want %s", report.Verdict, VerdictTight)
+		}
+		if len(report.Reasons) != 1 || !strings.Contains(report.Reasons[0], "Node a would have 95% of its CPU") {
+			t.Errorf("reasons = %q", report.Reasons)
+		}
+	})
+}
+
+func TestCheckNodeEligibility(t *testing.T) {
+	web := Workload{Kind: "Deployment", Name: "web", Replicas: 1, CPURequestMilliCores: 100, MemoryRequestBytes: 64 * mib}
+	ssd := worker("ssd", 0, 0)
+	ssd.Labels = map[string]string{"disktype": "ssd"}
+	cordoned := worker("cordoned", 0, 0)
+	cordoned.Unschedulable = true
+	notReady := worker("not-ready", 0, 0)
+	notReady.Ready = false
+	full := worker("full", 0, 0)
+	full.AllocatablePods, full.Pods = 30, 30
+
+	for _, nodes := range [][]metrics.NodeCapacity{{controlPlane()}, {cordoned}, {notReady}, {full}} {
+		if report := Check([]Workload{web}, nodes); report.Verdict != VerdictWontSchedule {
+			t.Errorf("on node %s: verdict = %s, want %s", nodes[0].Name, report.Verdict, VerdictWontSchedule)
+		}
+	}
+
+	pinned := web
+	pinned.NodeSelector = map[string]string{"disktype": "ssd"}
+	report := Check([]Workload{pinned}, []metrics.NodeCapacity{worker("plain", 0, 0), ssd})
+	if got := placed(report); report.Verdict != VerdictFits || got["ssd"] != 1 {
+		t.Errorf("with a node selector: verdict %s, pods placed %v, want the pod on ssd", report.Verdict, got)
+	}
+
+	unrequested := Check([]Workload{{Kind: "Deployment", Name: "web", Replicas: 1}}, []metrics.NodeCapacity{worker("a", 0, 0)})
+	if unrequested.Verdict != VerdictFits || len(unrequested.Reasons) != 1 {
+		t.Errorf("without requests: verdict %s, reasons %q, want fits with a warning", unrequested.Verdict, unrequested.Reasons)
+	}
+}
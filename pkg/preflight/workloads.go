@@ -0,0 +1,98 @@
+package preflight
+
+import (
+	"fmt"
+	"sort"
+
+	"helm.sh/helm/v3/pkg/releaseutil"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+
+	"app-store-api/pkg/metrics"
+)
+
+// Workload is a controller of the rendered chart and the pods it will create.
+type Workload struct {
+	Kind                 string              `json:"kind"`
+	Name                 string              `json:"name"`
+	Replicas             int                 `json:"replicas"`           // Pods to schedule; for a DaemonSet, one per eligible node
+	PerNode              bool                `json:"per_node,omitempty"` // DaemonSet
+	CPURequestMilliCores int64               `json:"cpu_request_milli_cores"`
+	MemoryRequestBytes   int64               `json:"memory_request_bytes"`
+	NodeSelector         map[string]string   `json:"node_selector,omitempty"`
+	Tolerations          []corev1.Toleration `json:"-"`
+}
+
+// WorkloadsFromManifest extracts the workloads of a rendered manifest, in manifest order.
+// CronJobs are ignored: they create no pods at install time.
+func WorkloadsFromManifest(manifest string) ([]Workload, error) {
+	decoder := scheme.Codecs.UniversalDeserializer()
+	docs := releaseutil.SplitManifests(manifest)
+	keys := make([]string, 0, len(docs))
+	for key := range docs {
+		keys = append(keys, key)
+	}
+	sort.Sort(releaseutil.BySplitManifestsOrder(keys))
+
+	var workloads []Workload
+	for _, key := range keys {
+		doc := docs[key]
+		obj, _, err := decoder.Decode([]byte(doc), nil, nil)
+		if err != nil {
+			// Custom resources are not registered in the scheme and hold no pods we can size
+			continue
+		}
+
+		var kind, name string
+		var spec *corev1.PodSpec
+		replicas := int32(1)
+		perNode := false
+		switch o := obj.(type) {
+		case *appsv1.Deployment:
+			kind, name, spec = "Deployment", o.Name, &o.Spec.Template.Spec
+			if o.Spec.Replicas != nil {
+				replicas = *o.Spec.Replicas
+			}
+		case *appsv1.StatefulSet:
+			kind, name, spec = "StatefulSet", o.Name, &o.Spec.Template.Spec
+			if o.Spec.Replicas != nil {
+				replicas = *o.Spec.Replicas
+			}
+		case *appsv1.ReplicaSet:
+			kind, name, spec = "ReplicaSet", o.Name, &o.Spec.Template.Spec
+			if o.Spec.Replicas != nil {
+				replicas = *o.Spec.Replicas
+			}
+		case *appsv1.DaemonSet:
+			kind, name, spec = "DaemonSet", o.Name, &o.Spec.Template.Spec
+			perNode = true
+		case *batchv1.Job:
+			kind, name, spec = "Job", o.Name, &o.Spec.Template.Spec
+			if o.Spec.Parallelism != nil {
+				replicas = *o.Spec.Parallelism
+			}
+		case *corev1.Pod:
+			kind, name, spec = "Pod", o.Name, &o.Spec
+		default:
+			continue
+		}
+		if replicas < 0 {
+			return nil, fmt.Errorf("%s %s has a negative replica count", kind, name)
+		}
+
+		cpu, mem := metrics.PodRequests(spec)
+		workloads = append(workloads, Workload{
+			Kind:                 kind,
+			Name:                 name,
+			Replicas:             int(replicas),
+			PerNode:              perNode,
+			CPURequestMilliCores: cpu,
+			MemoryRequestBytes:   mem,
+			NodeSelector:         spec.NodeSelector,
+			Tolerations:          spec.Tolerations,
+		})
+	}
+	return workloads, nil
+}
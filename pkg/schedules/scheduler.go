@@ -0,0 +1,117 @@
+package schedules
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"app-store-api/pkg/audit"
+	"app-store-api/pkg/helm"
+)
+
+// SchedulerActor is the audit actor of the transitions made by the scheduler.
+const SchedulerActor = "scheduler"
+
+// Scheduler puts releases to sleep and wakes them up following their schedules.
+type Scheduler struct {
+	store    *Store
+	helm     *helm.HelmClient
+	audit    *audit.Service // May be nil
+	location *time.Location
+}
+
+// NewScheduler creates a scheduler reading the schedule times in location.
+func NewScheduler(store *Store, hc *helm.HelmClient, auditService *audit.Service, location *time.Location) *Scheduler {
+	return &Scheduler{store: store, helm: hc, audit: auditService, location: location}
+}
+
+// Run applies the schedules every interval until ctx is cancelled.
+func (s *Scheduler) Run(ctx context.Context, interval time.Duration) {
+	log.Printf("Starting release scheduler (interval %v, time zone %s)", interval, s.location)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		s.reconcile(time.Now())
+		select {
+		case <-ctx.Done():
+			log.Println("Release scheduler stopped.")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// reconcile suspends the releases entering a sleep window and resumes those leaving one.
+// Only transitions are acted on, so a release woken up by hand stays up until its next window.
+func (s *Scheduler) reconcile(now time.Time) {
+	records, err := s.store.List()
+	if err != nil {
+		log.Printf("Scheduler: %v", err)
+		return
+	}
+	for i := range records {
+		rec := &records[i]
+		if rec.Schedule.Validate() != nil {
+			continue
+		}
+		asleep := rec.Schedule.Asleep(now.In(s.location))
+		if asleep == rec.Asleep {
+			continue
+		}
+		s.transition(rec, asleep, now)
+	}
+}
+
+// transition suspends or resumes a release and records the new state.
+func (s *Scheduler) transition(rec *Record, asleep bool, now time.Time) {
+	action, run := audit.ActionResume, s.helm.ResumeRelease
+	if asleep {
+		action, run = audit.ActionSuspend, s.helm.SuspendRelease
+	}
+
+	report, err := run(rec.Release)
+	if errors.Is(err, helm.ErrReleaseNotFound) {
+		log.Printf("Scheduler: release '%s' no longer exists, deleting its schedule", rec.Release)
+		if err := s.store.Delete(rec.Release); err != nil {
+			log.Printf("Scheduler: %v", err)
+		}
+		return
+	}
+	if err == nil {
+		err = report.Err()
+	}
+	s.record(audit.Entry{Time: now, Action: action, Actor: SchedulerActor, Release: rec.Release}, now, err)
+
+	if err != nil {
+		log.Printf("Scheduler: %s of release '%s' failed: %v", action, rec.Release, err)
+		rec.LastError = err.Error()
+	} else {
+		if asleep {
+			log.Printf("Scheduler: release '%s' put to sleep", rec.Release)
+		} else {
+			log.Printf("Scheduler: release '%s' woken up", rec.Release)
+		}
+		rec.Asleep = asleep
+		rec.LastTransition = &now
+		rec.LastError = ""
+	}
+	if err := s.store.Save(rec); err != nil {
+		// A concurrent edit wins; the transition is retried on the next run if still needed
+		log.Printf("Scheduler: could not save the state of release '%s': %v", rec.Release, err)
+	}
+}
+
+func (s *Scheduler) record(entry audit.Entry, startTime time.Time, opErr error) {
+	if s.audit == nil {
+		return
+	}
+	entry.DurationMs = time.Since(startTime).Milliseconds()
+	if opErr != nil {
+		entry.Outcome = audit.OutcomeFailure
+		entry.Error = opErr.Error()
+	} else {
+		entry.Outcome = audit.OutcomeSuccess
+	}
+	s.audit.Record(entry)
+}
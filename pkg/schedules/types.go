@@ -0,0 +1,131 @@
+package schedules
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is returned when a release has no schedule.
+var ErrNotFound = errors.New("schedule not found")
+
+// ErrConflict is returned when a schedule was modified concurrently.
+var ErrConflict = errors.New("schedule was modified concurrently")
+
+// dayAliases expand to the days they stand for.
+var dayAliases = map[string][]time.Weekday{
+	"daily":    {time.Sunday, time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Saturday},
+	"weekdays": {time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday},
+	"weekend":  {time.Saturday, time.Sunday},
+	"sun":      {time.Sunday},
+	"mon":      {time.Monday},
+	"tue":      {time.Tuesday},
+	"wed":      {time.Wednesday},
+	"thu":      {time.Thursday},
+	"fri":      {time.Friday},
+	"sat":      {time.Saturday},
+}
+
+// Window is a period the release sleeps in, repeated on each of its days.
+type Window struct {
+	Days []string `json:"days"` // "mon" to "sun", "weekdays", "weekend" or "daily"
+	From string   `json:"from"` // "HH:MM"
+	To   string   `json:"to"`   // "HH:MM", "24:00" for midnight; earlier than From when the window spans midnight
+}
+
+// Schedule lists the windows a release sleeps in. Times are read in the scheduler time zone.
+type Schedule struct {
+	Sleep []Window `json:"sleep"`
+}
+
+// Validate checks the days and times of every window.
+func (s Schedule) Validate() error {
+	if len(s.Sleep) == 0 {
+		return errors.New("'sleep' needs at least one window")
+	}
+	for i, w := range s.Sleep {
+		if _, err := w.weekdays(); err != nil {
+			return fmt.Errorf("window %d: %w", i+1, err)
+		}
+		from, err := parseClock(w.From)
+		if err != nil {
+			return fmt.Errorf("window %d: invalid 'from': %w", i+1, err)
+		}
+		to, err := parseClock(w.To)
+		if err != nil {
+			return fmt.Errorf("window %d: invalid 'to': %w", i+1, err)
+		}
+		if from == to || from == 24*60 {
+			return fmt.Errorf("window %d: 'from' and 'to' must differ and 'from' must be before 24:00", i+1)
+		}
+	}
+	return nil
+}
+
+// Asleep reports whether t falls in a sleep window. The schedule must be valid.
+func (s Schedule) Asleep(t time.Time) bool {
+	minute := t.Hour()*60 + t.Minute()
+	today := t.Weekday()
+	yesterday := (today + 6) % 7
+	for _, w := range s.Sleep {
+		days, _ := w.weekdays()
+		from, _ := parseClock(w.From)
+		to, _ := parseClock(w.To)
+		if from < to {
+			if days[today] && minute >= from && minute < to {
+				return true
+			}
+			continue
+		}
+		// The window starts on one of its days and ends the next day
+		if (days[today] && minute >= from) || (days[yesterday] && minute < to) {
+			return true
+		}
+	}
+	return false
+}
+
+// weekdays returns the days of the window.
+func (w Window) weekdays() (map[time.Weekday]bool, error) {
+	if len(w.Days) == 0 {
+		return nil, errors.New("'days' is empty")
+	}
+	days := make(map[time.Weekday]bool)
+	for _, d := range w.Days {
+		expanded, ok := dayAliases[strings.ToLower(strings.TrimSpace(d))]
+		if !ok {
+			return nil, fmt.Errorf("unknown day '%s'", d)
+		}
+		for _, day := range expanded {
+			days[day] = true
+		}
+	}
+	return days, nil
+}
+
+// parseClock returns the minutes since midnight of an "HH:MM" time, from 00:00 to 24:00.
+func parseClock(s string) (int, error) {
+	var hours, minutes int
+	if _, err := fmt.Sscanf(s, "%d:%d", &hours, &minutes); err != nil || len(s) != 5 {
+		return 0, fmt.Errorf("'%s' is not HH:MM", s)
+	}
+	if hours < 0 || minutes < 0 || minutes > 59 || hours > 24 || (hours == 24 && minutes != 0) {
+		return 0, fmt.Errorf("'%s' is not between 00:00 and 24:00", s)
+	}
+	return hours*60 + minutes, nil
+}
+
+// Record is the schedule of a release with the state the scheduler left it in.
+type Record struct {
+	Release   string    `json:"release"`
+	Schedule  Schedule  `json:"schedule"`
+	UpdatedBy string    `json:"updated_by"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// Asleep is set while the scheduler keeps the release suspended.
+	Asleep         bool       `json:"asleep"`
+	LastTransition *time.Time `json:"last_transition,omitempty"`
+	LastError      string     `json:"last_error,omitempty"` // Error of the last failed transition
+
+	resourceVersion string
+}
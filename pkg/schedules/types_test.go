@@ -0,0 +1,112 @@
+package schedules
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+// schedule decodes a schedule as the API receives it.
+func schedule(t *testing.T, body string) Schedule {
+	t.Helper()
+	var s Schedule
+	if err := json.Unmarshal([]byte(body), &s); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	return s
+}
+
+// transitions walks from start to end a minute at a time, as the scheduler would, and lists
+// the times the release goes to sleep ("sleep Mon 20:00") and wakes up ("wake Tue 07:00").
+func transitions(s Schedule, start, end time.Time) []string {
+	var out []string
+	asleep := s.Asleep(start)
+	for t := start.Add(time.Minute); t.Before(end); t = t.Add(time.Minute) {
+		if now := s.Asleep(t); now != asleep {
+			asleep = now
+			event := "wake "
+			if now {
+				event = "sleep "
+			}
+			out = append(out, event+t.Format("Mon 15:04"))
+		}
+	}
+	return out
+}
+
+// January 5, 2026 is a Monday.
+var monday = time.Date(2026, time.January, 5, 0, 0, 0, 0, time.UTC)
+
+func TestScheduleWeek(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		body string
+		want string
+	}{{
+		name: "weeknights",
+		body: `{"sleep": [{"days": ["weekdays"], "from": "20:00", "to": "07:00"}]}`,
+		// Friday night spills into Saturday; Sunday night does not start a window
+		want: "sleep Mon 20:00, wake Tue 07:00, sleep Tue 20:00, wake Wed 07:00, sleep Wed 20:00, wake Thu 07:00, " +
+			"sleep Thu 20:00, wake Fri 07:00, sleep Fri 20:00, wake Sat 07:00",
+	}, {
+		name: "weekends and lunch breaks",
+		body: `{"sleep": [{"days": ["Weekend"], "from": "00:00", "to": "24:00"}, {"days": [" wed", "fri"], "from": "12:00", "to": "13:00"}]}`,
+		want: "sleep Wed 12:00, wake Wed 13:00, sleep Fri 12:00, wake Fri 13:00, sleep Sat 00:00",
+	}, {
+		name: "overlapping windows",
+		body: `{"sleep": [{"days": ["daily"], "from": "22:00", "to": "02:00"}, {"days": ["daily"], "from": "01:00", "to": "06:00"}]}`,
+		want: "wake Mon 06:00, sleep Mon 22:00, wake Tue 06:00, sleep Tue 22:00, wake Wed 06:00, sleep Wed 22:00, " +
+			"wake Thu 06:00, sleep Thu 22:00, wake Fri 06:00, sleep Fri 22:00, wake Sat 06:00, sleep Sat 22:00, wake Sun 06:00, sleep Sun 22:00",
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := strings.Join(transitions(schedule(t, tc.body), monday, monday.AddDate(0, 0, 7)), ", ")
+			if got != tc.want {
+				t.Errorf("over the week:\n got  %s\n want %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestScheduleAcrossDaylightSaving(t *testing.T) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Skipf("no time zone database: %v", err)
+	}
+	// The clocks go from 02:00 to 03:00 on March 29, 2026; windows follow the wall clock
+	s := schedule(t, `{"sleep": [{"days": ["sun"], "from": "01:00", "to": "04:00"}]}`)
+	start := time.Date(2026, time.March, 28, 12, 0, 0, 0, paris)
+	var slept time.Duration
+	for t := start; t.Before(start.Add(24 * time.Hour)); t = t.Add(time.Minute) {
+		if s.Asleep(t.In(paris)) {
+			slept += time.Minute
+		}
+	}
+	if slept != 2*time.Hour {
+		t.Errorf("slept %v on the night of the change, want 2h", slept)
+	}
+}
+
+func TestScheduleValidate(t *testing.T) {
+	for body, want := range map[string]string{
+		`{"sleep": []}`: "at least one window",
+		`{"sleep": [{"days": ["mon"], "from": "08:00", "to": "18:00"}, {"from": "08:00", "to": "18:00"}]}`: "window 2: 'days' is empty",
+		`{"sleep": [{"days": ["someday"], "from": "08:00", "to": "18:00"}]}`:                               "unknown day 'someday'",
+		`{"sleep": [{"days": ["mon"], "from": "8:00", "to": "18:00"}]}`:                                    "invalid 'from': '8:00' is not HH:MM",
+		`{"sleep": [{"days": ["mon"], "from": "08:00", "to": "24:01"}]}`:                                   "invalid 'to': '24:01' is not between",
+		`{"sleep": [{"days": ["mon"], "from": "08:60", "to": "18:00"}]}`:                                   "'08:60' is not between",
+		`{"sleep": [{"days": ["mon"], "from": "08:00", "to": "08:00"}]}`:                                   "must differ",
+		`{"sleep": [{"days": ["mon"], "from": "24:00", "to": "06:00"}]}`:                                   "before 24:00",
+	} {
+		var s Schedule
+		if err := json.Unmarshal([]byte(body), &s); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Validate(); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Validate() of %s = %v, want an error with %q", body, err, want)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"app-store-api/pkg/config"
+)
+
+// certCheckInterval bounds how often the certificate files are checked for changes.
+const certCheckInterval = 10 * time.Second
+
+// certReloader serves a key pair from disk and reloads it when either file changes,
+// so rotated certificates (e.g. from cert-manager) are picked up without a restart.
+type certReloader struct {
+	certFile string
+	keyFile  string
+
+	mu          sync.Mutex
+	cert        *tls.Certificate
+	certModTime time.Time
+	keyModTime  time.Time
+	lastCheck   time.Time
+}
+
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	r := &certReloader{certFile: certFile, keyFile: keyFile}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *certReloader) reload() error {
+	certInfo, err := os.Stat(r.certFile)
+	if err != nil {
+		return fmt.Errorf("failed to stat TLS certificate %s: %w", r.certFile, err)
+	}
+	keyInfo, err := os.Stat(r.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to stat TLS key %s: %w", r.keyFile, err)
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load TLS key pair: %w", err)
+	}
+	r.cert = &cert
+	r.certModTime = certInfo.ModTime()
+	r.keyModTime = keyInfo.ModTime()
+	return nil
+}
+
+// getCertificate implements tls.Config.GetCertificate.
+// A failed reload keeps serving the previous certificate.
+func (r *certReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if time.Since(r.lastCheck) >= certCheckInterval {
+		r.lastCheck = time.Now()
+		certInfo, certErr := os.Stat(r.certFile)
+		keyInfo, keyErr := os.Stat(r.keyFile)
+		if certErr == nil && keyErr == nil &&
+			(!certInfo.ModTime().Equal(r.certModTime) || !keyInfo.ModTime().Equal(r.keyModTime)) {
+			if err := r.reload(); err != nil {
+				log.Printf("Warning: TLS certificate changed but could not be reloaded, keeping the previous one: %v", err)
+			} else {
+				log.Printf("Reloaded TLS certificate from %s", r.certFile)
+			}
+		}
+	}
+	return r.cert, nil
+}
+
+// NewTLSConfig builds the server TLS configuration from the application config.
+// It returns nil when TLS is not configured.
+func NewTLSConfig(cfg *config.AppConfig) (*tls.Config, error) {
+	if cfg.TLSCertFile == "" && cfg.TLSKeyFile == "" {
+		return nil, nil
+	}
+	if cfg.TLSCertFile == "" || cfg.TLSKeyFile == "" {
+		return nil, fmt.Errorf("both TLS_CERT_FILE and TLS_KEY_FILE must be set to enable TLS")
+	}
+
+	reloader, err := newCertReloader(cfg.TLSCertFile, cfg.TLSKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.getCertificate,
+	}
+
+	if cfg.TLSClientCAFile != "" {
+		caPEM, err := os.ReadFile(cfg.TLSClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read TLS client CA file %s: %w", cfg.TLSClientCAFile, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("no valid certificates found in TLS client CA file %s", cfg.TLSClientCAFile)
+		}
+		tlsConfig.ClientCAs = pool
+
+		switch cfg.TLSClientAuth {
+		case "request":
+			tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+		case "", "require":
+			tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+		default:
+			return nil, fmt.Errorf("unknown TLS_CLIENT_AUTH '%s' (expected request or require)", cfg.TLSClientAuth)
+		}
+	}
+	return tlsConfig, nil
+}
@@ -0,0 +1,93 @@
+package telemetry
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+const namespace = "appstore"
+
+// Registry holds every metric exported on GET /metrics.
+var Registry = prometheus.NewRegistry()
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests handled, by method, route and status code.",
+	}, []string{"method", "route", "status"})
+
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency, by method and route.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	helmOperations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "helm_operations_total",
+		Help:      "Helm operations, by operation, chart and outcome (success or failure).",
+	}, []string{"operation", "chart", "outcome"})
+
+	helmDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "helm_operation_duration_seconds",
+		Help:      "Duration of Helm operations, by operation and chart.",
+		Buckets:   []float64{1, 5, 15, 30, 60, 120, 300, 600},
+	}, []string{"operation", "chart"})
+
+	helmInFlight = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "helm_operations_in_flight",
+		Help:      "Helm operations currently running, by operation.",
+	}, []string{"operation"})
+
+	repoRefreshes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "helm_repo_refresh_total",
+		Help:      "Helm repository refreshes, by repository and result (success or failure).",
+	}, []string{"repo", "result"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests, httpDuration,
+		helmOperations, helmDuration, helmInFlight,
+		repoRefreshes,
+	)
+}
+
+// ObserveHTTPRequest records one handled HTTP request.
+func ObserveHTTPRequest(method, route, status string, duration time.Duration) {
+	httpRequests.WithLabelValues(method, route, status).Inc()
+	httpDuration.WithLabelValues(method, route).Observe(duration.Seconds())
+}
+
+// StartHelmOperation marks a Helm operation as in flight. Call the returned function
+// with the chart name and the operation error (nil on success) once it completes.
+func StartHelmOperation(operation string) func(chart string, err error) {
+	start := time.Now()
+	helmInFlight.WithLabelValues(operation).Inc()
+	return func(chart string, err error) {
+		helmInFlight.WithLabelValues(operation).Dec()
+		helmOperations.WithLabelValues(operation, chart, outcome(err)).Inc()
+		helmDuration.WithLabelValues(operation, chart).Observe(time.Since(start).Seconds())
+	}
+}
+
+// ObserveRepoRefresh records the result of refreshing one Helm repository.
+func ObserveRepoRefresh(repo string, err error) {
+	repoRefreshes.WithLabelValues(repo, outcome(err)).Inc()
+}
+
+func outcome(err error) string {
+	if err != nil {
+		return "failure"
+	}
+	return "success"
+}
@@ -0,0 +1,35 @@
+package values
+
+// Depth returns the nesting depth of a values tree.
+// An empty map has depth 0, a flat map of scalars has depth 1.
+func Depth(vals map[string]interface{}) int {
+	return depthOf(vals)
+}
+
+func depthOf(v interface{}) int {
+	maxChild := 0
+	switch typed := v.(type) {
+	case map[string]interface{}:
+		if len(typed) == 0 {
+			return 0
+		}
+		for _, child := range typed {
+			if d := depthOf(child); d > maxChild {
+				maxChild = d
+			}
+		}
+		return maxChild + 1
+	case []interface{}:
+		if len(typed) == 0 {
+			return 0
+		}
+		for _, child := range typed {
+			if d := depthOf(child); d > maxChild {
+				maxChild = d
+			}
+		}
+		return maxChild + 1
+	default:
+		return 0
+	}
+}
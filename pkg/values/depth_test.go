@@ -0,0 +1,29 @@
+package values
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestDepth(t *testing.T) {
+	cases := map[string]int{
+		`{}`:                              0,
+		`{"replicaCount": 2}`:             1,
+		`{"image": {"tag": "1.2.3"}}`:     2,
+		`{"a": {"b": {"c": {}}}, "d": 1}`: 3, // An empty container adds no level
+		`{"extraEnv": [{"name": "A", "value": "1"}]}`: 3, // List items count as a level
+		`{"a": [[[1]]]}`: 4,
+	}
+	for doc, want := range cases {
+		var vals map[string]interface{}
+		if err := json.Unmarshal([]byte(doc), &vals); err != nil {
+			t.Fatal(err)
+		}
+		if got := Depth(vals); got != want {
+			t.Errorf("Depth(%s) = %d, want %d", doc, got, want)
+		}
+	}
+	if got := Depth(nil); got != 0 {
+		t.Errorf("Depth(nil) = %d, want 0", got)
+	}
+}
@@ -0,0 +1,83 @@
+package values
+
+import (
+	"reflect"
+	"sort"
+)
+
+// Mismatch returns the first path, in key order, where got does not hold the value set in want.
+// Keys only present in got are ignored, lists are compared as a whole, and values for which
+// skip returns true are not compared. It returns false when every value of want is found in got.
+func Mismatch(want, got map[string]interface{}, skip func(interface{}) bool) (string, bool) {
+	return mismatch(want, got, "", skip)
+}
+
+func mismatch(want, got interface{}, path string, skip func(interface{}) bool) (string, bool) {
+	if skip != nil && skip(want) {
+		return "", false
+	}
+	wantMap, ok := want.(map[string]interface{})
+	if !ok {
+		if !reflect.DeepEqual(want, got) {
+			return path, true
+		}
+		return "", false
+	}
+	gotMap, ok := got.(map[string]interface{})
+	if !ok {
+		if len(wantMap) == 0 {
+			return "", false
+		}
+		return path, true
+	}
+
+	keys := make([]string, 0, len(wantMap))
+	for k := range wantMap {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		childPath := k
+		if path != "" {
+			childPath = path + "." + k
+		}
+		if p, found := mismatch(wantMap[k], gotMap[k], childPath, skip); found {
+			return p, true
+		}
+	}
+	return "", false
+}
+
+// Normalize returns vals as decoded from JSON, with every number a float64 and every list
+// a []interface{}, so trees decoded from YAML and JSON can be compared.
+func Normalize(vals map[string]interface{}) map[string]interface{} {
+	out, _ := normalize(vals).(map[string]interface{})
+	return out
+}
+
+func normalize(v interface{}) interface{} {
+	switch typed := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(typed))
+		for k, child := range typed {
+			out[k] = normalize(child)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(typed))
+		for i, child := range typed {
+			out[i] = normalize(child)
+		}
+		return out
+	case int:
+		return float64(typed)
+	case int64:
+		return float64(typed)
+	case uint64:
+		return float64(typed)
+	case float32:
+		return float64(typed)
+	default:
+		return v
+	}
+}
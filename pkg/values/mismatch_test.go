@@ -0,0 +1,98 @@
+package values
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// declared are the values of an app in the desired-state file, decoded as the sync decodes them.
+const declared = `
+replicaCount: 2
+resources:
+  limits: {cpu: 0.5, memory: 256Mi}
+ingress:
+  hosts: [wiki.example.com]
+podLabels: {}
+auth:
+  password: {secretRef: {name: wiki-admin, key: password}}
+`
+
+// isRef stands for helm.IsSecretRef, resolved at install so never found as is in the release.
+func isRef(v interface{}) bool {
+	m, ok := v.(map[string]interface{})
+	_, ref := m["secretRef"]
+	return ok && ref && len(m) == 1
+}
+
+// deployed decodes the values of a release as Helm stores them, in JSON.
+func deployed(t *testing.T, config string) map[string]interface{} {
+	t.Helper()
+	var out map[string]interface{}
+	if err := json.Unmarshal([]byte(config), &out); err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestMismatchAgainstTheDeployedValues(t *testing.T) {
+	var want map[string]interface{}
+	if err := yaml.Unmarshal([]byte(declared), &want); err != nil {
+		t.Fatal(err)
+	}
+	want = Normalize(want)
+
+	for _, tc := range []struct {
+		name     string
+		config   string
+		wantPath string
+	}{
+		// The release also holds the generated credentials, which are not declared
+		{"as declared", `{"replicaCount": 2, "resources": {"limits": {"cpu": 0.5, "memory": "256Mi"}},
+			"ingress": {"hosts": ["wiki.example.com"]}, "auth": {"password": "resolved", "username": "admin"}, "generated": {"secretKey": "x"}}`, ""},
+		{"scaled by hand", `{"auth": {"password": "resolved"}, "replicaCount": 3,
+			"resources": {"limits": {"cpu": 0.5, "memory": "256Mi"}}, "ingress": {"hosts": ["wiki.example.com"]}}`, "replicaCount"},
+		{"limit raised", `{"auth": {"password": "resolved"}, "replicaCount": 2,
+			"resources": {"limits": {"cpu": 1, "memory": "256Mi"}}, "ingress": {"hosts": ["wiki.example.com"]}}`, "resources.limits.cpu"},
+		{"host added", `{"auth": {"password": "resolved"}, "replicaCount": 2,
+			"resources": {"limits": {"cpu": 0.5, "memory": "256Mi"}}, "ingress": {"hosts": ["wiki.example.com", "www.example.com"]}}`, "ingress.hosts"},
+		{"ingress values dropped", `{"auth": {"password": "resolved"}, "replicaCount": 2,
+			"resources": {"limits": {"cpu": 0.5, "memory": "256Mi"}}, "ingress": null}`, "ingress"},
+		// Paths are reported in key order
+		{"installed without values", `{}`, "auth"},
+	} {
+		path, found := Mismatch(want, deployed(t, tc.config), isRef)
+		if path != tc.wantPath || found != (tc.wantPath != "") {
+			t.Errorf("%s: Mismatch() = %q, %t, want %q", tc.name, path, found, tc.wantPath)
+		}
+	}
+
+	// Without Normalize, YAML integers never equal the JSON numbers
+	var raw map[string]interface{}
+	if err := yaml.Unmarshal([]byte("replicaCount: 2"), &raw); err != nil {
+		t.Fatal(err)
+	}
+	if path, found := Mismatch(raw, deployed(t, `{"replicaCount": 2}`), nil); !found || path != "replicaCount" {
+		t.Errorf("Mismatch() of raw YAML = %q, %t", path, found)
+	}
+}
+
+func TestNormalizeMatchesJSON(t *testing.T) {
+	var fromYAML map[string]interface{}
+	if err := yaml.Unmarshal([]byte(`{count: 3, big: 9007199254740993, ratio: 0.25, tags: [1, two, {id: 4}], on: true, none: null}`), &fromYAML); err != nil {
+		t.Fatal(err)
+	}
+	fromYAML["small"] = int64(-7)
+	fromYAML["half"] = float32(0.5)
+
+	got := Normalize(fromYAML)
+	want := deployed(t, `{"count": 3, "big": 9007199254740993, "ratio": 0.25, "tags": [1, "two", {"id": 4}], "on": true, "none": null, "small": -7, "half": 0.5}`)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Normalize() = %#v, want the JSON decoding %#v", got, want)
+	}
+	if _, isInt := fromYAML["count"].(int); !isInt {
+		t.Error("Normalize() modified its input")
+	}
+}
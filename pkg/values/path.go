@@ -0,0 +1,119 @@
+package values
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Paths address a value with dot-separated keys, e.g. "auth.rootPassword".
+// Numeric segments index into lists, e.g. "extraEnv.0.value".
+
+// SplitPath splits a dot-separated value path into its segments.
+func SplitPath(path string) []string {
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, ".")
+}
+
+// Get returns the value stored at path.
+func Get(vals map[string]interface{}, path string) (interface{}, bool) {
+	var current interface{} = vals
+	for _, segment := range SplitPath(path) {
+		switch typed := current.(type) {
+		case map[string]interface{}:
+			next, ok := typed[segment]
+			if !ok {
+				return nil, false
+			}
+			current = next
+		case []interface{}:
+			i, err := strconv.Atoi(segment)
+			if err != nil || i < 0 || i >= len(typed) {
+				return nil, false
+			}
+			current = typed[i]
+		default:
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// Set stores value at path, creating intermediate maps as needed.
+// It returns false when the path crosses a scalar or an out-of-range list index.
+func Set(vals map[string]interface{}, path string, value interface{}) bool {
+	segments := SplitPath(path)
+	if len(segments) == 0 {
+		return false
+	}
+	var current interface{} = vals
+	for i, segment := range segments {
+		last := i == len(segments)-1
+		switch typed := current.(type) {
+		case map[string]interface{}:
+			if last {
+				typed[segment] = value
+				return true
+			}
+			next, ok := typed[segment]
+			if !ok || next == nil {
+				next = make(map[string]interface{})
+				typed[segment] = next
+			}
+			current = next
+		case []interface{}:
+			idx, err := strconv.Atoi(segment)
+			if err != nil || idx < 0 || idx >= len(typed) {
+				return false
+			}
+			if last {
+				typed[idx] = value
+				return true
+			}
+			current = typed[idx]
+		default:
+			return false
+		}
+	}
+	return false
+}
+
+// Copy returns a deep copy of a values tree.
+func Copy(vals map[string]interface{}) map[string]interface{} {
+	if vals == nil {
+		return nil
+	}
+	return copyValue(vals).(map[string]interface{})
+}
+
+func copyValue(v interface{}) interface{} {
+	switch typed := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(typed))
+		for k, child := range typed {
+			out[k] = copyValue(child)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(typed))
+		for i, child := range typed {
+			out[i] = copyValue(child)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+// Redact returns a copy of vals with every secret path and every secret-looking
+// key replaced by RedactedPlaceholder. The input map is never modified.
+func Redact(vals map[string]interface{}, secretPaths []string) map[string]interface{} {
+	out := RedactSecretKeys(vals)
+	for _, path := range secretPaths {
+		if current, ok := Get(out, path); ok && current != nil {
+			Set(out, path, RedactedPlaceholder)
+		}
+	}
+	return out
+}
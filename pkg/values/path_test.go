@@ -0,0 +1,69 @@
+package values
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGet(t *testing.T) {
+	vals := decode(t, `{"auth": {"username": "admin"}, "extraEnv": [{"value": "1"}], "empty": null}`)
+
+	for path, want := range map[string]interface{}{
+		"auth.username":    "admin",
+		"extraEnv.0.value": "1",
+		"empty":            nil,
+	} {
+		if got, ok := Get(vals, path); !ok || got != want {
+			t.Errorf("Get(%q) = %v, %t, want %v", path, got, ok, want)
+		}
+	}
+	for _, path := range []string{"auth.password", "extraEnv.1.value", "extraEnv.name", "auth.username.first"} {
+		if got, ok := Get(vals, path); ok {
+			t.Errorf("Get(%q) = %v, want no value", path, got)
+		}
+	}
+}
+
+func TestSet(t *testing.T) {
+	vals := decode(t, `{"auth": {"username": "admin"}, "extraEnv": [{"value": "1"}], "empty": null}`)
+
+	// Generated credentials and secret references land at their path, creating the maps on the way
+	for _, path := range []string{"auth.password", "gitea.admin.password", "empty.value", "extraEnv.0.value"} {
+		if !Set(vals, path, "x") {
+			t.Errorf("Set(%q) failed", path)
+		} else if got, _ := Get(vals, path); got != "x" {
+			t.Errorf("after Set(%q), the value is %v", path, got)
+		}
+	}
+	if got, _ := Get(vals, "auth.username"); got != "admin" {
+		t.Errorf("Set() lost a sibling value: auth.username = %v", got)
+	}
+
+	// Lists are never grown and scalars never replaced by maps
+	for _, path := range []string{"extraEnv.1.value", "extraEnv.-1", "auth.username.first", ""} {
+		before := Copy(vals)
+		if Set(vals, path, "x") {
+			t.Errorf("Set(%q) succeeded", path)
+		}
+		if !reflect.DeepEqual(vals, before) {
+			t.Errorf("failed Set(%q) changed the values", path)
+		}
+	}
+}
+
+func TestCopy(t *testing.T) {
+	vals := decode(t, `{"auth": {"username": "admin"}, "extraEnv": [{"value": "1"}]}`)
+	copied := Copy(vals)
+	if !reflect.DeepEqual(copied, vals) {
+		t.Fatalf("Copy() = %v, want %v", copied, vals)
+	}
+
+	Set(copied, "auth.username", "changed")
+	Set(copied, "extraEnv.0.value", "changed")
+	if got, _ := Get(vals, "auth.username"); got != "admin" {
+		t.Error("the copy shares its maps with the original")
+	}
+	if got, _ := Get(vals, "extraEnv.0.value"); got != "1" {
+		t.Error("the copy shares its lists with the original")
+	}
+}
@@ -0,0 +1,49 @@
+package values
+
+import (
+	"regexp"
+)
+
+// RedactedPlaceholder replaces any value considered sensitive.
+const RedactedPlaceholder = "[REDACTED]"
+
+// secretKeyPattern matches value keys that commonly hold credentials.
+var secretKeyPattern = regexp.MustCompile(`(?i)(password|passwd|secret|token|apikey|api_key|private_?key|credential)`)
+
+// IsSecretKey reports whether a key name looks like it holds a secret.
+func IsSecretKey(key string) bool {
+	return secretKeyPattern.MatchString(key)
+}
+
+// RedactSecretKeys returns a deep copy of vals where everything stored under a
+// secret-looking key, whole maps and lists included, is replaced with RedactedPlaceholder.
+// The input map is never modified.
+func RedactSecretKeys(vals map[string]interface{}) map[string]interface{} {
+	if vals == nil {
+		return nil
+	}
+	return redactKeys(vals).(map[string]interface{})
+}
+
+func redactKeys(v interface{}) interface{} {
+	switch typed := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(typed))
+		for k, child := range typed {
+			if IsSecretKey(k) {
+				out[k] = RedactedPlaceholder
+				continue
+			}
+			out[k] = redactKeys(child)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(typed))
+		for i, child := range typed {
+			out[i] = redactKeys(child)
+		}
+		return out
+	default:
+		return v
+	}
+}
@@ -0,0 +1,71 @@
+package values
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func decode(t *testing.T, doc string) map[string]interface{} {
+	t.Helper()
+	var vals map[string]interface{}
+	if err := json.Unmarshal([]byte(doc), &vals); err != nil {
+		t.Fatalf("invalid test document %s: %v", doc, err)
+	}
+	return vals
+}
+
+func TestRedactSecretKeys(t *testing.T) {
+	in := decode(t, `{
+		"auth": {"rootPassword": "s3cret", "username": "admin", "existingSecret": ""},
+		"apiKey": "k",
+		"tokens": {"read": "r", "write": {"value": "w"}},
+		"credentials": ["a", "b"],
+		"extraEnv": [{"name": "DB_USER", "value": "app"}, {"client_secret": "c"}],
+		"replicaCount": 2,
+		"tls": {"enabled": true}
+	}`)
+	want := decode(t, `{
+		"auth": {"rootPassword": "[REDACTED]", "username": "admin", "existingSecret": "[REDACTED]"},
+		"apiKey": "[REDACTED]",
+		"tokens": "[REDACTED]",
+		"credentials": "[REDACTED]",
+		"extraEnv": [{"name": "DB_USER", "value": "app"}, {"client_secret": "[REDACTED]"}],
+		"replicaCount": 2,
+		"tls": {"enabled": true}
+	}`)
+
+	got := RedactSecretKeys(in)
+	if !reflect.DeepEqual(got, want) {
+		gotJSON, _ := json.Marshal(got)
+		t.Errorf("RedactSecretKeys() = %s", gotJSON)
+	}
+	if tokens, _ := in["tokens"].(map[string]interface{}); tokens["read"] != "r" {
+		t.Error("RedactSecretKeys() modified its input")
+	}
+	if RedactSecretKeys(nil) != nil {
+		t.Error("RedactSecretKeys(nil) is not nil")
+	}
+}
+
+func TestRedactSecretPaths(t *testing.T) {
+	in := decode(t, `{"smtp": {"host": "mail", "pass": "p"}, "db": {"dsn": null}}`)
+	got := Redact(in, []string{"smtp.pass", "db.dsn", "ldap.bindPw"})
+
+	if v, _ := Get(got, "smtp.pass"); v != RedactedPlaceholder {
+		t.Errorf("declared secret path smtp.pass = %v, want it redacted", v)
+	}
+	if v, _ := Get(got, "smtp.host"); v != "mail" {
+		t.Errorf("smtp.host = %v, want it kept", v)
+	}
+	// Unset secrets are not made to look set
+	if v, ok := Get(got, "db.dsn"); !ok || v != nil {
+		t.Errorf("null secret path db.dsn = %v, want it left null", v)
+	}
+	if _, ok := Get(got, "ldap"); ok {
+		t.Error("a missing secret path was created")
+	}
+	if v, _ := Get(in, "smtp.pass"); v != "p" {
+		t.Error("Redact() modified its input")
+	}
+}